@@ -33,12 +33,6 @@ func main() {
 	log := logger.New(cfg.Logger)
 	log.Info("Starting manga reader API server")
 
-	// Создаем каталог для изображений, если он не существует
-	if err := os.MkdirAll(cfg.Storage.ImagesPath, 0755); err != nil {
-		log.Error("Failed to create images directory", "error", err)
-		os.Exit(1)
-	}
-
 	// Инициализируем приложение
 	app, err := app.NewApp(cfg, log)
 	if err != nil {
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/config"
+	"github.com/LirikaOne-Back/manga-reader3/internal/migrate"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/logger"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq" // Импорт драйвера PostgreSQL
+)
+
+const usage = `Использование: migrate [-env .env] <команда> [аргументы]
+
+Команды:
+  up              применить все непримененные миграции
+  down [N]        откатить N последних миграций (по умолчанию 1)
+  status          показать состояние миграций
+  to <версия>     привести схему к указанной версии (0 - откатить все)
+`
+
+func main() {
+	envPath := flag.String("env", ".env", "путь к .env файлу")
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Загружаем конфигурацию
+	cfg, err := config.LoadConfig(*envPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Инициализируем логгер
+	log := logger.New(cfg.Logger)
+
+	db, err := sqlx.Connect("postgres", cfg.Postgres.DSN())
+	if err != nil {
+		log.Error("Failed to connect to postgres", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.NewMigrator(db, log)
+	if err != nil {
+		log.Error("Failed to load migrations", "error", err)
+		os.Exit(1)
+	}
+
+	if err := run(context.Background(), migrator, flag.Args()); err != nil {
+		log.Error("Migration failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run выполняет команду миграции
+func run(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", count)
+
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("target version is required")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+
+		count, err := migrator.To(ctx, version)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schema is at version %d (%d migration(s) changed)\n", version, count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+
+	return nil
+}
@@ -7,14 +7,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/config"
 	"github.com/LirikaOne-Back/manga-reader3/internal/handler"
+	"github.com/LirikaOne-Back/manga-reader3/internal/jwtkeys"
+	"github.com/LirikaOne-Back/manga-reader3/internal/mailer"
+	"github.com/LirikaOne-Back/manga-reader3/internal/migrate"
+	"github.com/LirikaOne-Back/manga-reader3/internal/oidc"
+	"github.com/LirikaOne-Back/manga-reader3/internal/ratelimit"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository/postgres"
 	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/LirikaOne-Back/manga-reader3/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // Импорт драйвера PostgreSQL
@@ -39,30 +47,78 @@ func NewApp(cfg *config.Config, logger *slog.Logger) (*App, error) {
 		return nil, fmt.Errorf("failed to initialize postgres: %w", err)
 	}
 
+	// Применяем миграции схемы, если включено
+	if cfg.Postgres.AutoMigrate {
+		if err := runMigrations(db, logger); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	}
+
 	// Инициализируем подключение к Redis
 	redisClient, err := initRedis(cfg.Redis, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize redis: %w", err)
 	}
 
+	// Инициализируем хранилище изображений
+	imageStorage, err := initStorage(cfg.Storage, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Инициализируем отправку писем
+	mail, err := initMailer(cfg.Mail, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize mailer: %w", err)
+	}
+
+	// Загружаем ключи подписи JWT
+	jwtKeys, err := initJWTKeys(cfg.JWT, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize jwt keys: %w", err)
+	}
+
 	// Инициализируем репозитории
 	repos := initRepositories(db, logger)
 
 	// Инициализируем сервисы
-	services := initServices(repos, cfg, redisClient, logger)
+	services := initServices(repos, cfg, redisClient, imageStorage, mail, jwtKeys, logger)
+
+	// Перестраиваем индекс подсказок поиска: манга могла измениться, пока приложение не работало
+	if err := services.Manga.RebuildSuggestIndex(context.Background()); err != nil {
+		logger.Warn("Failed to build suggest index", "error", err)
+	}
+
+	// Инициализируем ограничения частоты запросов
+	rateLimits, err := initRateLimits(cfg.RateLimit, redisClient, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize rate limits: %w", err)
+	}
 
 	// Инициализируем обработчики
-	handlers := initHandlers(services, logger)
+	handlerConfig := handler.Config{
+		RateLimits: rateLimits,
+		Timeouts: map[string]time.Duration{
+			"default": cfg.Server.RequestTimeout,
+			"upload":  cfg.Server.UploadTimeout,
+		},
+	}
+	handlers := initHandlers(services, handlerConfig, logger)
 
 	// Инициализируем роутер
-	router := initRouter(handlers, cfg, logger)
+	router, err := initRouter(handlers, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize router: %w", err)
+	}
 
 	// Инициализируем HTTP-сервер
+	// ReadTimeout рассчитан на загрузку архивов, поэтому заголовки запроса ограничены отдельно
 	httpServer := &http.Server{
-		Addr:         ":" + cfg.Server.Port,
-		Handler:      router,
-		ReadTimeout:  cfg.Server.ReadTimeout,
-		WriteTimeout: cfg.Server.WriteTimeout,
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.RequestTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
 	}
 
 	return &App{
@@ -128,6 +184,9 @@ func initPostgres(cfg config.PostgresConfig, logger *slog.Logger) (*sqlx.DB, err
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
 
+	// Сопоставляем поля структур с колонками в snake_case (ChapterID -> chapter_id)
+	db.MapperFunc(toSnakeCase)
+
 	// Настраиваем пул соединений
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
@@ -142,6 +201,40 @@ func initPostgres(cfg config.PostgresConfig, logger *slog.Logger) (*sqlx.DB, err
 	return db, nil
 }
 
+// runMigrations применяет непримененные миграции схемы
+func runMigrations(db *sqlx.DB, logger *slog.Logger) error {
+	migrator, err := migrate.NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+
+	count, err := migrator.Up(context.Background())
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Database schema is up to date", "applied", count, "version", migrator.Latest())
+	return nil
+}
+
+// toSnakeCase преобразует имя поля Go в snake_case с учетом аббревиатур (ImageURL -> image_url)
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Разделитель ставим на границе слова: aB или ABc
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // initRedis инициализирует подключение к Redis
 func initRedis(cfg config.RedisConfig, logger *slog.Logger) (*redis.Client, error) {
 	logger.Info("Connecting to Redis", "host", cfg.Host, "port", cfg.Port)
@@ -169,12 +262,66 @@ func initRedis(cfg config.RedisConfig, logger *slog.Logger) (*redis.Client, erro
 	return client, nil
 }
 
+// initStorage инициализирует хранилище изображений
+func initStorage(cfg config.StorageConfig, logger *slog.Logger) (storage.Storage, error) {
+	logger.Info("Initializing storage", "backend", cfg.Backend)
+
+	switch cfg.Backend {
+	case "local", "":
+		return storage.NewLocalStorage(cfg.ImagesPath, cfg.PublicURL)
+	case "s3":
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		return storage.NewS3Storage(ctx, cfg.S3)
+	case "memory":
+		logger.Warn("Using in-memory storage, images will be lost on restart")
+		return storage.NewMemoryStorage(cfg.PublicURL), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// initMailer инициализирует отправку писем
+func initMailer(cfg config.MailConfig, logger *slog.Logger) (mailer.Mailer, error) {
+	logger.Info("Initializing mailer", "backend", cfg.Backend)
+
+	switch cfg.Backend {
+	case "log", "":
+		return mailer.NewLogMailer(cfg.From, cfg.Dir, logger)
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTP), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend: %s", cfg.Backend)
+	}
+}
+
+// initJWTKeys загружает ключи подписи JWT
+func initJWTKeys(cfg config.JWTConfig, logger *slog.Logger) (*jwtkeys.KeySet, error) {
+	keys, err := jwtkeys.Load(jwtkeys.Config{
+		Algorithm:    cfg.SigningAlgorithm,
+		Secret:       cfg.Secret,
+		Dir:          cfg.KeysDir,
+		ActiveKey:    cfg.ActiveKeyID,
+		AcceptSecret: cfg.AcceptSecret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("JWT keys loaded", "algorithm", keys.Active().Algorithm, "active_kid", keys.Active().ID, "keys", len(keys.JWKS().Keys))
+	return keys, nil
+}
+
 // initRepositories инициализирует репозитории
 func initRepositories(db *sqlx.DB, logger *slog.Logger) *repository.Repositories {
 	return &repository.Repositories{
 		Manga:   postgres.NewMangaRepo(db, logger),
 		Chapter: postgres.NewChapterRepo(db, logger),
 		User:    postgres.NewUserRepo(db, logger),
+		Comment: postgres.NewCommentRepo(db, logger),
+		Team:    postgres.NewTeamRepo(db, logger),
+		Audit:   postgres.NewAuditRepo(db, logger),
 	}
 }
 
@@ -183,43 +330,116 @@ func initServices(
 	repos *repository.Repositories,
 	cfg *config.Config,
 	redisClient *redis.Client,
+	imageStorage storage.Storage,
+	mail mailer.Mailer,
+	jwtKeys *jwtkeys.KeySet,
 	logger *slog.Logger,
 ) *service.Services {
-	mangaService := service.NewMangaService(repos.Manga, logger)
+	auditService := service.NewAuditService(repos.Audit, logger)
+
+	mangaService := service.NewMangaService(repos.Manga, repos.Team, auditService, redisClient, logger)
 
 	chapterService := service.NewChapterService(
 		repos.Chapter,
 		repos.Manga,
+		repos.Team,
+		auditService,
 		logger,
-		cfg.Storage.ImagesPath,
+		imageStorage,
+		cfg.Storage.Image,
 	)
 
 	authConfig := service.AuthConfig{
-		JWTSecret:    cfg.JWT.Secret,
-		AccessTTL:    cfg.JWT.AccessTokenTTL,
-		RefreshTTL:   cfg.JWT.RefreshTokenTTL,
-		JWTAlgorithm: cfg.JWT.SigningAlgorithm,
-		RedisClient:  redisClient,
+		Keys:        jwtKeys,
+		AccessTTL:   cfg.JWT.AccessTokenTTL,
+		RefreshTTL:  cfg.JWT.RefreshTokenTTL,
+		RedisClient: redisClient,
+		Mailer:      mail,
+		AppURL:      cfg.Mail.AppURL,
+		VerifyTTL:   cfg.Mail.VerifyTTL,
+		ResetTTL:    cfg.Mail.ResetTTL,
+
+		TwoFactorRequired: cfg.TwoFactor.Required,
+		TwoFactorIssuer:   cfg.TwoFactor.Issuer,
+
+		LoginThrottle: service.LoginThrottleConfig{
+			FreeAttempts:  cfg.Login.FreeAttempts,
+			BaseDelay:     cfg.Login.BaseDelay,
+			MaxAttempts:   cfg.Login.MaxAttempts,
+			IPMaxAttempts: cfg.Login.IPMaxAttempts,
+			Lockout:       cfg.Login.Lockout,
+		},
+
+		Audit: auditService,
+	}
+	for _, providerConfig := range cfg.OIDC.Providers {
+		authConfig.OIDC = append(authConfig.OIDC, oidc.NewProvider(providerConfig))
 	}
 
 	authService := service.NewAuthService(repos.User, logger, authConfig)
 
-	userService := service.NewUserService(repos.User, logger)
+	userService := service.NewUserService(repos.User, auditService, logger)
+
+	commentService := service.NewCommentService(
+		repos.Comment,
+		repos.Manga,
+		repos.Chapter,
+		auditService,
+		logger,
+		cfg.Comments.EditWindow,
+	)
+
+	teamService := service.NewTeamService(repos.Team, repos.User, auditService, logger)
 
 	return &service.Services{
 		Manga:   mangaService,
 		Chapter: chapterService,
 		Auth:    authService,
 		User:    userService,
+		Comment: commentService,
+		Team:    teamService,
+		Audit:   auditService,
+	}
+}
+
+// initRateLimits инициализирует хранилище и политики ограничения частоты запросов
+func initRateLimits(cfg config.RateLimitConfig, redisClient *redis.Client, logger *slog.Logger) (handler.RateLimits, error) {
+	if !cfg.Enabled {
+		logger.Info("Rate limiting disabled")
+		return handler.RateLimits{}, nil
 	}
+
+	logger.Info("Initializing rate limits", "store", cfg.Store)
+
+	var store ratelimit.Store
+	switch cfg.Store {
+	case "redis", "":
+		store = ratelimit.NewRedisStore(redisClient)
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	default:
+		return handler.RateLimits{}, fmt.Errorf("unknown rate limit store: %s", cfg.Store)
+	}
+
+	policies := make(map[string]ratelimit.Policy, len(cfg.Policies))
+	for name, spec := range cfg.Policies {
+		policy, err := ratelimit.ParsePolicy(name, spec)
+		if err != nil {
+			return handler.RateLimits{}, err
+		}
+		policies[name] = policy
+	}
+
+	return handler.RateLimits{Store: store, Policies: policies}, nil
 }
 
 // initHandlers инициализирует обработчики
 func initHandlers(
 	services *service.Services,
+	handlerConfig handler.Config,
 	logger *slog.Logger,
 ) *handler.Handler {
-	return handler.NewHandler(services, logger)
+	return handler.NewHandler(services, handlerConfig, logger)
 }
 
 // initRouter инициализирует роутер Gin
@@ -227,7 +447,7 @@ func initRouter(
 	h *handler.Handler,
 	cfg *config.Config,
 	logger *slog.Logger,
-) *gin.Engine {
+) (*gin.Engine, error) {
 	// Настраиваем режим Gin в зависимости от уровня логирования
 	if cfg.Logger.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -242,7 +462,9 @@ func initRouter(
 	router.Use(gin.Recovery())
 
 	// Регистрируем обработчики
-	h.Register(router)
+	if err := h.Register(router); err != nil {
+		return nil, err
+	}
 
-	return router
+	return router, nil
 }
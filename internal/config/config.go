@@ -1,23 +1,35 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/LirikaOne-Back/manga-reader3/internal/mailer"
+	"github.com/LirikaOne-Back/manga-reader3/internal/oidc"
+	"github.com/LirikaOne-Back/manga-reader3/internal/storage"
 	"github.com/LirikaOne-Back/manga-reader3/pkg/logger"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/utils"
 	"github.com/joho/godotenv"
 )
 
 // Config содержит все настройки приложения
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	Logger   logger.Config
-	JWT      JWTConfig
-	Storage  StorageConfig
-	Redis    RedisConfig
+	Server    ServerConfig
+	Postgres  PostgresConfig
+	Logger    logger.Config
+	JWT       JWTConfig
+	Storage   StorageConfig
+	Redis     RedisConfig
+	Comments  CommentsConfig
+	Mail      MailConfig
+	OIDC      OIDCConfig
+	TwoFactor TwoFactorConfig
+	Login     LoginConfig
+	RateLimit RateLimitConfig
 }
 
 // ServerConfig настройки HTTP-сервера
@@ -26,16 +38,19 @@ type ServerConfig struct {
 	ShutdownTimeout time.Duration
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
+	RequestTimeout  time.Duration // Время обработки запроса, после которого отменяется его контекст
+	UploadTimeout   time.Duration // Время обработки загрузки страниц глав
 }
 
 // PostgresConfig настройки подключения к PostgreSQL
 type PostgresConfig struct {
-	Host     string
-	Port     string
-	Username string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host        string
+	Port        string
+	Username    string
+	Password    string
+	DBName      string
+	SSLMode     string
+	AutoMigrate bool // Применять миграции при запуске приложения
 }
 
 // JWTConfig настройки JWT-токенов
@@ -43,12 +58,62 @@ type JWTConfig struct {
 	Secret           string
 	AccessTokenTTL   time.Duration
 	RefreshTokenTTL  time.Duration
-	SigningAlgorithm string
+	SigningAlgorithm string // HS256, RS256, ES256, EdDSA
+	KeysDir          string // Каталог с ключами <kid>.pem для RS256, ES256, EdDSA
+	ActiveKeyID      string // kid ключа, которым подписываются новые токены
+	AcceptSecret     bool   // Принимать токены HS256, подписанные Secret, после перехода на асимметричную подпись
 }
 
 // StorageConfig настройки хранилища файлов
 type StorageConfig struct {
-	ImagesPath string
+	Backend    string // local, s3, memory
+	ImagesPath string // Каталог для backend=local
+	PublicURL  string // Базовый адрес, по которому раздаются изображения
+	S3         storage.S3Config
+	Image      utils.ProcessImageOptions // Обработка загружаемых страниц
+}
+
+// CommentsConfig настройки комментариев
+type CommentsConfig struct {
+	EditWindow time.Duration // Время, в течение которого автор может редактировать комментарий
+}
+
+// MailConfig настройки отправки писем
+type MailConfig struct {
+	Backend   string // smtp, log
+	From      string
+	Dir       string // Каталог для писем при backend=log; пустое значение - только лог
+	AppURL    string // Адрес фронтенда для ссылок в письмах
+	VerifyTTL time.Duration
+	ResetTTL  time.Duration
+	SMTP      mailer.SMTPConfig
+}
+
+// OIDCConfig настройки входа через внешних провайдеров OpenID Connect
+type OIDCConfig struct {
+	Providers []oidc.ProviderConfig
+}
+
+// TwoFactorConfig настройки двухфакторной аутентификации
+type TwoFactorConfig struct {
+	Required bool   // 2FA обязательна для модераторов и администраторов
+	Issuer   string // Название сервиса в приложении-аутентификаторе
+}
+
+// LoginConfig настройки защиты входа от подбора пароля
+type LoginConfig struct {
+	FreeAttempts  int           // Неудачные попытки без задержки
+	BaseDelay     time.Duration // Начальная задержка, удваивается с каждой следующей неудачной попыткой
+	MaxAttempts   int           // Неудачные попытки для одного имени пользователя до блокировки
+	IPMaxAttempts int           // Неудачные попытки с одного IP до блокировки
+	Lockout       time.Duration
+}
+
+// RateLimitConfig настройки ограничения частоты запросов
+type RateLimitConfig struct {
+	Enabled  bool
+	Store    string            // redis, memory
+	Policies map[string]string // Политики групп маршрутов в формате "300/m;moderator=1000/m;admin=0"
 }
 
 // RedisConfig содержит настройки подключения к Redis
@@ -85,8 +150,14 @@ func LoadConfig(path string) (*Config, error) {
 	// Настройки сервера
 	serverPort := getEnv("SERVER_PORT", "8080")
 	shutdownTimeout, _ := strconv.Atoi(getEnv("SERVER_SHUTDOWN_TIMEOUT", "5"))
-	readTimeout, _ := strconv.Atoi(getEnv("SERVER_READ_TIMEOUT", "10"))
-	writeTimeout, _ := strconv.Atoi(getEnv("SERVER_WRITE_TIMEOUT", "10"))
+	readTimeout, _ := strconv.Atoi(getEnv("SERVER_READ_TIMEOUT", "120"))
+	writeTimeout, _ := strconv.Atoi(getEnv("SERVER_WRITE_TIMEOUT", "130"))
+	requestTimeout, _ := strconv.Atoi(getEnv("SERVER_REQUEST_TIMEOUT", "10"))
+	uploadTimeout, _ := strconv.Atoi(getEnv("SERVER_UPLOAD_TIMEOUT", "120"))
+	// Таймауты http.Server ограничивают любой запрос, поэтому таймаут маршрута не может быть больше них
+	if max(requestTimeout, uploadTimeout) > min(readTimeout, writeTimeout) {
+		return nil, errors.New("invalid SERVER_READ_TIMEOUT or SERVER_WRITE_TIMEOUT: must not be less than SERVER_REQUEST_TIMEOUT and SERVER_UPLOAD_TIMEOUT")
+	}
 
 	// Настройки PostgreSQL
 	pgHost := getEnv("POSTGRES_HOST", "localhost")
@@ -95,19 +166,47 @@ func LoadConfig(path string) (*Config, error) {
 	pgPass := getEnv("POSTGRES_PASSWORD", "postgres")
 	pgDB := getEnv("POSTGRES_DB", "manga_reader")
 	pgSSLMode := getEnv("POSTGRES_SSLMODE", "disable")
+	pgAutoMigrate := getEnv("POSTGRES_AUTO_MIGRATE", "false") == "true"
 
 	// Настройки JWT
 	jwtSecret := getEnv("JWT_SECRET", "super_secret_key")
 	jwtAccessTTL, _ := strconv.Atoi(getEnv("JWT_ACCESS_TTL", "15"))  // минуты
 	jwtRefreshTTL, _ := strconv.Atoi(getEnv("JWT_REFRESH_TTL", "7")) // дни
 	jwtAlgorithm := getEnv("JWT_SIGNING_ALGORITHM", "HS256")
+	jwtKeysDir := getEnv("JWT_KEYS_DIR", "./keys")
+	jwtActiveKeyID := getEnv("JWT_ACTIVE_KEY_ID", "")
+	jwtAcceptSecret, _ := strconv.ParseBool(getEnv("JWT_ACCEPT_SECRET", "false"))
 
 	// Настройки логгера
 	logLevel := getEnv("LOG_LEVEL", "info")
 	logJSON := getEnv("LOG_JSON", "false") == "true"
 
 	// Настройки хранилища
+	storageBackend := getEnv("STORAGE_BACKEND", "local")
 	imagesPath := getEnv("STORAGE_IMAGES_PATH", "./data/images")
+	storagePublicURL := getEnv("STORAGE_PUBLIC_URL", "/images")
+	s3Endpoint := getEnv("STORAGE_S3_ENDPOINT", "localhost:9000")
+	s3Region := getEnv("STORAGE_S3_REGION", "us-east-1")
+	s3Bucket := getEnv("STORAGE_S3_BUCKET", "manga-images")
+	s3AccessKey := getEnv("STORAGE_S3_ACCESS_KEY", "")
+	s3SecretKey := getEnv("STORAGE_S3_SECRET_KEY", "")
+	s3UseSSL := getEnv("STORAGE_S3_USE_SSL", "false") == "true"
+	s3PublicURL := getEnv("STORAGE_S3_PUBLIC_URL", "")
+	imageFormat := getEnv("STORAGE_IMAGE_FORMAT", utils.FormatJPEG)
+	imageMaxWidth, _ := strconv.Atoi(getEnv("STORAGE_IMAGE_MAX_WIDTH", "1920"))
+	imageMaxHeight, _ := strconv.Atoi(getEnv("STORAGE_IMAGE_MAX_HEIGHT", "2400"))
+	imageQuality, _ := strconv.Atoi(getEnv("STORAGE_IMAGE_QUALITY", "85"))
+	if imageFormat == "original" {
+		imageFormat = "" // Сохраняем исходный формат
+	} else if !utils.IsSupportedFormat(imageFormat) {
+		return nil, fmt.Errorf("invalid STORAGE_IMAGE_FORMAT %q: must be jpeg, webp, png or original", imageFormat)
+	}
+	if imageMaxWidth <= 0 || imageMaxHeight <= 0 {
+		return nil, errors.New("invalid STORAGE_IMAGE_MAX_WIDTH or STORAGE_IMAGE_MAX_HEIGHT: must be positive integers")
+	}
+	if imageQuality <= 0 || imageQuality > 100 {
+		return nil, errors.New("invalid STORAGE_IMAGE_QUALITY: must be from 1 to 100")
+	}
 
 	// Настройки Redis
 	redisHost := getEnv("REDIS_HOST", "redis")
@@ -117,6 +216,45 @@ func LoadConfig(path string) (*Config, error) {
 	redisPoolSize, _ := strconv.Atoi(getEnv("REDIS_POOL_SIZE", "10"))
 	redisTTL, _ := strconv.Atoi(getEnv("REDIS_TTL", "60")) // в минутах
 
+	// Настройки комментариев
+	commentsEditWindow, _ := strconv.Atoi(getEnv("COMMENTS_EDIT_WINDOW", "15")) // в минутах
+
+	// Настройки почты
+	mailBackend := getEnv("MAIL_BACKEND", "log")
+	mailFrom := getEnv("MAIL_FROM", "Manga Reader <no-reply@localhost>")
+	mailDir := getEnv("MAIL_DIR", "./data/mail")
+	mailAppURL := getEnv("MAIL_APP_URL", "http://localhost:3000")
+	mailVerifyTTL, _ := strconv.Atoi(getEnv("MAIL_VERIFY_TTL", "24")) // в часах
+	mailResetTTL, _ := strconv.Atoi(getEnv("MAIL_RESET_TTL", "1"))    // в часах
+	smtpHost := getEnv("SMTP_HOST", "localhost")
+	smtpPort := getEnv("SMTP_PORT", "587")
+	smtpUsername := getEnv("SMTP_USERNAME", "")
+	smtpPassword := getEnv("SMTP_PASSWORD", "")
+
+	// Настройки OpenID Connect
+	oidcProviders := loadOIDCProviders(getEnv("OIDC_PROVIDERS", ""), getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080"))
+
+	// Настройки двухфакторной аутентификации
+	twoFactorRequired, _ := strconv.ParseBool(getEnv("TWO_FACTOR_REQUIRED", "false"))
+	twoFactorIssuer := getEnv("TWO_FACTOR_ISSUER", "Manga Reader")
+
+	// Настройки защиты входа
+	loginFreeAttempts, _ := strconv.Atoi(getEnv("LOGIN_FREE_ATTEMPTS", "3"))
+	loginBaseDelay, _ := strconv.Atoi(getEnv("LOGIN_BASE_DELAY", "1")) // секунды
+	loginMaxAttempts, _ := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "10"))
+	loginIPMaxAttempts, _ := strconv.Atoi(getEnv("LOGIN_IP_MAX_ATTEMPTS", "50"))
+	loginLockout, _ := strconv.Atoi(getEnv("LOGIN_LOCKOUT", "15")) // минуты
+
+	// Настройки ограничения частоты запросов
+	rateLimitEnabled, _ := strconv.ParseBool(getEnv("RATE_LIMIT_ENABLED", "true"))
+	rateLimitStore := getEnv("RATE_LIMIT_STORE", "redis")
+	rateLimitPolicies := map[string]string{
+		"api":    getEnv("RATE_LIMIT_API", "300/m;moderator=1000/m;admin=0"),
+		"auth":   getEnv("RATE_LIMIT_AUTH", "20/m"),
+		"search": getEnv("RATE_LIMIT_SEARCH", "60/m;user=120/m"),
+		"upload": getEnv("RATE_LIMIT_UPLOAD", "300/h;admin=0"),
+	}
+
 	// Создаем и возвращаем конфигурацию
 	return &Config{
 		Server: ServerConfig{
@@ -124,14 +262,17 @@ func LoadConfig(path string) (*Config, error) {
 			ShutdownTimeout: time.Duration(shutdownTimeout) * time.Second,
 			ReadTimeout:     time.Duration(readTimeout) * time.Second,
 			WriteTimeout:    time.Duration(writeTimeout) * time.Second,
+			RequestTimeout:  time.Duration(requestTimeout) * time.Second,
+			UploadTimeout:   time.Duration(uploadTimeout) * time.Second,
 		},
 		Postgres: PostgresConfig{
-			Host:     pgHost,
-			Port:     pgPort,
-			Username: pgUser,
-			Password: pgPass,
-			DBName:   pgDB,
-			SSLMode:  pgSSLMode,
+			Host:        pgHost,
+			Port:        pgPort,
+			Username:    pgUser,
+			Password:    pgPass,
+			DBName:      pgDB,
+			SSLMode:     pgSSLMode,
+			AutoMigrate: pgAutoMigrate,
 		},
 		Logger: logger.Config{
 			Level:      logger.Level(logLevel),
@@ -144,9 +285,29 @@ func LoadConfig(path string) (*Config, error) {
 			AccessTokenTTL:   time.Duration(jwtAccessTTL) * time.Minute,
 			RefreshTokenTTL:  time.Duration(jwtRefreshTTL) * 24 * time.Hour,
 			SigningAlgorithm: jwtAlgorithm,
+			KeysDir:          jwtKeysDir,
+			ActiveKeyID:      jwtActiveKeyID,
+			AcceptSecret:     jwtAcceptSecret,
 		},
 		Storage: StorageConfig{
+			Backend:    storageBackend,
 			ImagesPath: imagesPath,
+			PublicURL:  storagePublicURL,
+			S3: storage.S3Config{
+				Endpoint:  s3Endpoint,
+				Region:    s3Region,
+				Bucket:    s3Bucket,
+				AccessKey: s3AccessKey,
+				SecretKey: s3SecretKey,
+				UseSSL:    s3UseSSL,
+				PublicURL: s3PublicURL,
+			},
+			Image: utils.ProcessImageOptions{
+				MaxWidth:  imageMaxWidth,
+				MaxHeight: imageMaxHeight,
+				Quality:   imageQuality,
+				Format:    imageFormat,
+			},
 		},
 		Redis: RedisConfig{
 			Host:     redisHost,
@@ -156,9 +317,71 @@ func LoadConfig(path string) (*Config, error) {
 			PoolSize: redisPoolSize,
 			TTL:      time.Duration(redisTTL) * time.Minute,
 		},
+		Comments: CommentsConfig{
+			EditWindow: time.Duration(commentsEditWindow) * time.Minute,
+		},
+		Mail: MailConfig{
+			Backend:   mailBackend,
+			From:      mailFrom,
+			Dir:       mailDir,
+			AppURL:    mailAppURL,
+			VerifyTTL: time.Duration(mailVerifyTTL) * time.Hour,
+			ResetTTL:  time.Duration(mailResetTTL) * time.Hour,
+			SMTP: mailer.SMTPConfig{
+				Host:     smtpHost,
+				Port:     smtpPort,
+				Username: smtpUsername,
+				Password: smtpPassword,
+				From:     mailFrom,
+			},
+		},
+		OIDC: OIDCConfig{
+			Providers: oidcProviders,
+		},
+		TwoFactor: TwoFactorConfig{
+			Required: twoFactorRequired,
+			Issuer:   twoFactorIssuer,
+		},
+		Login: LoginConfig{
+			FreeAttempts:  loginFreeAttempts,
+			BaseDelay:     time.Duration(loginBaseDelay) * time.Second,
+			MaxAttempts:   loginMaxAttempts,
+			IPMaxAttempts: loginIPMaxAttempts,
+			Lockout:       time.Duration(loginLockout) * time.Minute,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:  rateLimitEnabled,
+			Store:    rateLimitStore,
+			Policies: rateLimitPolicies,
+		},
 	}, nil
 }
 
+// loadOIDCProviders читает настройки провайдеров из переменных OIDC_<NAME>_*.
+// names - список имен через запятую, например "google,keycloak".
+func loadOIDCProviders(names, redirectBaseURL string) []oidc.ProviderConfig {
+	var providers []oidc.ProviderConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		redirectURL := strings.TrimRight(redirectBaseURL, "/") + "/api/auth/oidc/" + name + "/callback"
+
+		providers = append(providers, oidc.ProviderConfig{
+			Name:         name,
+			IssuerURL:    getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", redirectURL),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		})
+	}
+	return providers
+}
+
 // getEnv получает значение переменной окружения или возвращает значение по умолчанию
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы объектов в журнале аудита
+const (
+	AuditEntityManga   = "manga"
+	AuditEntityChapter = "chapter"
+	AuditEntityPage    = "page"
+	AuditEntityUser    = "user"
+	AuditEntityComment = "comment"
+	AuditEntityTeam    = "team"
+)
+
+// AuditEntry представляет запись журнала аудита: кто, что и с каким объектом сделал
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    int             `json:"actor_id"` // 0 - действие без входа (система)
+	ActorName  string          `json:"actor_name,omitempty"`
+	ActorRole  string          `json:"actor_role,omitempty"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Before     json.RawMessage `json:"before"` // Состояние объекта до изменения, null при создании
+	After      json.RawMessage `json:"after"`  // Состояние объекта после изменения, null при удалении
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter содержит параметры выборки записей журнала аудита
+type AuditFilter struct {
+	ActorID    *int
+	EntityType string
+	EntityID   *int
+	From       *time.Time
+	To         *time.Time
+	Cursor     int64 // ID последней записи предыдущей страницы
+	Limit      int
+}
+
+// AuditPage представляет страницу журнала аудита, от новых записей к старым
+type AuditPage struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+}
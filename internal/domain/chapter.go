@@ -15,10 +15,17 @@ type Chapter struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// ChapterPage представляет страницу списка глав манги
+type ChapterPage struct {
+	Chapters   []Chapter `json:"chapters"`
+	NextCursor string    `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+}
+
 // Page представляет страницу главы
 type Page struct {
-	ID        int    `json:"id"`
-	ChapterID int    `json:"chapter_id"`
-	Number    int    `json:"number"`
-	ImageURL  string `json:"image_url"`
+	ID         int               `json:"id"`
+	ChapterID  int               `json:"chapter_id"`
+	Number     int               `json:"number"`
+	ImageURL   string            `json:"image_url"`
+	Renditions map[string]string `json:"renditions,omitempty" db:"-"` // Адреса вариантов изображения (thumb, mobile, full)
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"time"
+)
+
+// Comment представляет комментарий к манге или главе
+type Comment struct {
+	ID         int        `json:"id"`
+	MangaID    int        `json:"manga_id"`
+	ChapterID  *int       `json:"chapter_id,omitempty"` // nil для комментариев к манге
+	ParentID   *int       `json:"parent_id,omitempty"`  // nil для комментариев верхнего уровня
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	Body       string     `json:"body"`
+	Spoiler    bool       `json:"spoiler"`
+	Hidden     bool       `json:"hidden"`  // Скрыт модератором
+	Deleted    bool       `json:"deleted"` // Удален автором или модератором
+	ReplyCount int        `json:"reply_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+}
+
+// CommentTarget определяет ветку комментариев: манга целиком или отдельная глава
+type CommentTarget struct {
+	MangaID   int
+	ChapterID *int
+}
+
+// CommentFilter содержит параметры выборки комментариев
+type CommentFilter struct {
+	Target   CommentTarget
+	ParentID *int // nil - комментарии верхнего уровня
+	Cursor   int  // ID последнего комментария предыдущей страницы
+	Limit    int
+}
+
+// CommentPage представляет страницу комментариев
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+	Locked     bool      `json:"locked"`                // Ветка закрыта для новых комментариев
+}
+
+// CommentInput представляет данные для создания комментария
+type CommentInput struct {
+	Body     string `json:"body" binding:"required,max=5000"`
+	Spoiler  bool   `json:"spoiler"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// CommentUpdate представляет данные для редактирования комментария
+type CommentUpdate struct {
+	Body    string `json:"body" binding:"required,max=5000"`
+	Spoiler bool   `json:"spoiler"`
+}
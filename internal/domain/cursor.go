@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+)
+
+// PageCursor позиция в списке для курсорной пагинации: ключ сортировки последнего элемента
+// предыдущей страницы. Клиент получает курсор в виде непрозрачной строки next_cursor.
+type PageCursor struct {
+	Sort   string    `json:"s"`           // Список и сортировка, для которых выдан курсор
+	Desc   bool      `json:"d,omitempty"` // Сортировка по убыванию
+	ID     int       `json:"i"`           // ID последнего элемента, различает элементы с одинаковым ключом
+	Text   string    `json:"t,omitempty"`
+	Number float64   `json:"n,omitempty"`
+	Count  int       `json:"c,omitempty"`
+	Time   time.Time `json:"tm"`
+}
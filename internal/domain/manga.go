@@ -21,19 +21,98 @@ type Manga struct {
 	Status      string    `json:"status"` // ongoing, completed, hiatus
 	Author      string    `json:"author"`
 	Artist      string    `json:"artist,omitempty"`
-	Rating      float64   `json:"rating"`
+	Rating      float64   `json:"rating"`       // Средняя оценка пользователей
+	RatingCount int       `json:"rating_count"` // Количество оценок
 	Genres      []Genre   `json:"genres"`
+	TeamID      *int      `json:"team_id,omitempty"` // Команда, которой принадлежит манга
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	Highlight *MangaHighlight `json:"highlight,omitempty" db:"-"` // Фрагменты с найденными словами, только в результатах поиска
+	Relevance float64         `json:"-" db:"-"`                   // Релевантность результата поиска
+}
+
+// MangaHighlight содержит фрагменты манги, в которых найденные слова выделены тегом <mark>.
+// Остальной текст экранирован как HTML, поэтому фрагменты можно вставлять в разметку как есть.
+type MangaHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// MangaRating представляет агрегированную оценку манги и оценку текущего пользователя
+type MangaRating struct {
+	MangaID     int     `json:"manga_id"`
+	Rating      float64 `json:"rating"`
+	RatingCount int     `json:"rating_count"`
+	UserScore   int     `json:"user_score,omitempty"` // 0, если пользователь не оценивал мангу
+}
+
+// RatingInput представляет оценку манги пользователем
+type RatingInput struct {
+	Score int `json:"score" binding:"required,min=1,max=10"`
+}
+
+// Watermark представляет настройки водяного знака для страниц манги
+type Watermark struct {
+	MangaID   int       `json:"manga_id"`
+	Enabled   bool      `json:"enabled"`
+	Text      string    `json:"text,omitempty"`
+	Logo      []byte    `json:"-"` // PNG-логотип
+	HasLogo   bool      `json:"has_logo" db:"-"`
+	Position  string    `json:"position"` // top-left, top-right, bottom-left, bottom-right, center
+	Opacity   float64   `json:"opacity"`  // 0-1
+	Scale     float64   `json:"scale"`    // Ширина относительно ширины страницы, 0-1
+	ApplyOn   string    `json:"apply_on"` // upload, rendition
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// MangaFilter содержит параметры для фильтрации списка манги
+// Способы сочетания жанров в MangaFilter.Genres
+const (
+	GenreModeAll = "all" // Манга должна иметь все жанры
+	GenreModeAny = "any" // Манга должна иметь хотя бы один из жанров
+)
+
+// MangaFilter содержит параметры для фильтрации списка манги.
+// Пустые списки и nil-границы не ограничивают выборку.
 type MangaFilter struct {
-	Genre    string
-	Status   string
-	SortBy   string // title, rating, date
-	SortDesc bool
-	Search   string
-	Page     int
-	PageSize int
+	Genres        []string // Названия жанров, которые должна иметь манга
+	GenreMode     string   // all (по умолчанию) или any
+	ExcludeGenres []string // Названия жанров, которых у манги быть не должно
+	Statuses      []string
+	YearFrom      *int
+	YearTo        *int
+	MinRating     *float64
+	Author        string // Часть имени автора
+	Artist        string // Часть имени художника
+	ChaptersFrom  *int   // Минимальное количество глав
+	ChaptersTo    *int   // Максимальное количество глав
+	SortBy        string // title, rating, date, updated (по умолчанию), relevance (только вместе с Search)
+	SortDesc      bool
+	Search        string
+	Page          int // Номер страницы; не учитывается, если задан After
+	PageSize      int
+	After         *PageCursor // Позиция последней манги предыдущей страницы
+	WithTotal     bool        // Подсчитать общее количество подходящей манги
+}
+
+// MangaPage представляет страницу списка манги
+type MangaPage struct {
+	Mangas     []Manga `json:"data"`
+	Total      *int    `json:"total,omitempty"`       // Только если общее количество запрошено
+	NextCursor string  `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+}
+
+// Типы подсказок поиска
+const (
+	SuggestionTitle      = "title"
+	SuggestionAlterTitle = "alter_title"
+	SuggestionAuthor     = "author" // Автор или художник
+	SuggestionGenre      = "genre"
+)
+
+// Suggestion представляет подсказку для строки поиска
+type Suggestion struct {
+	Type    string `json:"type"` // title, alter_title, author, genre
+	Text    string `json:"text"`
+	MangaID int    `json:"manga_id,omitempty"` // Манга, к которой относится название
 }
@@ -1 +1,8 @@
 package domain
+
+// PageImage представляет содержимое изображения страницы для отдачи клиенту
+type PageImage struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// Session представляет активную сессию пользователя (вход с одного устройства)
+type Session struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// ClientInfo описывает клиента, от имени которого выполняется вход или обновление токенов
+type ClientInfo struct {
+	Device    string
+	UserAgent string
+	IP        string
+}
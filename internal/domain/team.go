@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"time"
+)
+
+// Роли участников команды
+const (
+	TeamRoleOwner    = "owner"    // Управляет составом команды, загружает и удаляет мангу команды
+	TeamRoleUploader = "uploader" // Загружает и редактирует мангу и главы команды
+	TeamRoleMember   = "member"   // Участник без прав на изменение манги
+)
+
+// Права участников команды
+const (
+	PermissionUpload        = "upload"         // Создание и изменение манги, глав и страниц
+	PermissionDeleteManga   = "delete_manga"   // Удаление манги команды
+	PermissionManageMembers = "manage_members" // Управление составом команды
+)
+
+// Team представляет команду переводчиков, которой принадлежит манга
+type Team struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TeamMember представляет участника команды
+type TeamMember struct {
+	TeamID   int       `json:"team_id"`
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// TeamInput представляет данные для создания или изменения команды
+type TeamInput struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+	OwnerID     int    `json:"owner_id"` // Владелец новой команды; при изменении команды не используется
+}
+
+// TeamMemberInput представляет роль участника команды
+type TeamMemberInput struct {
+	Role string `json:"role" binding:"required,oneof=owner uploader member"`
+}
+
+// UserTeam представляет команду пользователя вместе с его ролью в ней
+type UserTeam struct {
+	Team
+	Role string `json:"role"`
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// TOTP представляет настройки двухфакторной аутентификации пользователя
+type TOTP struct {
+	UserID    int        `json:"user_id"`
+	Secret    string     `json:"-"`
+	Enabled   bool       `json:"enabled"`
+	CreatedAt time.Time  `json:"created_at"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+}
+
+// TwoFactorStatus представляет состояние двухфакторной аутентификации пользователя
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"` // 2FA обязательна для роли пользователя
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorSetup представляет данные для добавления аккаунта в приложение-аутентификатор
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI для QR-кода
+}
+
+// TwoFactorLogin представляет данные для второго шага входа
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // Код из приложения или код восстановления
+}
@@ -6,14 +6,15 @@ import (
 
 // User представляет пользователя системы
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Не выводим в JSON
-	AvatarURL    string    `json:"avatar_url,omitempty"`
-	Role         string    `json:"role"` // user, moderator, admin
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	PasswordHash  string    `json:"-"` // Не выводим в JSON
+	AvatarURL     string    `json:"avatar_url,omitempty"`
+	Role          string    `json:"role"` // user, moderator, admin
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // Bookmark представляет закладку пользователя
@@ -24,6 +25,18 @@ type Bookmark struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// BookmarkedManga представляет мангу из закладок пользователя
+type BookmarkedManga struct {
+	Manga
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// BookmarkPage представляет страницу закладок пользователя, от новых к старым
+type BookmarkPage struct {
+	Bookmarks  []BookmarkedManga `json:"bookmarks"`
+	NextCursor string            `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+}
+
 // ReadHistory представляет историю чтения
 type ReadHistory struct {
 	ID        int       `json:"id"`
@@ -34,6 +47,45 @@ type ReadHistory struct {
 	ReadAt    time.Time `json:"read_at"`
 }
 
+// ReadHistoryPage представляет страницу истории чтения, от последних прочитанных глав к ранним
+type ReadHistoryPage struct {
+	History    []ReadHistory `json:"history"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Пусто, если страниц больше нет
+}
+
+// ChapterReadState представляет главу манги вместе с прогрессом чтения пользователя
+type ChapterReadState struct {
+	MangaID       int        `json:"manga_id"`
+	MangaTitle    string     `json:"manga_title"`
+	CoverURL      string     `json:"cover_url,omitempty"`
+	ChapterID     int        `json:"chapter_id"`
+	ChapterNumber float64    `json:"chapter_number"`
+	ChapterTitle  string     `json:"chapter_title"`
+	PageCount     int        `json:"page_count"`
+	Page          *int       `json:"page,omitempty"`    // nil, если глава не открывалась
+	ReadAt        *time.Time `json:"read_at,omitempty"` // nil, если глава не открывалась
+}
+
+// ChapterRef представляет краткую информацию о главе
+type ChapterRef struct {
+	ID        int     `json:"id"`
+	Number    float64 `json:"number"`
+	Title     string  `json:"title"`
+	PageCount int     `json:"page_count"`
+}
+
+// ContinueReading представляет позицию, с которой пользователь может продолжить чтение манги
+type ContinueReading struct {
+	MangaID     int         `json:"manga_id"`
+	MangaTitle  string      `json:"manga_title"`
+	CoverURL    string      `json:"cover_url,omitempty"`
+	LastChapter ChapterRef  `json:"last_chapter"`
+	LastPage    int         `json:"last_page"`
+	ReadAt      time.Time   `json:"read_at"`
+	NextChapter *ChapterRef `json:"next_chapter,omitempty"` // nil, если непрочитанных глав нет
+	UnreadCount int         `json:"unread_count"`
+}
+
 // UserSignup представляет данные для регистрации пользователя
 type UserSignup struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
@@ -45,12 +97,27 @@ type UserSignup struct {
 type UserLogin struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device,omitempty" binding:"max=100"` // Название устройства; по умолчанию определяется по User-Agent
 }
 
-// TokenResponse представляет ответ с токеном доступа
+// TokenResponse представляет ответ с токеном доступа.
+// Если у пользователя включена двухфакторная аутентификация, вход возвращает только ChallengeToken,
+// который вместе с кодом обменивается на токены через /api/auth/login/2fa.
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token,omitempty"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int64  `json:"expires_in"`
+	AccessToken       string `json:"access_token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	TokenType         string `json:"token_type,omitempty"`
+	ExpiresIn         int64  `json:"expires_in"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+// UserIdentity представляет внешнюю учетную запись (OpenID Connect), привязанную к пользователю
+type UserIdentity struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
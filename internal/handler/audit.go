@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler обрабатывает HTTP-запросы к журналу аудита
+type AuditHandler struct {
+	auditService AuditService
+	middleware   *Middleware
+	logger       *slog.Logger
+}
+
+// AuditService интерфейс сервиса журнала аудита
+type AuditService interface {
+	List(ctx context.Context, filter domain.AuditFilter, cursor string, limit int) (domain.AuditPage, error)
+}
+
+// NewAuditHandler создает новый экземпляр AuditHandler
+func NewAuditHandler(auditService AuditService, middleware *Middleware, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		middleware:   middleware,
+		logger:       logger,
+	}
+}
+
+// Register регистрирует обработчики путей для журнала аудита
+func (h *AuditHandler) Register(router *gin.RouterGroup) {
+	router.GET("/admin/audit", h.getAuditLog)
+}
+
+// getAuditLog возвращает записи журнала аудита
+// @Summary Получить журнал аудита
+// @Description Возвращает записи журнала аудита от новых к старым с курсорной пагинацией (только для администраторов)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param actor_id query int false "ID пользователя, выполнившего действие"
+// @Param entity_type query string false "Тип объекта: manga, chapter, page, user, comment, team"
+// @Param entity_id query int false "ID объекта"
+// @Param from query string false "Начало периода (RFC 3339, включительно)"
+// @Param to query string false "Конец периода (RFC 3339, не включительно)"
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество записей (по умолчанию 50, максимум 200)"
+// @Success 200 {object} domain.AuditPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/admin/audit [get]
+func (h *AuditHandler) getAuditLog(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.auditService.List(c.Request.Context(), filter, c.Query("cursor"), limit)
+	if err != nil {
+		h.logger.Error("failed to get audit log", "error", err)
+		if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// auditFilter разбирает параметры выборки журнала аудита из запроса
+func auditFilter(c *gin.Context) (domain.AuditFilter, error) {
+	filter := domain.AuditFilter{EntityType: c.Query("entity_type")}
+
+	if value := c.Query("actor_id"); value != "" {
+		actorID, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.AuditFilter{}, errors.New("invalid actor_id parameter")
+		}
+		filter.ActorID = &actorID
+	}
+
+	if value := c.Query("entity_id"); value != "" {
+		entityID, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.AuditFilter{}, errors.New("invalid entity_id parameter")
+		}
+		filter.EntityID = &entityID
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return domain.AuditFilter{}, errors.New("invalid from parameter")
+		}
+		filter.From = &from
+	}
+
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return domain.AuditFilter{}, errors.New("invalid to parameter")
+		}
+		filter.To = &to
+	}
+
+	return filter, nil
+}
@@ -2,11 +2,14 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/jwtkeys"
 	"github.com/LirikaOne-Back/manga-reader3/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -14,23 +17,51 @@ import (
 // AuthHandler обрабатывает HTTP-запросы, связанные с аутентификацией
 type AuthHandler struct {
 	authService AuthService
+	middleware  *Middleware
 	logger      *slog.Logger
 }
 
 // AuthService интерфейс сервиса аутентификации
 type AuthService interface {
 	Register(ctx context.Context, input domain.UserSignup) (domain.User, error)
-	Login(ctx context.Context, input domain.UserLogin) (domain.TokenResponse, error)
-	RefreshToken(ctx context.Context, refreshToken string) (domain.TokenResponse, error)
+	Login(ctx context.Context, input domain.UserLogin, client domain.ClientInfo) (domain.TokenResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string, client domain.ClientInfo) (domain.TokenResponse, error)
 	Logout(ctx context.Context, userID int) error
+	SessionService
 	ChangePassword(ctx context.Context, userID int, oldPassword, newPassword string) error
+	SendVerificationEmail(ctx context.Context, userID int) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	OIDCProviders() []string
+	StartOIDCLogin(ctx context.Context, providerName string) (string, error)
+	CompleteOIDCLogin(ctx context.Context, providerName, state, code string, client domain.ClientInfo) (domain.TokenResponse, error)
+	TwoFactorService
 	ValidateToken(tokenString string) (*service.JWTClaims, error)
+	JWKS() jwtkeys.JWKS
+}
+
+// SessionService интерфейс управления сессиями пользователей
+type SessionService interface {
+	ListSessions(ctx context.Context, userID int) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string) error
+}
+
+// TwoFactorService интерфейс двухфакторной аутентификации
+type TwoFactorService interface {
+	TwoFactorStatus(ctx context.Context, userID int) (domain.TwoFactorStatus, error)
+	SetupTwoFactor(ctx context.Context, userID int) (domain.TwoFactorSetup, error)
+	EnableTwoFactor(ctx context.Context, userID int, sessionID, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID int, password, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error)
+	CompleteTwoFactorLogin(ctx context.Context, input domain.TwoFactorLogin) (domain.TokenResponse, error)
 }
 
 // NewAuthHandler создает новый экземпляр AuthHandler
-func NewAuthHandler(authService AuthService, logger *slog.Logger) *AuthHandler {
+func NewAuthHandler(authService AuthService, middleware *Middleware, logger *slog.Logger) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		middleware:  middleware,
 		logger:      logger,
 	}
 }
@@ -39,12 +70,34 @@ func NewAuthHandler(authService AuthService, logger *slog.Logger) *AuthHandler {
 func (h *AuthHandler) Register(router *gin.RouterGroup) {
 	auth := router.Group("/auth")
 	{
-		auth.POST("/signup", h.signup)
-		auth.POST("/login", h.login)
-		auth.POST("/refresh", h.refresh)
-		auth.POST("/logout", h.authMiddleware(), h.logout)
-		auth.PUT("/password", h.authMiddleware(), h.changePassword)
-		auth.GET("/me", h.authMiddleware(), h.getMe)
+		// Ограничение частоты для входа, регистрации и действий с паролями и кодами
+		limit := h.middleware.RateLimit("auth")
+
+		auth.POST("/signup", limit, h.signup)
+		auth.POST("/login", limit, h.login)
+		auth.POST("/login/2fa", limit, h.loginTwoFactor)
+		auth.POST("/refresh", limit, h.refresh)
+		auth.GET("/oidc", h.getOIDCProviders)
+		auth.GET("/oidc/:provider/start", limit, h.oidcStart)
+		auth.GET("/oidc/:provider/callback", limit, h.oidcCallback)
+		auth.POST("/logout", h.logout)
+		auth.PUT("/password", limit, h.changePassword)
+		auth.POST("/password/forgot", limit, h.forgotPassword)
+		auth.POST("/password/reset", limit, h.resetPassword)
+		auth.POST("/email/verify", limit, h.verifyEmail)
+		auth.POST("/email/resend", limit, h.resendVerificationEmail)
+		auth.GET("/me", h.getMe)
+		auth.GET("/sessions", h.getSessions)
+		auth.DELETE("/sessions/:id", h.revokeSession)
+
+		twoFactor := auth.Group("/2fa")
+		{
+			twoFactor.GET("", h.getTwoFactorStatus)
+			twoFactor.POST("/setup", h.setupTwoFactor)
+			twoFactor.POST("/enable", limit, h.enableTwoFactor)
+			twoFactor.POST("/disable", limit, h.disableTwoFactor)
+			twoFactor.POST("/recovery-codes", limit, h.regenerateRecoveryCodes)
+		}
 	}
 }
 
@@ -89,13 +142,13 @@ func (h *AuthHandler) signup(c *gin.Context) {
 		"id":       user.ID,
 		"username": user.Username,
 		"email":    user.Email,
-		"message":  "User registered successfully",
+		"message":  "User registered successfully, check your email to confirm the address",
 	})
 }
 
 // login обработчик для входа пользователя
 // @Summary Вход пользователя
-// @Description Аутентифицирует пользователя и возвращает токены
+// @Description Аутентифицирует пользователя и возвращает токены. Если включена 2FA, возвращает challenge_token для /api/auth/login/2fa
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -103,6 +156,7 @@ func (h *AuthHandler) signup(c *gin.Context) {
 // @Success 200 {object} domain.TokenResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse "Слишком много неудачных попыток, время ожидания в заголовке Retry-After"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/auth/login [post]
 func (h *AuthHandler) login(c *gin.Context) {
@@ -113,9 +167,17 @@ func (h *AuthHandler) login(c *gin.Context) {
 		return
 	}
 
-	tokenResponse, err := h.authService.Login(c.Request.Context(), input)
+	tokenResponse, err := h.authService.Login(c.Request.Context(), input, clientInfo(c, input.Device))
 	if err != nil {
 		h.logger.Error("login failed", "error", err)
+
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(throttled.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, ErrorResponse{Message: "Too many failed login attempts, try again later"})
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid username or password"})
 		return
 	}
@@ -123,6 +185,132 @@ func (h *AuthHandler) login(c *gin.Context) {
 	c.JSON(http.StatusOK, tokenResponse)
 }
 
+// getOIDCProviders обработчик для получения списка внешних провайдеров входа
+// @Summary Внешние провайдеры входа
+// @Description Возвращает имена настроенных провайдеров OpenID Connect
+// @Tags auth
+// @Produce json
+// @Success 200 {array} string
+// @Router /api/auth/oidc [get]
+func (h *AuthHandler) getOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, h.authService.OIDCProviders())
+}
+
+// oidcStart обработчик для начала входа через внешнего провайдера
+// @Summary Начало входа через провайдера OpenID Connect
+// @Description Перенаправляет на страницу входа провайдера (authorization code flow с PKCE)
+// @Tags auth
+// @Param provider path string true "Имя провайдера"
+// @Success 302
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/oidc/{provider}/start [get]
+func (h *AuthHandler) oidcStart(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, err := h.authService.StartOIDCLogin(c.Request.Context(), provider)
+	if err != nil {
+		h.logger.Error("failed to start oidc login", "provider", provider, "error", err)
+
+		if strings.Contains(err.Error(), "provider not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Identity provider not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to start external login"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// oidcCallback обработчик для завершения входа через внешнего провайдера
+// @Summary Завершение входа через провайдера OpenID Connect
+// @Description Принимает ответ провайдера, находит или создает пользователя, привязанного к внешней учетной записи, и возвращает токены
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Имя провайдера"
+// @Param code query string true "Код авторизации"
+// @Param state query string true "Значение state из запроса на вход"
+// @Success 200 {object} domain.TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/oidc/{provider}/callback [get]
+func (h *AuthHandler) oidcCallback(c *gin.Context) {
+	provider := c.Param("provider")
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		h.logger.Warn("oidc provider returned error", "provider", provider, "error", errorCode,
+			"description", c.Query("error_description"))
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "External login failed: " + errorCode})
+		return
+	}
+
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Missing code or state"})
+		return
+	}
+
+	tokenResponse, err := h.authService.CompleteOIDCLogin(c.Request.Context(), provider, state, code, clientInfo(c, ""))
+	if err != nil {
+		h.logger.Error("oidc login failed", "provider", provider, "error", err)
+
+		switch {
+		case strings.Contains(err.Error(), "provider not found"):
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Identity provider not found"})
+		case strings.Contains(err.Error(), "login state"):
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid or expired login state"})
+		case strings.Contains(err.Error(), "email already exists"):
+			c.JSON(http.StatusConflict, ErrorResponse{Message: "An account with this email already exists, sign in with password to continue"})
+		case strings.Contains(err.Error(), "external login failed"), strings.Contains(err.Error(), "did not return an email"):
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "External login failed"})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to complete external login"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse)
+}
+
+// loginTwoFactor обработчик для второго шага входа
+// @Summary Второй шаг входа
+// @Description Обменивает challenge_token из ответа /api/auth/login и код из приложения-аутентификатора или код восстановления на токены
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body domain.TwoFactorLogin true "Challenge и код"
+// @Success 200 {object} domain.TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/login/2fa [post]
+func (h *AuthHandler) loginTwoFactor(c *gin.Context) {
+	var input domain.TwoFactorLogin
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid two-factor login data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid two-factor login data: " + err.Error()})
+		return
+	}
+
+	tokenResponse, err := h.authService.CompleteTwoFactorLogin(c.Request.Context(), input)
+	if err != nil {
+		h.logger.Error("two-factor login failed", "error", err)
+		if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid code or expired challenge"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to complete login"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse)
+}
+
 // refresh обработчик для обновления токенов
 // @Summary Обновление токенов
 // @Description Обновляет токены по refresh-токену
@@ -145,9 +333,13 @@ func (h *AuthHandler) refresh(c *gin.Context) {
 		return
 	}
 
-	tokenResponse, err := h.authService.RefreshToken(c.Request.Context(), input.RefreshToken)
+	tokenResponse, err := h.authService.RefreshToken(c.Request.Context(), input.RefreshToken, clientInfo(c, ""))
 	if err != nil {
 		h.logger.Error("token refresh failed", "error", err)
+		if strings.Contains(err.Error(), "failed to") {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to refresh token"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid refresh token"})
 		return
 	}
@@ -183,6 +375,62 @@ func (h *AuthHandler) logout(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// getSessions обработчик для получения активных сессий пользователя
+// @Summary Активные сессии
+// @Description Возвращает устройства, с которых выполнен вход; текущая сессия отмечена полем current
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.Session
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/sessions [get]
+func (h *AuthHandler) getSessions(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get sessions", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get sessions"})
+		return
+	}
+
+	currentSessionID := c.GetString("session_id")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// revokeSession обработчик для завершения сессии
+// @Summary Завершение сессии
+// @Description Отзывает refresh-токены сессии, выполняя выход на одном устройстве
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param id path string true "ID сессии"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/sessions/{id} [delete]
+func (h *AuthHandler) revokeSession(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	revokeSession(c, h.authService, h.logger, userID, c.Param("id"))
+}
+
 // changePassword обработчик для изменения пароля
 // @Summary Изменение пароля
 // @Description Изменяет пароль пользователя
@@ -229,6 +477,354 @@ func (h *AuthHandler) changePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
+// forgotPassword обработчик для запроса сброса пароля
+// @Summary Запрос сброса пароля
+// @Description Отправляет на email письмо со ссылкой для сброса пароля. Ответ не зависит от того, зарегистрирован ли адрес
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Email в формате {\"email\": \"user@example.com\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/password/forgot [post]
+func (h *AuthHandler) forgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid password reset request", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid email: " + err.Error()})
+		return
+	}
+
+	err := h.authService.RequestPasswordReset(c.Request.Context(), input.Email)
+	if err != nil {
+		h.logger.Error("password reset request failed", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to send password reset email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// resetPassword обработчик для установки нового пароля по ссылке из письма
+// @Summary Сброс пароля
+// @Description Устанавливает новый пароль по токену из письма и завершает все сессии пользователя
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Токен и новый пароль в формате {\"token\": \"token\", \"new_password\": \"new\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/password/reset [post]
+func (h *AuthHandler) resetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid password reset data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid password data: " + err.Error()})
+		return
+	}
+
+	err := h.authService.ResetPassword(c.Request.Context(), input.Token, input.NewPassword)
+	if err != nil {
+		h.logger.Error("password reset failed", "error", err)
+
+		if strings.Contains(err.Error(), "invalid or expired token") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid or expired token"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// verifyEmail обработчик для подтверждения email по ссылке из письма
+// @Summary Подтверждение email
+// @Description Подтверждает email по токену из письма. Чтобы снять ограничения, нужно обновить токены через /api/auth/refresh
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Токен в формате {\"token\": \"token\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/auth/email/verify [post]
+func (h *AuthHandler) verifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid email verification data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid verification data: " + err.Error()})
+		return
+	}
+
+	err := h.authService.VerifyEmail(c.Request.Context(), input.Token)
+	if err != nil {
+		h.logger.Error("email verification failed", "error", err)
+
+		if strings.Contains(err.Error(), "invalid or expired token") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid or expired token"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// resendVerificationEmail обработчик для повторной отправки письма подтверждения
+// @Summary Повторная отправка письма подтверждения
+// @Description Отправляет новое письмо для подтверждения email; ссылка из предыдущего письма перестает действовать
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/email/resend [post]
+func (h *AuthHandler) resendVerificationEmail(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	err := h.authService.SendVerificationEmail(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to resend verification email", "user_id", userID, "error", err)
+
+		if strings.Contains(err.Error(), "already verified") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Email already verified"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// getTwoFactorStatus обработчик для получения состояния 2FA
+// @Summary Состояние двухфакторной аутентификации
+// @Description Возвращает, включена ли 2FA, обязательна ли она для роли пользователя и сколько осталось кодов восстановления
+// @Tags auth
+// @Produce json
+// @Success 200 {object} domain.TwoFactorStatus
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/2fa [get]
+func (h *AuthHandler) getTwoFactorStatus(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	status, err := h.authService.TwoFactorStatus(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get two-factor status", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// setupTwoFactor обработчик для начала настройки 2FA
+// @Summary Настройка двухфакторной аутентификации
+// @Description Создает секрет TOTP и otpauth URI для QR-кода. 2FA включается после подтверждения кодом через /api/auth/2fa/enable
+// @Tags auth
+// @Produce json
+// @Success 200 {object} domain.TwoFactorSetup
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/2fa/setup [post]
+func (h *AuthHandler) setupTwoFactor(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	setup, err := h.authService.SetupTwoFactor(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to set up two-factor authentication", "user_id", userID, "error", err)
+		h.writeTwoFactorError(c, err, "Failed to set up two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// enableTwoFactor обработчик для включения 2FA
+// @Summary Включение двухфакторной аутентификации
+// @Description Подтверждает секрет кодом из приложения, включает 2FA и возвращает коды восстановления. Остальные сессии завершаются
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Код в формате {\"code\": \"123456\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/2fa/enable [post]
+func (h *AuthHandler) enableTwoFactor(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid two-factor code data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid code data: " + err.Error()})
+		return
+	}
+
+	codes, err := h.authService.EnableTwoFactor(c.Request.Context(), userID, c.GetString("session_id"), input.Code)
+	if err != nil {
+		h.logger.Error("failed to enable two-factor authentication", "user_id", userID, "error", err)
+		h.writeTwoFactorError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+		"message":        "Two-factor authentication enabled, refresh tokens to apply it to the current session",
+	})
+}
+
+// disableTwoFactor обработчик для отключения 2FA
+// @Summary Отключение двухфакторной аутентификации
+// @Description Отключает 2FA после проверки пароля и кода из приложения или кода восстановления. Недоступно, если 2FA обязательна для роли
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Пароль и код в формате {\"password\": \"password\", \"code\": \"123456\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/2fa/disable [post]
+func (h *AuthHandler) disableTwoFactor(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid two-factor disable data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid data: " + err.Error()})
+		return
+	}
+
+	err := h.authService.DisableTwoFactor(c.Request.Context(), userID, input.Password, input.Code)
+	if err != nil {
+		h.logger.Error("failed to disable two-factor authentication", "user_id", userID, "error", err)
+		h.writeTwoFactorError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// regenerateRecoveryCodes обработчик для замены кодов восстановления
+// @Summary Новые коды восстановления
+// @Description Заменяет коды восстановления новыми после проверки кода из приложения
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param input body map[string]string true "Код в формате {\"code\": \"123456\"}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/auth/2fa/recovery-codes [post]
+func (h *AuthHandler) regenerateRecoveryCodes(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid two-factor code data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid code data: " + err.Error()})
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), userID, input.Code)
+	if err != nil {
+		h.logger.Error("failed to regenerate recovery codes", "user_id", userID, "error", err)
+		h.writeTwoFactorError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// writeTwoFactorError пишет ответ для ошибки управления 2FA
+func (h *AuthHandler) writeTwoFactorError(c *gin.Context, err error, message string) {
+	switch {
+	case strings.Contains(err.Error(), "invalid two-factor code"), strings.Contains(err.Error(), "invalid password"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid password or code"})
+	case strings.Contains(err.Error(), "mandatory"):
+		c.JSON(http.StatusForbidden, ErrorResponse{Message: "Two-factor authentication is mandatory for your role"})
+	case strings.Contains(err.Error(), "already enabled"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Two-factor authentication already enabled"})
+	case strings.Contains(err.Error(), "not enabled"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Two-factor authentication not enabled"})
+	case strings.Contains(err.Error(), "setup not started"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Two-factor setup not started"})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: message})
+	}
+}
+
+// jwks обработчик для публикации открытых ключей подписи токенов
+// @Summary Открытые ключи JWT
+// @Description Возвращает открытые ключи (JWKS), которыми другие сервисы проверяют access-токены. Ключ подписи указан в заголовке kid токена
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwtkeys.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
 // getMe обработчик для получения информации о текущем пользователе
 // @Summary Информация о текущем пользователе
 // @Description Возвращает информацию о текущем аутентифицированном пользователе
@@ -250,44 +846,29 @@ func (h *AuthHandler) getMe(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// authMiddleware middleware для проверки аутентификации
-func (h *AuthHandler) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Authorization header is required"})
-			c.Abort()
-			return
-		}
-
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid authorization header format"})
-			c.Abort()
-			return
-		}
-
-		tokenString := headerParts[1]
-		claims, err := h.authService.ValidateToken(tokenString)
-		if err != nil {
-			h.logger.Warn("invalid token", "error", err)
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid or expired token"})
-			c.Abort()
+// revokeSession завершает сессию пользователя и пишет ответ
+func revokeSession(c *gin.Context, sessions SessionService, logger *slog.Logger, userID int, sessionID string) {
+	err := sessions.RevokeSession(c.Request.Context(), userID, sessionID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Session not found"})
 			return
 		}
 
-		// Добавляем информацию о пользователе в контекст
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("user_role", claims.Role)
+		logger.Error("failed to revoke session", "user_id", userID, "session", sessionID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to revoke session"})
+		return
+	}
 
-		// Также можно добавить полную информацию о пользователе, если это требуется
-		// user, err := h.userService.GetByID(c.Request.Context(), claims.UserID)
-		// if err == nil {
-		//     c.Set("user", user)
-		// }
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
 
-		c.Next()
+// clientInfo собирает сведения о клиенте для сессии
+func clientInfo(c *gin.Context, device string) domain.ClientInfo {
+	return domain.ClientInfo{
+		Device:    device,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
 	}
 }
 
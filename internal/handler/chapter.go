@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/gin-gonic/gin"
@@ -14,25 +18,29 @@ import (
 // ChapterHandler обрабатывает HTTP-запросы, связанные с главами манги
 type ChapterHandler struct {
 	chapterService ChapterService
+	middleware     *Middleware
 	logger         *slog.Logger
 }
 
 // ChapterService интерфейс сервиса глав
 type ChapterService interface {
-	GetByMangaID(ctx context.Context, mangaID int) ([]domain.Chapter, error)
+	GetByMangaID(ctx context.Context, mangaID int, cursor string, limit int) (domain.ChapterPage, error)
 	GetByID(ctx context.Context, id int) (domain.Chapter, error)
-	Create(ctx context.Context, chapter domain.Chapter) (int, error)
-	Update(ctx context.Context, chapter domain.Chapter) error
-	Delete(ctx context.Context, id int) error
+	Create(ctx context.Context, userID int, admin bool, chapter domain.Chapter) (int, error)
+	Update(ctx context.Context, userID int, admin bool, chapter domain.Chapter) error
+	Delete(ctx context.Context, userID int, admin bool, id int) error
 	GetPages(ctx context.Context, chapterID int) ([]domain.Page, error)
-	AddPage(ctx context.Context, page domain.Page, imageData []byte) (int, error)
-	DeletePage(ctx context.Context, id int) error
+	AddPage(ctx context.Context, userID int, admin bool, page domain.Page, imageData []byte) (int, error)
+	AddPagesFromArchive(ctx context.Context, userID int, admin bool, chapterID int, archiveData []byte) ([]int, error)
+	DeletePage(ctx context.Context, userID int, admin bool, id int) error
+	GetPageImage(ctx context.Context, pageID int, variant, format string) (domain.PageImage, error)
 }
 
 // NewChapterHandler создает новый экземпляр ChapterHandler
-func NewChapterHandler(chapterService ChapterService, logger *slog.Logger) *ChapterHandler {
+func NewChapterHandler(chapterService ChapterService, middleware *Middleware, logger *slog.Logger) *ChapterHandler {
 	return &ChapterHandler{
 		chapterService: chapterService,
+		middleware:     middleware,
 		logger:         logger,
 	}
 }
@@ -43,25 +51,33 @@ func (h *ChapterHandler) Register(router *gin.RouterGroup) {
 	{
 		chapters.GET("/manga/:manga_id", h.getChaptersByManga)
 		chapters.GET("/:id", h.getChapterByID)
-		chapters.POST("", h.authMiddleware("moderator"), h.createChapter)
-		chapters.PUT("/:id", h.authMiddleware("moderator"), h.updateChapter)
-		chapters.DELETE("/:id", h.authMiddleware("moderator"), h.deleteChapter)
+		chapters.POST("", h.createChapter)
+		chapters.PUT("/:id", h.updateChapter)
+		chapters.DELETE("/:id", h.deleteChapter)
 
 		// Пути для работы со страницами
 		chapters.GET("/:id/pages", h.getChapterPages)
-		chapters.POST("/:id/pages", h.authMiddleware("moderator"), h.addChapterPage)
-		chapters.DELETE("/pages/:page_id", h.authMiddleware("moderator"), h.deleteChapterPage)
+		chapters.POST("/:id/pages", h.middleware.RateLimit("upload"), h.middleware.Timeout("upload"), h.addChapterPage)
+		chapters.POST("/:id/archive", h.middleware.RateLimit("upload"), h.middleware.Timeout("upload"), h.uploadChapterArchive)
+		chapters.DELETE("/pages/:page_id", h.deleteChapterPage)
+	}
+
+	pages := router.Group("/pages")
+	{
+		pages.GET("/:id/image", h.getPageImage)
 	}
 }
 
 // getChaptersByManga возвращает список глав для указанной манги
 // @Summary Получить главы манги
-// @Description Возвращает список глав для указанной манги
+// @Description Возвращает главы указанной манги по возрастанию номера с курсорной пагинацией
 // @Tags chapters
 // @Accept json
 // @Produce json
 // @Param manga_id path int true "ID манги"
-// @Success 200 {array} domain.Chapter
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество глав (по умолчанию 100, максимум 500)"
+// @Success 200 {object} domain.ChapterPage
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -74,19 +90,20 @@ func (h *ChapterHandler) getChaptersByManga(c *gin.Context) {
 		return
 	}
 
-	chapters, err := h.chapterService.GetByMangaID(c.Request.Context(), mangaID)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.chapterService.GetByMangaID(c.Request.Context(), mangaID, c.Query("cursor"), limit)
 	if err != nil {
 		h.logger.Error("failed to get chapters", "manga_id", mangaID, "error", err)
+		if strings.Contains(err.Error(), "invalid cursor") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get chapters: " + err.Error()})
 		return
 	}
 
-	if len(chapters) == 0 {
-		c.JSON(http.StatusOK, []domain.Chapter{})
-		return
-	}
-
-	c.JSON(http.StatusOK, chapters)
+	c.JSON(http.StatusOK, page)
 }
 
 // getChapterByID возвращает главу по ID
@@ -141,9 +158,21 @@ func (h *ChapterHandler) createChapter(c *gin.Context) {
 		return
 	}
 
-	id, err := h.chapterService.Create(c.Request.Context(), chapter)
+	userID, admin := editor(c)
+
+	id, err := h.chapterService.Create(c.Request.Context(), userID, admin, chapter)
 	if err != nil {
 		h.logger.Error("failed to create chapter", "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create chapter: " + err.Error()})
 		return
 	}
@@ -188,9 +217,21 @@ func (h *ChapterHandler) updateChapter(c *gin.Context) {
 	// Устанавливаем ID из URL
 	chapter.ID = id
 
-	err = h.chapterService.Update(c.Request.Context(), chapter)
+	userID, admin := editor(c)
+
+	err = h.chapterService.Update(c.Request.Context(), userID, admin, chapter)
 	if err != nil {
 		h.logger.Error("failed to update chapter", "id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update chapter: " + err.Error()})
 		return
 	}
@@ -223,9 +264,21 @@ func (h *ChapterHandler) deleteChapter(c *gin.Context) {
 		return
 	}
 
-	err = h.chapterService.Delete(c.Request.Context(), id)
+	userID, admin := editor(c)
+
+	err = h.chapterService.Delete(c.Request.Context(), userID, admin, id)
 	if err != nil {
 		h.logger.Error("failed to delete chapter", "id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete chapter: " + err.Error()})
 		return
 	}
@@ -353,9 +406,26 @@ func (h *ChapterHandler) addChapterPage(c *gin.Context) {
 		Number:    number,
 	}
 
-	id, err := h.chapterService.AddPage(c.Request.Context(), page, imageData)
+	userID, admin := editor(c)
+
+	id, err := h.chapterService.AddPage(c.Request.Context(), userID, admin, page, imageData)
 	if err != nil {
 		h.logger.Error("failed to add page", "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if strings.Contains(err.Error(), "invalid image") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid image: " + err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to add page: " + err.Error()})
 		return
 	}
@@ -366,6 +436,100 @@ func (h *ChapterHandler) addChapterPage(c *gin.Context) {
 	})
 }
 
+// uploadChapterArchive добавляет в главу страницы из CBZ/ZIP архива
+// @Summary Загрузить архив страниц
+// @Description Добавляет в конец главы все изображения из CBZ/ZIP архива в естественном порядке имен файлов
+// @Tags chapters
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID главы"
+// @Param archive formData file true "CBZ/ZIP архив со страницами"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/chapters/{id}/archive [post]
+func (h *ChapterHandler) uploadChapterArchive(c *gin.Context) {
+	chapterID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid chapter id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid chapter ID format"})
+		return
+	}
+
+	// Получаем архив
+	file, err := c.FormFile("archive")
+	if err != nil {
+		h.logger.Error("failed to get archive file", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Failed to get archive file: " + err.Error()})
+		return
+	}
+
+	// Ограничиваем размер архива (например, до 200 МБ)
+	if file.Size > 200*1024*1024 {
+		h.logger.Error("archive too large", "size", file.Size)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Archive file is too large (max 200MB)"})
+		return
+	}
+
+	// Проверяем расширение файла
+	if !isAllowedArchiveName(file.Filename) {
+		h.logger.Error("invalid archive type", "filename", file.Filename)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid archive type. Allowed types: .cbz, .zip"})
+		return
+	}
+
+	// Открываем файл
+	src, err := file.Open()
+	if err != nil {
+		h.logger.Error("failed to open archive file", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to open archive file: " + err.Error()})
+		return
+	}
+	defer src.Close()
+
+	// Читаем данные архива
+	archiveData, err := io.ReadAll(src)
+	if err != nil {
+		h.logger.Error("failed to read archive data", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to read archive data: " + err.Error()})
+		return
+	}
+
+	userID, admin := editor(c)
+
+	ids, err := h.chapterService.AddPagesFromArchive(c.Request.Context(), userID, admin, chapterID, archiveData)
+	if err != nil {
+		h.logger.Error("failed to add pages from archive", "chapter_id", chapterID, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		if strings.Contains(err.Error(), "invalid archive") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid archive: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to add pages: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ids":     ids,
+		"count":   len(ids),
+		"message": "Pages added successfully",
+	})
+}
+
 // deleteChapterPage удаляет страницу по ID
 // @Summary Удалить страницу
 // @Description Удаляет страницу по её ID
@@ -389,9 +553,21 @@ func (h *ChapterHandler) deleteChapterPage(c *gin.Context) {
 		return
 	}
 
-	err = h.chapterService.DeletePage(c.Request.Context(), pageID)
+	userID, admin := editor(c)
+
+	err = h.chapterService.DeletePage(c.Request.Context(), userID, admin, pageID)
 	if err != nil {
 		h.logger.Error("failed to delete page", "id", pageID, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete page: " + err.Error()})
 		return
 	}
@@ -401,47 +577,63 @@ func (h *ChapterHandler) deleteChapterPage(c *gin.Context) {
 	})
 }
 
-// authMiddleware middleware для проверки роли пользователя
-func (h *ChapterHandler) authMiddleware(requiredRole string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Предполагается, что JWT middleware уже добавил информацию о пользователе в контекст
-		userRole, exists := c.Get("user_role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
-			c.Abort()
+// getPageImage возвращает изображение страницы в нужном варианте и формате
+// @Summary Получить изображение страницы
+// @Description Возвращает изображение страницы. Варианты (thumb, mobile, full) генерируются при первом запросе и кэшируются
+// @Tags chapters
+// @Produce image/jpeg,image/png,image/webp
+// @Param id path int true "ID страницы"
+// @Param variant query string false "Вариант изображения (thumb, mobile, full); по умолчанию исходное изображение"
+// @Param format query string false "Формат изображения (jpeg, webp, png); по умолчанию формат исходного изображения"
+// @Success 200 {file} file
+// @Success 304 "Not Modified"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/pages/{id}/image [get]
+func (h *ChapterHandler) getPageImage(c *gin.Context) {
+	pageID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid page id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid page ID format"})
+		return
+	}
+
+	variant := c.Query("variant")
+	format := strings.ToLower(c.Query("format"))
+	if format == "jpg" {
+		format = "jpeg"
+	}
+
+	image, err := h.chapterService.GetPageImage(c.Request.Context(), pageID, variant, format)
+	if err != nil {
+		h.logger.Error("failed to get page image", "id", pageID, "variant", variant, "format", format, "error", err)
+
+		if strings.Contains(err.Error(), "invalid variant") || strings.Contains(err.Error(), "invalid format") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 			return
 		}
-
-		// Проверяем роль пользователя
-		role := userRole.(string)
-		if !hasRequiredRole(role, requiredRole) {
-			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Insufficient permissions"})
-			c.Abort()
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Page not found"})
 			return
 		}
 
-		c.Next()
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get page image: " + err.Error()})
+		return
 	}
-}
 
-// hasRequiredRole проверяет, имеет ли пользователь требуемую роль
-func hasRequiredRole(userRole, requiredRole string) bool {
-	// Администратор имеет все права
-	if userRole == "admin" {
-		return true
-	}
+	c.Header("Content-Type", image.ContentType)
+	c.Header("ETag", image.ETag)
+	c.Header("Cache-Control", "public, max-age=604800")
 
-	// Модератор имеет права модератора и пользователя
-	if userRole == "moderator" && (requiredRole == "moderator" || requiredRole == "user") {
-		return true
-	}
-
-	// Обычный пользователь имеет только права пользователя
-	if userRole == "user" && requiredRole == "user" {
-		return true
-	}
+	// ServeContent обрабатывает If-None-Match и Range
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(image.Data))
+}
 
-	return false
+// isAllowedArchiveName проверяет допустимое расширение архива
+func isAllowedArchiveName(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".cbz" || ext == ".zip"
 }
 
 // isAllowedImageType проверяет допустимый тип изображения
@@ -0,0 +1,528 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// CommentHandler обрабатывает HTTP-запросы, связанные с комментариями
+type CommentHandler struct {
+	commentService CommentService
+	logger         *slog.Logger
+	middleware     *Middleware
+}
+
+// CommentService интерфейс сервиса комментариев
+type CommentService interface {
+	MangaTarget(ctx context.Context, mangaID int) (domain.CommentTarget, error)
+	ChapterTarget(ctx context.Context, chapterID int) (domain.CommentTarget, error)
+	List(ctx context.Context, target domain.CommentTarget, cursor string, limit, viewerID int, moderator bool) (domain.CommentPage, error)
+	ListReplies(ctx context.Context, commentID int, cursor string, limit, viewerID int, moderator bool) (domain.CommentPage, error)
+	Create(ctx context.Context, target domain.CommentTarget, userID int, moderator bool, input domain.CommentInput) (domain.Comment, error)
+	Update(ctx context.Context, id, userID int, moderator bool, update domain.CommentUpdate) (domain.Comment, error)
+	Delete(ctx context.Context, id, userID int, moderator bool) error
+	SetHidden(ctx context.Context, id, moderatorID int, hidden bool) error
+	SetLocked(ctx context.Context, target domain.CommentTarget, moderatorID int, locked bool) error
+}
+
+// NewCommentHandler создает новый экземпляр CommentHandler
+func NewCommentHandler(commentService CommentService, middleware *Middleware, logger *slog.Logger) *CommentHandler {
+	return &CommentHandler{
+		commentService: commentService,
+		middleware:     middleware,
+		logger:         logger,
+	}
+}
+
+// Register регистрирует обработчики путей для комментариев
+func (h *CommentHandler) Register(router *gin.RouterGroup) {
+	// Ветка комментариев манги
+	manga := router.Group("/manga/:id/comments")
+	{
+		manga.GET("", h.getMangaComments)
+		manga.POST("", h.createMangaComment)
+		manga.PUT("/lock", h.lockMangaComments)
+		manga.DELETE("/lock", h.unlockMangaComments)
+	}
+
+	// Ветка комментариев главы
+	chapters := router.Group("/chapters/:id/comments")
+	{
+		chapters.GET("", h.getChapterComments)
+		chapters.POST("", h.createChapterComment)
+		chapters.PUT("/lock", h.lockChapterComments)
+		chapters.DELETE("/lock", h.unlockChapterComments)
+	}
+
+	comments := router.Group("/comments")
+	{
+		comments.GET("/:id/replies", h.getCommentReplies)
+		comments.PUT("/:id", h.updateComment)
+		comments.DELETE("/:id", h.deleteComment)
+
+		// Модерация
+		comments.PUT("/:id/hidden", h.setCommentHidden)
+	}
+}
+
+// getMangaComments возвращает комментарии к манге
+// @Summary Получить комментарии к манге
+// @Description Возвращает комментарии верхнего уровня к манге от новых к старым с курсорной пагинацией
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество комментариев (по умолчанию 20, максимум 100)"
+// @Success 200 {object} domain.CommentPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/manga/{id}/comments [get]
+func (h *CommentHandler) getMangaComments(c *gin.Context) {
+	target, ok := h.mangaTarget(c)
+	if !ok {
+		return
+	}
+	h.listComments(c, target)
+}
+
+// createMangaComment добавляет комментарий к манге
+// @Summary Добавить комментарий к манге
+// @Description Публикует комментарий к манге или ответ на комментарий (parent_id)
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Param comment body domain.CommentInput true "Комментарий"
+// @Success 201 {object} domain.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/comments [post]
+func (h *CommentHandler) createMangaComment(c *gin.Context) {
+	target, ok := h.mangaTarget(c)
+	if !ok {
+		return
+	}
+	h.createComment(c, target)
+}
+
+// lockMangaComments закрывает ветку комментариев манги
+// @Summary Закрыть комментарии к манге
+// @Description Запрещает новые комментарии и редактирование в ветке манги (кроме модераторов)
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/comments/lock [put]
+func (h *CommentHandler) lockMangaComments(c *gin.Context) {
+	target, ok := h.mangaTarget(c)
+	if !ok {
+		return
+	}
+	h.setLocked(c, target, true)
+}
+
+// unlockMangaComments открывает ветку комментариев манги
+// @Summary Открыть комментарии к манге
+// @Description Снова разрешает комментарии в ветке манги
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/comments/lock [delete]
+func (h *CommentHandler) unlockMangaComments(c *gin.Context) {
+	target, ok := h.mangaTarget(c)
+	if !ok {
+		return
+	}
+	h.setLocked(c, target, false)
+}
+
+// getChapterComments возвращает комментарии к главе
+// @Summary Получить комментарии к главе
+// @Description Возвращает комментарии верхнего уровня к главе от новых к старым с курсорной пагинацией
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID главы"
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество комментариев (по умолчанию 20, максимум 100)"
+// @Success 200 {object} domain.CommentPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/chapters/{id}/comments [get]
+func (h *CommentHandler) getChapterComments(c *gin.Context) {
+	target, ok := h.chapterTarget(c)
+	if !ok {
+		return
+	}
+	h.listComments(c, target)
+}
+
+// createChapterComment добавляет комментарий к главе
+// @Summary Добавить комментарий к главе
+// @Description Публикует комментарий к главе или ответ на комментарий (parent_id)
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID главы"
+// @Param comment body domain.CommentInput true "Комментарий"
+// @Success 201 {object} domain.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/chapters/{id}/comments [post]
+func (h *CommentHandler) createChapterComment(c *gin.Context) {
+	target, ok := h.chapterTarget(c)
+	if !ok {
+		return
+	}
+	h.createComment(c, target)
+}
+
+// lockChapterComments закрывает ветку комментариев главы
+// @Summary Закрыть комментарии к главе
+// @Description Запрещает новые комментарии и редактирование в ветке главы (кроме модераторов)
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID главы"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/chapters/{id}/comments/lock [put]
+func (h *CommentHandler) lockChapterComments(c *gin.Context) {
+	target, ok := h.chapterTarget(c)
+	if !ok {
+		return
+	}
+	h.setLocked(c, target, true)
+}
+
+// unlockChapterComments открывает ветку комментариев главы
+// @Summary Открыть комментарии к главе
+// @Description Снова разрешает комментарии в ветке главы
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID главы"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/chapters/{id}/comments/lock [delete]
+func (h *CommentHandler) unlockChapterComments(c *gin.Context) {
+	target, ok := h.chapterTarget(c)
+	if !ok {
+		return
+	}
+	h.setLocked(c, target, false)
+}
+
+// getCommentReplies возвращает ответы на комментарий
+// @Summary Получить ответы на комментарий
+// @Description Возвращает ответы на комментарий в порядке публикации с курсорной пагинацией
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID комментария"
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество ответов (по умолчанию 20, максимум 100)"
+// @Success 200 {object} domain.CommentPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/comments/{id}/replies [get]
+func (h *CommentHandler) getCommentReplies(c *gin.Context) {
+	id, ok := h.commentID(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	userID, moderator := h.viewer(c)
+
+	page, err := h.commentService.ListReplies(c.Request.Context(), id, c.Query("cursor"), limit, userID, moderator)
+	if err != nil {
+		h.logger.Error("failed to get comment replies", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to get comment replies")
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// updateComment редактирует комментарий
+// @Summary Редактировать комментарий
+// @Description Автор может редактировать комментарий в течение ограниченного времени после публикации
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID комментария"
+// @Param comment body domain.CommentUpdate true "Новый текст комментария"
+// @Success 200 {object} domain.Comment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/comments/{id} [put]
+func (h *CommentHandler) updateComment(c *gin.Context) {
+	id, ok := h.commentID(c)
+	if !ok {
+		return
+	}
+
+	var update domain.CommentUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		h.logger.Error("invalid comment data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid comment data: " + err.Error()})
+		return
+	}
+
+	userID, moderator := h.viewer(c)
+
+	comment, err := h.commentService.Update(c.Request.Context(), id, userID, moderator, update)
+	if err != nil {
+		h.logger.Error("failed to update comment", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to update comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// deleteComment удаляет комментарий
+// @Summary Удалить комментарий
+// @Description Мягко удаляет комментарий: ответы сохраняются, текст комментария больше не отображается. Доступно автору и модераторам
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID комментария"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/comments/{id} [delete]
+func (h *CommentHandler) deleteComment(c *gin.Context) {
+	id, ok := h.commentID(c)
+	if !ok {
+		return
+	}
+
+	userID, moderator := h.viewer(c)
+
+	if err := h.commentService.Delete(c.Request.Context(), id, userID, moderator); err != nil {
+		h.logger.Error("failed to delete comment", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// setCommentHidden скрывает комментарий или возвращает его в ленту
+// @Summary Скрыть комментарий
+// @Description Скрывает комментарий (hidden=true) или возвращает его в ленту (hidden=false). Доступно модераторам
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID комментария"
+// @Param hidden body object true "Флаг скрытия, например {\"hidden\": true}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/comments/{id}/hidden [put]
+func (h *CommentHandler) setCommentHidden(c *gin.Context) {
+	id, ok := h.commentID(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Hidden *bool `json:"hidden" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid hidden flag", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid data: " + err.Error()})
+		return
+	}
+
+	userID, _ := h.viewer(c)
+
+	if err := h.commentService.SetHidden(c.Request.Context(), id, userID, *input.Hidden); err != nil {
+		h.logger.Error("failed to change comment visibility", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to change comment visibility")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment visibility updated successfully"})
+}
+
+// listComments отдает страницу комментариев верхнего уровня ветки
+func (h *CommentHandler) listComments(c *gin.Context, target domain.CommentTarget) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	userID, moderator := h.viewer(c)
+
+	page, err := h.commentService.List(c.Request.Context(), target, c.Query("cursor"), limit, userID, moderator)
+	if err != nil {
+		h.logger.Error("failed to get comments", "manga_id", target.MangaID, "chapter_id", target.ChapterID, "error", err)
+		h.errorResponse(c, err, "Failed to get comments")
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// createComment публикует комментарий в ветке
+func (h *CommentHandler) createComment(c *gin.Context, target domain.CommentTarget) {
+	var input domain.CommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid comment data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid comment data: " + err.Error()})
+		return
+	}
+
+	userID, moderator := h.viewer(c)
+
+	comment, err := h.commentService.Create(c.Request.Context(), target, userID, moderator, input)
+	if err != nil {
+		h.logger.Error("failed to create comment", "manga_id", target.MangaID, "chapter_id", target.ChapterID, "error", err)
+		h.errorResponse(c, err, "Failed to create comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// setLocked закрывает или открывает ветку комментариев
+func (h *CommentHandler) setLocked(c *gin.Context, target domain.CommentTarget, locked bool) {
+	userID, _ := h.viewer(c)
+
+	if err := h.commentService.SetLocked(c.Request.Context(), target, userID, locked); err != nil {
+		h.logger.Error("failed to change comment thread lock", "manga_id", target.MangaID, "chapter_id", target.ChapterID, "error", err)
+		h.errorResponse(c, err, "Failed to change comment thread lock")
+		return
+	}
+
+	if locked {
+		c.JSON(http.StatusOK, gin.H{"message": "Comments locked successfully"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comments unlocked successfully"})
+}
+
+// mangaTarget возвращает ветку комментариев манги из параметра пути
+func (h *CommentHandler) mangaTarget(c *gin.Context) (domain.CommentTarget, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return domain.CommentTarget{}, false
+	}
+
+	target, err := h.commentService.MangaTarget(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get manga", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to get manga")
+		return domain.CommentTarget{}, false
+	}
+
+	return target, true
+}
+
+// chapterTarget возвращает ветку комментариев главы из параметра пути
+func (h *CommentHandler) chapterTarget(c *gin.Context) (domain.CommentTarget, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid chapter id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid chapter ID format"})
+		return domain.CommentTarget{}, false
+	}
+
+	target, err := h.commentService.ChapterTarget(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get chapter", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to get chapter")
+		return domain.CommentTarget{}, false
+	}
+
+	return target, true
+}
+
+// commentID возвращает ID комментария из параметра пути
+func (h *CommentHandler) commentID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid comment id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid comment ID format"})
+		return 0, false
+	}
+	return id, true
+}
+
+// viewer возвращает ID текущего пользователя (0 для анонимных запросов) и наличие прав модератора
+func (h *CommentHandler) viewer(c *gin.Context) (int, bool) {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(int)
+
+	return id, hasPrivilege(c, "moderator")
+}
+
+// errorResponse преобразует ошибку сервиса в HTTP-ответ
+func (h *CommentHandler) errorResponse(c *gin.Context, err error, message string) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case strings.Contains(err.Error(), "invalid comment"), strings.Contains(err.Error(), "invalid cursor"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	case strings.Contains(err.Error(), "permission denied"):
+		c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: message})
+	}
+}
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"bytes"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/LirikaOne-Back/manga-reader3/internal/storage"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -19,19 +23,25 @@ type Handler struct {
 	chapter    *ChapterHandler
 	auth       *AuthHandler
 	user       *UserHandler
+	comment    *CommentHandler
+	team       *TeamHandler
+	audit      *AuditHandler
 	middleware *Middleware
 }
 
 // NewHandler создает новый экземпляр Handler
-func NewHandler(services *service.Services, logger *slog.Logger) *Handler {
+func NewHandler(services *service.Services, cfg Config, logger *slog.Logger) *Handler {
 	// Инициализируем middleware
-	middleware := NewMiddleware(services.Auth, logger)
+	middleware := NewMiddleware(services.Auth, cfg, logger)
 
 	// Инициализируем обработчики
-	mangaHandler := NewMangaHandler(services.Manga, logger)
-	chapterHandler := NewChapterHandler(services.Chapter, logger)
-	authHandler := NewAuthHandler(services.Auth, logger)
-	userHandler := NewUserHandler(services.User, middleware, logger)
+	mangaHandler := NewMangaHandler(services.Manga, middleware, logger)
+	chapterHandler := NewChapterHandler(services.Chapter, middleware, logger)
+	authHandler := NewAuthHandler(services.Auth, middleware, logger)
+	userHandler := NewUserHandler(services.User, services.Auth, middleware, logger)
+	commentHandler := NewCommentHandler(services.Comment, middleware, logger)
+	teamHandler := NewTeamHandler(services.Team, middleware, logger)
+	auditHandler := NewAuditHandler(services.Audit, middleware, logger)
 
 	return &Handler{
 		services:   services,
@@ -40,20 +50,28 @@ func NewHandler(services *service.Services, logger *slog.Logger) *Handler {
 		chapter:    chapterHandler,
 		auth:       authHandler,
 		user:       userHandler,
+		comment:    commentHandler,
+		team:       teamHandler,
+		audit:      auditHandler,
 		middleware: middleware,
 	}
 }
 
-// Register регистрирует все обработчики HTTP
-func (h *Handler) Register(router *gin.Engine) {
+// Register регистрирует все обработчики HTTP.
+// Возвращает ошибку, если для какого-либо маршрута не задано правило доступа.
+func (h *Handler) Register(router *gin.Engine) error {
 	// Добавляем middleware
+	router.Use(h.middleware.RequestID())
 	router.Use(h.middleware.Logger())
 	router.Use(h.middleware.Recover())
 	router.Use(h.middleware.CORS())
 	router.Use(h.middleware.ContentTypeJSON())
+	router.Use(h.middleware.Authorize())
+	router.Use(h.middleware.Timeout("default"))
 
-	// Статические файлы для изображений
-	router.Static("/images", "./data/images")
+	// Изображения из хранилища
+	router.GET("/images/*key", h.serveImage)
+	router.HEAD("/images/*key", h.serveImage)
 
 	// Простой эндпоинт для проверки работоспособности
 	router.GET("/health", func(c *gin.Context) {
@@ -63,16 +81,45 @@ func (h *Handler) Register(router *gin.Engine) {
 		})
 	})
 
+	// Открытые ключи для проверки токенов другими сервисами
+	router.GET("/.well-known/jwks.json", h.auth.jwks)
+
 	// Группа API
-	api := router.Group("/api")
+	api := router.Group("/api", h.middleware.RateLimit("api"))
 	{
 		// Регистрируем обработчики
 		h.manga.Register(api)
 		h.chapter.Register(api)
 		h.auth.Register(api)
 		h.user.Register(api)
+		h.comment.Register(api)
+		h.team.Register(api)
+		h.audit.Register(api)
 	}
 
 	// Swagger
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return checkRoutePolicies(router.Routes())
+}
+
+// serveImage отдает изображение из хранилища. Изображения манги с водяным знаком,
+// накладываемым при генерации вариантов, доступны только через /api/pages/{id}/image.
+func (h *Handler) serveImage(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	img, err := h.services.Chapter.GetStoredImage(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Image not found"})
+			return
+		}
+		h.logger.Error("failed to get image", "key", key, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get image"})
+		return
+	}
+
+	c.Header("Content-Type", img.ContentType)
+	c.Header("Cache-Control", "public, max-age=86400")
+	http.ServeContent(c.Writer, c.Request, key, time.Time{}, bytes.NewReader(img.Data))
 }
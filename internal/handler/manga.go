@@ -3,9 +3,13 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/gin-gonic/gin"
@@ -15,22 +19,32 @@ import (
 type MangaHandler struct {
 	mangaService MangaService
 	logger       *slog.Logger
+	middleware   *Middleware
 }
 
 // MangaService интерфейс сервиса манги
 type MangaService interface {
-	GetAll(ctx context.Context, filter domain.MangaFilter) ([]domain.Manga, int, error)
+	GetAll(ctx context.Context, filter domain.MangaFilter, cursor string) (domain.MangaPage, error)
 	GetByID(ctx context.Context, id int) (domain.Manga, error)
-	Create(ctx context.Context, manga domain.Manga) (int, error)
-	Update(ctx context.Context, manga domain.Manga) error
-	Delete(ctx context.Context, id int) error
+	Create(ctx context.Context, userID int, admin bool, manga domain.Manga) (int, error)
+	Update(ctx context.Context, userID int, admin bool, manga domain.Manga) error
+	Delete(ctx context.Context, userID int, admin bool, id int) error
+	SetTeam(ctx context.Context, mangaID int, teamID *int) error
 	GetGenres(ctx context.Context) ([]domain.Genre, error)
+	Suggest(ctx context.Context, q string, limit int) ([]domain.Suggestion, error)
+	GetWatermark(ctx context.Context, userID int, admin bool, mangaID int) (*domain.Watermark, error)
+	SaveWatermark(ctx context.Context, userID int, admin bool, watermark domain.Watermark) error
+	DeleteWatermark(ctx context.Context, userID int, admin bool, mangaID int) error
+	RateManga(ctx context.Context, userID, mangaID, score int) (domain.MangaRating, error)
+	RemoveRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error)
+	GetRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error)
 }
 
 // NewMangaHandler создает новый экземпляр MangaHandler
-func NewMangaHandler(mangaService MangaService, logger *slog.Logger) *MangaHandler {
+func NewMangaHandler(mangaService MangaService, middleware *Middleware, logger *slog.Logger) *MangaHandler {
 	return &MangaHandler{
 		mangaService: mangaService,
+		middleware:   middleware,
 		logger:       logger,
 	}
 }
@@ -39,28 +53,60 @@ func NewMangaHandler(mangaService MangaService, logger *slog.Logger) *MangaHandl
 func (h *MangaHandler) Register(router *gin.RouterGroup) {
 	manga := router.Group("/manga")
 	{
-		manga.GET("", h.getAllManga)
+		manga.GET("", h.middleware.RateLimit("search"), h.getAllManga)
 		manga.GET("/:id", h.getMangaByID)
 		manga.POST("", h.createManga)
 		manga.PUT("/:id", h.updateManga)
 		manga.DELETE("/:id", h.deleteManga)
+		manga.PUT("/:id/team", h.setMangaTeam)
 		manga.GET("/genres", h.getGenres)
+		manga.GET("/suggest", h.suggest)
+
+		// Оценки манги пользователями
+		rating := manga.Group("/:id/rating")
+		{
+			rating.GET("", h.getRating)
+			rating.PUT("", h.rateManga)
+			rating.DELETE("", h.removeRating)
+		}
+
+		// Настройки водяного знака доступны команде манги
+		watermark := manga.Group("/:id/watermark")
+		{
+			watermark.GET("", h.getWatermark)
+			watermark.PUT("", h.saveWatermark)
+			watermark.DELETE("", h.deleteWatermark)
+		}
 	}
 }
 
 // getAllManga возвращает список манги с фильтрацией
 // @Summary Получить список манги
-// @Description Возвращает список манги с возможностью фильтрации и пагинации
+// @Description Возвращает список манги с возможностью фильтрации и пагинации.
+// @Description Следующая страница запрашивается по курсору next_cursor из предыдущего ответа с теми же фильтрами и сортировкой;
+// @Description курсор не пропускает и не повторяет мангу при добавлении новой. Поле total есть в ответе только при withTotal=true.
+// @Description В результатах поиска поле highlight содержит фрагменты с найденными словами, выделенными тегом <mark>
 // @Tags manga
 // @Accept json
 // @Produce json
-// @Param genre query string false "Фильтр по жанру"
-// @Param status query string false "Фильтр по статусу"
-// @Param sortBy query string false "Поле для сортировки (title, rating, date)"
+// @Param genre query []string false "Жанры манги (можно повторять параметр или перечислить через запятую)" collectionFormat(multi)
+// @Param genreMode query string false "Сочетание жанров: all - все жанры (по умолчанию), any - хотя бы один"
+// @Param excludeGenre query []string false "Исключаемые жанры" collectionFormat(multi)
+// @Param status query []string false "Статусы (ongoing, completed, hiatus)" collectionFormat(multi)
+// @Param yearFrom query int false "Год выпуска не раньше"
+// @Param yearTo query int false "Год выпуска не позже"
+// @Param minRating query number false "Минимальная средняя оценка (0-10)"
+// @Param author query string false "Часть имени автора"
+// @Param artist query string false "Часть имени художника"
+// @Param chaptersFrom query int false "Минимальное количество глав"
+// @Param chaptersTo query int false "Максимальное количество глав"
+// @Param sortBy query string false "Поле для сортировки (title, rating, date, relevance); при поиске по умолчанию relevance"
 // @Param sortDesc query boolean false "Сортировка по убыванию"
-// @Param search query string false "Поиск по названию"
-// @Param page query int false "Номер страницы (начиная с 1)"
+// @Param search query string false "Полнотекстовый поиск по названиям, автору, жанрам и описанию с учетом опечаток"
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param page query int false "Номер страницы (начиная с 1), если курсор не задан"
 // @Param pageSize query int false "Размер страницы"
+// @Param withTotal query boolean false "Подсчитать общее количество подходящей манги"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -68,11 +114,13 @@ func (h *MangaHandler) Register(router *gin.RouterGroup) {
 func (h *MangaHandler) getAllManga(c *gin.Context) {
 	h.logger.Info("handling get all manga request")
 
-	var filter domain.MangaFilter
+	filter, err := mangaFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
 
-	// Получаем параметры фильтрации
-	filter.Genre = c.Query("genre")
-	filter.Status = c.Query("status")
+	// Получаем параметры сортировки и поиска
 	filter.SortBy = c.Query("sortBy")
 	filter.SortDesc = c.Query("sortDesc") == "true"
 	filter.Search = c.Query("search")
@@ -89,22 +137,33 @@ func (h *MangaHandler) getAllManga(c *gin.Context) {
 		pageSize = 20
 	}
 	filter.PageSize = pageSize
+	filter.WithTotal = c.Query("withTotal") == "true"
 
 	// Получаем данные от сервиса
-	mangas, total, err := h.mangaService.GetAll(c.Request.Context(), filter)
+	result, err := h.mangaService.GetAll(c.Request.Context(), filter, c.Query("cursor"))
 	if err != nil {
 		h.logger.Error("failed to get manga list", "error", err)
+		if strings.Contains(err.Error(), "invalid") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get manga list"})
 		return
 	}
 
 	// Формируем ответ
-	c.JSON(http.StatusOK, gin.H{
-		"data":  mangas,
-		"total": total,
-		"page":  filter.Page,
-		"size":  filter.PageSize,
-	})
+	response := gin.H{
+		"data": result.Mangas,
+		"page": filter.Page,
+		"size": filter.PageSize,
+	}
+	if result.Total != nil {
+		response["total"] = *result.Total
+	}
+	if result.NextCursor != "" {
+		response["next_cursor"] = result.NextCursor
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // getMangaByID возвращает мангу по идентификатору
@@ -139,13 +198,16 @@ func (h *MangaHandler) getMangaByID(c *gin.Context) {
 
 // createManga создает новую мангу
 // @Summary Создать новую мангу
-// @Description Создает новую мангу с указанными данными
+// @Description Создает новую мангу команды team_id (требуется право загрузки в команде; мангу без команды создает администратор)
 // @Tags manga
 // @Accept json
 // @Produce json
 // @Param manga body domain.Manga true "Данные для создания манги"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Security ApiKeyAuth
 // @Router /api/manga [post]
@@ -158,9 +220,21 @@ func (h *MangaHandler) createManga(c *gin.Context) {
 		return
 	}
 
-	id, err := h.mangaService.Create(c.Request.Context(), manga)
+	userID, admin := editor(c)
+
+	id, err := h.mangaService.Create(c.Request.Context(), userID, admin, manga)
 	if err != nil {
 		h.logger.Error("failed to create manga", "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to create manga: " + err.Error()})
 		return
 	}
@@ -170,7 +244,7 @@ func (h *MangaHandler) createManga(c *gin.Context) {
 
 // updateManga обновляет существующую мангу
 // @Summary Обновить мангу
-// @Description Обновляет существующую мангу по её ID
+// @Description Обновляет существующую мангу по её ID (требуется право загрузки в команде манги)
 // @Tags manga
 // @Accept json
 // @Produce json
@@ -179,6 +253,8 @@ func (h *MangaHandler) createManga(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Security ApiKeyAuth
 // @Router /api/manga/{id} [put]
@@ -200,9 +276,21 @@ func (h *MangaHandler) updateManga(c *gin.Context) {
 	// Устанавливаем ID из URL
 	manga.ID = id
 
-	err = h.mangaService.Update(c.Request.Context(), manga)
+	userID, admin := editor(c)
+
+	err = h.mangaService.Update(c.Request.Context(), userID, admin, manga)
 	if err != nil {
 		h.logger.Error("failed to update manga", "id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Manga not found"})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to update manga: " + err.Error()})
 		return
 	}
@@ -212,7 +300,7 @@ func (h *MangaHandler) updateManga(c *gin.Context) {
 
 // deleteManga удаляет мангу по идентификатору
 // @Summary Удалить мангу
-// @Description Удаляет мангу по её ID
+// @Description Удаляет мангу по её ID (требуется право удаления в команде манги)
 // @Tags manga
 // @Accept json
 // @Produce json
@@ -220,6 +308,8 @@ func (h *MangaHandler) updateManga(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Security ApiKeyAuth
 // @Router /api/manga/{id} [delete]
@@ -231,9 +321,21 @@ func (h *MangaHandler) deleteManga(c *gin.Context) {
 		return
 	}
 
-	err = h.mangaService.Delete(c.Request.Context(), id)
+	userID, admin := editor(c)
+
+	err = h.mangaService.Delete(c.Request.Context(), userID, admin, id)
 	if err != nil {
 		h.logger.Error("failed to delete manga", "id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Manga not found"})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete manga: " + err.Error()})
 		return
 	}
@@ -241,6 +343,79 @@ func (h *MangaHandler) deleteManga(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Manga deleted successfully"})
 }
 
+// setMangaTeam назначает манге команду
+// @Summary Назначить команду манге
+// @Description Передает мангу команде; team_id = null снимает назначение. Доступно администраторам
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Param team body object true "Команда, например {\"team_id\": 1}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/team [put]
+func (h *MangaHandler) setMangaTeam(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	var input struct {
+		TeamID *int `json:"team_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid manga team data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga team data: " + err.Error()})
+		return
+	}
+
+	err = h.mangaService.SetTeam(c.Request.Context(), id, input.TeamID)
+	if err != nil {
+		h.logger.Error("failed to set manga team", "id", id, "error", err)
+
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to set manga team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Manga team updated successfully"})
+}
+
+// suggest возвращает подсказки для строки поиска
+// @Summary Подсказки поиска
+// @Description Возвращает названия, альтернативные названия, авторов и жанры, начинающиеся с q или содержащие слово, начинающееся с q
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param q query string true "Начало строки поиска"
+// @Param limit query int false "Количество подсказок (по умолчанию 10, максимум 20)"
+// @Success 200 {array} domain.Suggestion
+// @Failure 500 {object} ErrorResponse
+// @Router /api/manga/suggest [get]
+func (h *MangaHandler) suggest(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	suggestions, err := h.mangaService.Suggest(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		h.logger.Error("failed to get suggestions", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
 // getGenres возвращает список всех жанров
 // @Summary Получить список жанров
 // @Description Возвращает список всех доступных жанров манги
@@ -260,3 +435,391 @@ func (h *MangaHandler) getGenres(c *gin.Context) {
 
 	c.JSON(http.StatusOK, genres)
 }
+
+// getRating возвращает рейтинг манги и оценку текущего пользователя
+// @Summary Получить оценку манги
+// @Description Возвращает средний рейтинг манги, количество голосов и оценку текущего пользователя
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} domain.MangaRating
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/rating [get]
+func (h *MangaHandler) getRating(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	rating, err := h.mangaService.GetRating(c.Request.Context(), userID.(int), id)
+	if err != nil {
+		h.logger.Error("failed to get rating", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Manga not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get rating"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rating)
+}
+
+// rateManga сохраняет оценку манги текущим пользователем
+// @Summary Оценить мангу
+// @Description Сохраняет оценку манги от 1 до 10 (один голос на пользователя) и пересчитывает рейтинг
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Param rating body domain.RatingInput true "Оценка"
+// @Success 200 {object} domain.MangaRating
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/rating [put]
+func (h *MangaHandler) rateManga(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	var input domain.RatingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid rating data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid rating data: " + err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	rating, err := h.mangaService.RateManga(c.Request.Context(), userID.(int), id, input.Score)
+	if err != nil {
+		h.logger.Error("failed to rate manga", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "invalid score") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Manga not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to rate manga: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rating)
+}
+
+// removeRating удаляет оценку манги текущим пользователем
+// @Summary Удалить оценку манги
+// @Description Удаляет оценку текущего пользователя и пересчитывает рейтинг манги
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} domain.MangaRating
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/rating [delete]
+func (h *MangaHandler) removeRating(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	rating, err := h.mangaService.RemoveRating(c.Request.Context(), userID.(int), id)
+	if err != nil {
+		h.logger.Error("failed to remove rating", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to remove rating: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rating)
+}
+
+// getWatermark возвращает настройки водяного знака манги
+// @Summary Получить водяной знак манги
+// @Description Возвращает настройки водяного знака, накладываемого на страницы манги
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} domain.Watermark
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/watermark [get]
+func (h *MangaHandler) getWatermark(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	userID, admin := editor(c)
+
+	watermark, err := h.mangaService.GetWatermark(c.Request.Context(), userID, admin, id)
+	if err != nil {
+		h.logger.Error("failed to get watermark", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Watermark not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get watermark"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watermark)
+}
+
+// saveWatermark сохраняет настройки водяного знака манги
+// @Summary Настроить водяной знак манги
+// @Description Сохраняет водяной знак (PNG-логотип и/или текст), накладываемый на страницы при загрузке или при генерации вариантов
+// @Tags manga
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID манги"
+// @Param enabled formData boolean false "Включен ли водяной знак (по умолчанию true)"
+// @Param text formData string false "Текст водяного знака"
+// @Param logo formData file false "PNG-логотип (если не передан, сохраняется ранее загруженный)"
+// @Param position formData string false "Положение (top-left, top-right, bottom-left, bottom-right, center)"
+// @Param opacity formData number false "Непрозрачность от 0 до 1 (по умолчанию 0.5)"
+// @Param scale formData number false "Ширина относительно ширины страницы от 0 до 1 (по умолчанию 0.2)"
+// @Param apply_on formData string false "Когда применять: upload или rendition (по умолчанию rendition)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/watermark [put]
+func (h *MangaHandler) saveWatermark(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	watermark := domain.Watermark{
+		MangaID:  id,
+		Enabled:  c.DefaultPostForm("enabled", "true") == "true",
+		Text:     c.PostForm("text"),
+		Position: c.PostForm("position"),
+		ApplyOn:  c.PostForm("apply_on"),
+	}
+
+	watermark.Opacity, err = strconv.ParseFloat(c.DefaultPostForm("opacity", "0.5"), 64)
+	if err != nil {
+		h.logger.Error("invalid watermark opacity", "opacity", c.PostForm("opacity"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid watermark opacity"})
+		return
+	}
+
+	watermark.Scale, err = strconv.ParseFloat(c.DefaultPostForm("scale", "0.2"), 64)
+	if err != nil {
+		h.logger.Error("invalid watermark scale", "scale", c.PostForm("scale"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid watermark scale"})
+		return
+	}
+
+	// Логотип необязателен
+	if file, err := c.FormFile("logo"); err == nil {
+		// Ограничиваем размер логотипа (например, до 1 МБ)
+		if file.Size > 1024*1024 {
+			h.logger.Error("logo too large", "size", file.Size)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Logo file is too large (max 1MB)"})
+			return
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			h.logger.Error("failed to open logo file", "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to open logo file: " + err.Error()})
+			return
+		}
+		defer src.Close()
+
+		watermark.Logo, err = io.ReadAll(src)
+		if err != nil {
+			h.logger.Error("failed to read logo data", "error", err)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to read logo data: " + err.Error()})
+			return
+		}
+	}
+
+	userID, admin := editor(c)
+
+	err = h.mangaService.SaveWatermark(c.Request.Context(), userID, admin, watermark)
+	if err != nil {
+		h.logger.Error("failed to save watermark", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "invalid watermark") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Manga not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to save watermark: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Watermark saved successfully"})
+}
+
+// deleteWatermark удаляет водяной знак манги
+// @Summary Удалить водяной знак манги
+// @Description Удаляет настройки водяного знака. Уже обработанные при загрузке страницы не изменяются
+// @Tags manga
+// @Accept json
+// @Produce json
+// @Param id path int true "ID манги"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/manga/{id}/watermark [delete]
+func (h *MangaHandler) deleteWatermark(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid manga id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid manga ID format"})
+		return
+	}
+
+	userID, admin := editor(c)
+
+	err = h.mangaService.DeleteWatermark(c.Request.Context(), userID, admin, id)
+	if err != nil {
+		h.logger.Error("failed to delete watermark", "manga_id", id, "error", err)
+
+		if strings.Contains(err.Error(), "permission denied") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "Watermark not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to delete watermark: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Watermark deleted successfully"})
+}
+
+// mangaFilter разбирает параметры фильтрации списка манги
+func mangaFilter(c *gin.Context) (domain.MangaFilter, error) {
+	filter := domain.MangaFilter{
+		Genres:        queryList(c, "genre"),
+		GenreMode:     c.Query("genreMode"),
+		ExcludeGenres: queryList(c, "excludeGenre"),
+		Statuses:      queryList(c, "status"),
+		Author:        strings.TrimSpace(c.Query("author")),
+		Artist:        strings.TrimSpace(c.Query("artist")),
+	}
+
+	var err error
+	if filter.YearFrom, err = queryInt(c, "yearFrom"); err != nil {
+		return domain.MangaFilter{}, err
+	}
+	if filter.YearTo, err = queryInt(c, "yearTo"); err != nil {
+		return domain.MangaFilter{}, err
+	}
+	if filter.ChaptersFrom, err = queryInt(c, "chaptersFrom"); err != nil {
+		return domain.MangaFilter{}, err
+	}
+	if filter.ChaptersTo, err = queryInt(c, "chaptersTo"); err != nil {
+		return domain.MangaFilter{}, err
+	}
+
+	if value := c.Query("minRating"); value != "" {
+		rating, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return domain.MangaFilter{}, errors.New("invalid filter: minRating must be a number")
+		}
+		filter.MinRating = &rating
+	}
+
+	return filter, nil
+}
+
+// queryList возвращает значения параметра запроса, переданные повторением параметра или через запятую
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryArray(name) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// queryInt возвращает целочисленный параметр запроса или nil, если он не передан
+func queryInt(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %s must be an integer", name)
+	}
+	return &n, nil
+}
@@ -1,84 +1,73 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/LirikaOne-Back/manga-reader3/internal/ratelimit"
 	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Заголовок и ключи контекста middleware
+const (
+	requestIDHeader  = "X-Request-ID"
+	timeoutParentKey = "timeout_parent" // Контекст запроса до применения таймаута
 )
 
 // Middleware содержит middleware функции для обработки HTTP-запросов
 type Middleware struct {
 	authService AuthService
+	rateLimits  RateLimits
+	timeouts    map[string]time.Duration
 	logger      *slog.Logger
 }
 
+// Config настройки middleware
+type Config struct {
+	RateLimits RateLimits
+	Timeouts   map[string]time.Duration // Таймауты по именам групп маршрутов: default, upload
+}
+
+// RateLimits настройки ограничения частоты запросов.
+// Политики задаются по именам групп маршрутов: api, auth, search, upload.
+type RateLimits struct {
+	Store    ratelimit.Store // nil отключает ограничения
+	Policies map[string]ratelimit.Policy
+}
+
 // NewMiddleware создает новый экземпляр Middleware
-func NewMiddleware(authService AuthService, logger *slog.Logger) *Middleware {
+func NewMiddleware(authService AuthService, cfg Config, logger *slog.Logger) *Middleware {
 	return &Middleware{
 		authService: authService,
+		rateLimits:  cfg.RateLimits,
+		timeouts:    cfg.Timeouts,
 		logger:      logger,
 	}
 }
 
-// JWTAuth middleware для проверки JWT токена
-func (m *Middleware) JWTAuth() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Authorization header is required"})
-			c.Abort()
-			return
-		}
-
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid authorization header format"})
-			c.Abort()
-			return
-		}
-
-		tokenString := headerParts[1]
-		claims, err := m.authService.ValidateToken(tokenString)
-		if err != nil {
-			m.logger.Warn("invalid token", "error", err)
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Invalid or expired token"})
-			c.Abort()
-			return
-		}
-
-		// Добавляем информацию о пользователе в контекст
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("user_role", claims.Role)
-
-		c.Next()
+// twoFactorSatisfied проверяет, что для действий модератора и администратора выполнено требование 2FA.
+// Обязательна ли 2FA для роли, решает AuthService при выдаче токена (claim "2fa").
+func twoFactorSatisfied(c *gin.Context, requiredRole string) bool {
+	if requiredRole == "user" {
+		return true
 	}
+	return c.GetBool("two_factor")
 }
 
-// RoleAuth middleware для проверки роли пользователя
-func (m *Middleware) RoleAuth(requiredRole string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Предполагается, что JWT middleware уже добавил информацию о пользователе в контекст
-		userRole, exists := c.Get("user_role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: "Unauthorized"})
-			c.Abort()
-			return
-		}
-
-		// Проверяем роль пользователя
-		role := userRole.(string)
-		if !hasRole(role, requiredRole) {
-			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Insufficient permissions"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
+// hasPrivilege проверяет, что текущий пользователь имеет роль requiredRole и выполнил для нее требование 2FA.
+// Используется обработчиками, которые расширяют права модераторов и администраторов на маршрутах для всех пользователей.
+func hasPrivilege(c *gin.Context, requiredRole string) bool {
+	role := c.GetString("user_role")
+	return role != "" && hasRole(role, requiredRole) && twoFactorSatisfied(c, requiredRole)
 }
 
 // hasRole проверяет, имеет ли пользователь требуемую роль
@@ -106,7 +95,8 @@ func (m *Middleware) CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Retry-After, RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -123,7 +113,7 @@ func (m *Middleware) Recover() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				m.logger.Error("panic recovered", "error", err)
+				m.logger.ErrorContext(c.Request.Context(), "panic recovered", "error", err)
 				c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Internal server error"})
 				c.Abort()
 			}
@@ -132,22 +122,73 @@ func (m *Middleware) Recover() gin.HandlerFunc {
 	}
 }
 
-// Timeout middleware для ограничения времени выполнения запроса
-func (m *Middleware) Timeout() gin.HandlerFunc {
+// Timeout middleware ограничивает время выполнения запроса таймаутом с именем name.
+// Контекст запроса отменяется по истечении таймаута, что прерывает запросы к базе данных.
+// Таймаут маршрута заменяет общий, а не сокращает его, поэтому для загрузок можно задать больший таймаут.
+func (m *Middleware) Timeout(name string) gin.HandlerFunc {
+	timeout := m.timeouts[name]
+	if timeout <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
 	return func(c *gin.Context) {
-		// TODO: Implement request timeout
+		parent := c.Request.Context()
+		if base, ok := c.Get(timeoutParentKey); ok {
+			parent = base.(context.Context)
+		} else {
+			c.Set(timeoutParentKey, parent)
+		}
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
 		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			m.logger.WarnContext(ctx, "request timed out", "timeout", timeout, "path", c.Request.URL.Path)
+			c.JSON(http.StatusGatewayTimeout, ErrorResponse{Message: "Request timed out"})
+			c.Abort()
+		}
 	}
 }
 
-// RequestID middleware для добавления уникального ID запроса
+// RequestID middleware для добавления уникального ID запроса.
+// ID берется из заголовка X-Request-ID или генерируется, возвращается в ответе
+// и сохраняется в контексте запроса, откуда логгер добавляет его ко всем записям.
 func (m *Middleware) RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: Implement request ID
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+
 		c.Next()
 	}
 }
 
+// validRequestID проверяет ID запроса от клиента, чтобы он не мог подделать записи в логе
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Logger middleware для логирования запросов
 func (m *Middleware) Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -173,14 +214,14 @@ func (m *Middleware) Logger() gin.HandlerFunc {
 		}
 
 		// Логируем в зависимости от статус-кода
-		logFunc := m.logger.Info
+		logFunc := m.logger.InfoContext
 		if statusCode >= 500 {
-			logFunc = m.logger.Error
+			logFunc = m.logger.ErrorContext
 		} else if statusCode >= 400 {
-			logFunc = m.logger.Warn
+			logFunc = m.logger.WarnContext
 		}
 
-		logFunc("HTTP request",
+		logFunc(c.Request.Context(), "HTTP request",
 			"status", statusCode,
 			"method", method,
 			"path", path,
@@ -191,6 +232,66 @@ func (m *Middleware) Logger() gin.HandlerFunc {
 	}
 }
 
+// RateLimit middleware ограничивает частоту запросов к группе маршрутов по политике с именем name.
+// Аутентифицированные пользователи ограничиваются по ID с лимитом своей роли, анонимные - по IP.
+// Ответ содержит заголовки RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset,
+// при превышении - статус 429 и Retry-After.
+func (m *Middleware) RateLimit(name string) gin.HandlerFunc {
+	policy, ok := m.rateLimits.Policies[name]
+	if !ok || m.rateLimits.Store == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		subject, role := m.rateLimitSubject(c)
+
+		limit := policy.Limit(role)
+		if limit.Unlimited() {
+			c.Next()
+			return
+		}
+
+		result, err := m.rateLimits.Store.Allow(c.Request.Context(), name+":"+subject, limit)
+		if err != nil {
+			// Недоступность хранилища не должна останавливать API
+			m.logger.ErrorContext(c.Request.Context(), "failed to check rate limit", "policy", name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", limit.String())
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			m.logger.WarnContext(c.Request.Context(), "rate limit exceeded", "policy", name, "subject", subject, "role", role)
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, ErrorResponse{Message: "Too many requests, try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitSubject возвращает ключ ограничения и роль пользователя, определенного Authorize
+func (m *Middleware) rateLimitSubject(c *gin.Context) (string, string) {
+	if userID := getUserIDFromContext(c); userID != 0 {
+		return "user:" + strconv.Itoa(userID), c.GetString("user_role")
+	}
+
+	return "ip:" + c.ClientIP(), ""
+}
+
+// ceilSeconds округляет длительность вверх до целых секунд для заголовков
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
 // ContentTypeJSON middleware для проверки Content-Type
 func (m *Middleware) ContentTypeJSON() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/ratelimit"
+	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitRouter возвращает маршрут GET /api/manga с ограничением "api" по спецификации spec
+func newRateLimitRouter(t *testing.T, spec string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	policy, err := ratelimit.ParsePolicy("api", spec)
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+
+	auth := testAuthService{claims: map[string]*service.JWTClaims{
+		"user":  {UserID: 1, Username: "user", Role: "user", Type: "access"},
+		"admin": {UserID: 2, Username: "admin", Role: "admin", Type: "access"},
+	}}
+	middleware := NewMiddleware(auth, Config{
+		RateLimits: RateLimits{
+			Store:    ratelimit.NewMemoryStore(),
+			Policies: map[string]ratelimit.Policy{"api": policy},
+		},
+	}, testLogger())
+
+	router := gin.New()
+	router.Use(middleware.Authorize())
+	router.GET("/api/manga", middleware.RateLimit("api"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+// rateLimitRequest выполняет GET /api/manga от имени вызывающего; пустой token - анонимный запрос
+func rateLimitRequest(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/manga", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	router := newRateLimitRouter(t, "2/m")
+
+	tests := []struct {
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{status: http.StatusOK, remaining: "1", reset: "30"},
+		{status: http.StatusOK, remaining: "0", reset: "60"},
+		{status: http.StatusTooManyRequests, remaining: "0", reset: "60", retryAfter: "30"},
+	}
+
+	for i, tt := range tests {
+		rec := rateLimitRequest(router, "")
+
+		if rec.Code != tt.status {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, tt.status)
+		}
+
+		headers := map[string]string{
+			"RateLimit-Policy":    "2;w=60",
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": tt.remaining,
+			"RateLimit-Reset":     tt.reset,
+			"Retry-After":         tt.retryAfter,
+		}
+		for name, want := range headers {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("request %d: %s = %q, want %q", i+1, name, got, want)
+			}
+		}
+
+		if tt.status == http.StatusTooManyRequests {
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Message == "" {
+				t.Errorf("request %d: unexpected body %s", i+1, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestRateLimitSubjects(t *testing.T) {
+	router := newRateLimitRouter(t, "1/m;admin=0")
+
+	if rec := rateLimitRequest(router, ""); rec.Code != http.StatusOK {
+		t.Fatalf("anonymous: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := rateLimitRequest(router, ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("anonymous: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// Пользователь ограничивается по ID, а не по IP, с которого уже исчерпан запас
+	if rec := rateLimitRequest(router, "user"); rec.Code != http.StatusOK {
+		t.Fatalf("user: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := rateLimitRequest(router, "user"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("user: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// Для роли admin ограничение отключено, заголовки не отправляются
+	for i := 0; i < 3; i++ {
+		rec := rateLimitRequest(router, "admin")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("admin: status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "" {
+			t.Fatalf("admin: RateLimit-Limit = %q, want empty", got)
+		}
+	}
+}
+
+func TestRateLimitUnknownPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	middleware := NewMiddleware(testAuthService{}, Config{
+		RateLimits: RateLimits{Store: ratelimit.NewMemoryStore()},
+	}, testLogger())
+	router := gin.New()
+	router.GET("/api/manga", middleware.RateLimit("api"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 3; i++ {
+		if rec := rateLimitRequest(router, ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Policy правило доступа к маршруту
+type Policy struct {
+	Role          string // Минимальная роль: user, moderator, admin; пустая - доступ без входа
+	VerifiedEmail bool   // Требуется подтвержденный email
+}
+
+// Правила доступа
+var (
+	Public    = Policy{}
+	User      = Policy{Role: "user"}
+	Verified  = Policy{Role: "user", VerifiedEmail: true}
+	Moderator = Policy{Role: "moderator"}
+	Admin     = Policy{Role: "admin"}
+)
+
+// routePolicies правила доступа ко всем маршрутам в формате "METHOD путь" (путь как в gin.Context.FullPath).
+// Маршрут без правила не регистрируется: Handler.Register возвращает ошибку.
+var routePolicies = map[string]Policy{
+	// Служебные маршруты
+	"GET /health":                Public,
+	"GET /.well-known/jwks.json": Public,
+	"GET /swagger/*any":          Public,
+	"GET /images/*key":           Public,
+	"HEAD /images/*key":          Public,
+
+	// Аутентификация
+	"POST /api/auth/signup":                 Public,
+	"POST /api/auth/login":                  Public,
+	"POST /api/auth/login/2fa":              Public,
+	"POST /api/auth/refresh":                Public,
+	"GET /api/auth/oidc":                    Public,
+	"GET /api/auth/oidc/:provider/start":    Public,
+	"GET /api/auth/oidc/:provider/callback": Public,
+	"POST /api/auth/password/forgot":        Public,
+	"POST /api/auth/password/reset":         Public,
+	"POST /api/auth/email/verify":           Public,
+	"POST /api/auth/logout":                 User,
+	"PUT /api/auth/password":                User,
+	"POST /api/auth/email/resend":           User,
+	"GET /api/auth/me":                      User,
+	"GET /api/auth/sessions":                User,
+	"DELETE /api/auth/sessions/:id":         User,
+	"GET /api/auth/2fa":                     User,
+	"POST /api/auth/2fa/setup":              User,
+	"POST /api/auth/2fa/enable":             User,
+	"POST /api/auth/2fa/disable":            User,
+	"POST /api/auth/2fa/recovery-codes":     User,
+
+	// Манга
+	"GET /api/manga":               Public,
+	"GET /api/manga/genres":        Public,
+	"GET /api/manga/suggest":       Public,
+	"GET /api/manga/:id":           Public,
+	"PUT /api/manga/:id/team":      Admin,
+	"GET /api/manga/:id/rating":    User,
+	"PUT /api/manga/:id/rating":    User,
+	"DELETE /api/manga/:id/rating": User,
+
+	// Изменение манги и глав: права в команде манги проверяют MangaService и ChapterService
+	"POST /api/manga":                     User,
+	"PUT /api/manga/:id":                  User,
+	"DELETE /api/manga/:id":               User,
+	"GET /api/manga/:id/watermark":        User,
+	"PUT /api/manga/:id/watermark":        User,
+	"DELETE /api/manga/:id/watermark":     User,
+	"POST /api/chapters":                  User,
+	"PUT /api/chapters/:id":               User,
+	"DELETE /api/chapters/:id":            User,
+	"POST /api/chapters/:id/pages":        Verified,
+	"POST /api/chapters/:id/archive":      Verified,
+	"DELETE /api/chapters/pages/:page_id": User,
+
+	// Главы и страницы
+	"GET /api/chapters/manga/:manga_id": Public,
+	"GET /api/chapters/:id":             Public,
+	"GET /api/chapters/:id/pages":       Public,
+	"GET /api/pages/:id/image":          Public,
+
+	// Команды: составом управляют участники с правом manage_members, проверку выполняет TeamService
+	"GET /api/teams":                         Public,
+	"GET /api/teams/:id":                     Public,
+	"POST /api/teams":                        Admin,
+	"PUT /api/teams/:id":                     Admin,
+	"DELETE /api/teams/:id":                  Admin,
+	"GET /api/teams/:id/members":             Public,
+	"PUT /api/teams/:id/members/:user_id":    User,
+	"DELETE /api/teams/:id/members/:user_id": User,
+
+	// Комментарии
+	"GET /api/manga/:id/comments":            Public,
+	"POST /api/manga/:id/comments":           Verified,
+	"PUT /api/manga/:id/comments/lock":       Moderator,
+	"DELETE /api/manga/:id/comments/lock":    Moderator,
+	"GET /api/chapters/:id/comments":         Public,
+	"POST /api/chapters/:id/comments":        Verified,
+	"PUT /api/chapters/:id/comments/lock":    Moderator,
+	"DELETE /api/chapters/:id/comments/lock": Moderator,
+	"GET /api/comments/:id/replies":          Public,
+	"PUT /api/comments/:id":                  User,
+	"DELETE /api/comments/:id":               User,
+	"PUT /api/comments/:id/hidden":           Moderator,
+
+	// Пользователи
+	"GET /api/users/profile":                     User,
+	"PUT /api/users/profile":                     User,
+	"DELETE /api/users/profile":                  User,
+	"GET /api/users/bookmarks":                   User,
+	"POST /api/users/bookmarks/:manga_id":        User,
+	"DELETE /api/users/bookmarks/:manga_id":      User,
+	"GET /api/users/history":                     User,
+	"POST /api/users/history":                    User,
+	"GET /api/users/continue":                    User,
+	"GET /api/users/teams":                       User,
+	"GET /api/users/:id":                         Admin,
+	"PUT /api/users/:id":                         Admin,
+	"DELETE /api/users/:id":                      Admin,
+	"GET /api/users/:id/sessions":                Admin,
+	"DELETE /api/users/:id/sessions/:session_id": Admin,
+	"DELETE /api/users/:id/lock":                 Admin,
+
+	// Администрирование
+	"GET /api/admin/audit": Admin,
+}
+
+// Authorize middleware проверяет доступ к маршруту по правилу из routePolicies.
+// Пользователь определяется по access-токену из заголовка Authorization; на публичных маршрутах
+// запрос без токена или с невалидным токеном обрабатывается как анонимный.
+func (m *Middleware) Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			// Маршрут не найден, ответит gin
+			c.Next()
+			return
+		}
+
+		policy, ok := routePolicies[c.Request.Method+" "+route]
+		if !ok {
+			m.logger.ErrorContext(c.Request.Context(), "no access policy for route", "method", c.Request.Method, "route", route)
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Access denied"})
+			c.Abort()
+			return
+		}
+
+		claims, message := m.authenticate(c)
+		if claims != nil {
+			setIdentity(c, claims)
+		}
+		setAuditActor(c, claims)
+
+		if policy.Role == "" {
+			c.Next()
+			return
+		}
+
+		if claims == nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Message: message})
+			c.Abort()
+			return
+		}
+
+		if !hasRole(claims.Role, policy.Role) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		if !twoFactorSatisfied(c, policy.Role) {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Two-factor authentication is required for this role"})
+			c.Abort()
+			return
+		}
+
+		if policy.VerifiedEmail && !claims.EmailVerified {
+			c.JSON(http.StatusForbidden, ErrorResponse{Message: "Email address is not verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate проверяет access-токен из заголовка Authorization.
+// Если пользователь не определен, возвращает сообщение для ответа 401.
+func (m *Middleware) authenticate(c *gin.Context) (*service.JWTClaims, string) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		return nil, "Authorization header is required"
+	}
+
+	headerParts := strings.Split(authHeader, " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return nil, "Invalid authorization header format"
+	}
+
+	claims, err := m.authService.ValidateToken(headerParts[1])
+	if err != nil {
+		m.logger.WarnContext(c.Request.Context(), "invalid token", "error", err)
+		return nil, "Invalid or expired token"
+	}
+
+	// refresh-токен не дает доступа к API
+	if claims.Type != "access" {
+		m.logger.WarnContext(c.Request.Context(), "invalid token type", "type", claims.Type)
+		return nil, "Invalid or expired token"
+	}
+
+	return claims, ""
+}
+
+// setIdentity добавляет информацию о пользователе в контекст
+func setIdentity(c *gin.Context, claims *service.JWTClaims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("user_role", claims.Role)
+	c.Set("session_id", claims.SessionID)
+	c.Set("email_verified", claims.EmailVerified)
+	c.Set("two_factor", claims.TwoFactor)
+}
+
+// setAuditActor добавляет в контекст запроса автора действий для журнала аудита
+func setAuditActor(c *gin.Context, claims *service.JWTClaims) {
+	actor := service.AuditActor{IP: c.ClientIP()}
+	if claims != nil {
+		actor.UserID = claims.UserID
+		actor.Username = claims.Username
+		actor.Role = claims.Role
+	}
+	c.Request = c.Request.WithContext(service.WithAuditActor(c.Request.Context(), actor))
+}
+
+// editor возвращает ID текущего пользователя и наличие прав администратора.
+// Права на изменение конкретной манги определяются ролью пользователя в ее команде.
+// Администратор без выполненного требования 2FA получает только права своей роли в команде.
+func editor(c *gin.Context) (int, bool) {
+	userID, _ := c.Get("user_id")
+	id, _ := userID.(int)
+
+	return id, hasPrivilege(c, "admin")
+}
+
+// checkRoutePolicies проверяет, что для каждого маршрута задано правило доступа
+func checkRoutePolicies(routes gin.RoutesInfo) error {
+	var missing []string
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if _, ok := routePolicies[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("no access policy for routes: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// testAuthService проверяет токены по таблице: токен - имя вызывающего из testCallers
+type testAuthService struct {
+	AuthService
+	claims map[string]*service.JWTClaims
+}
+
+func (s testAuthService) ValidateToken(token string) (*service.JWTClaims, error) {
+	claims, ok := s.claims[token]
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// testCaller вызывающий, от имени которого выполняется запрос; пустой role - анонимный запрос
+type testCaller struct {
+	name          string
+	role          string
+	emailVerified bool
+	twoFactor     bool
+}
+
+var testCallers = []testCaller{
+	{name: "anonymous"},
+	{name: "user", role: "user", emailVerified: true},
+	{name: "user_2fa", role: "user", emailVerified: true, twoFactor: true},
+	{name: "unverified", role: "user"},
+	{name: "unverified_2fa", role: "user", twoFactor: true},
+	{name: "moderator", role: "moderator", emailVerified: true},
+	{name: "moderator_2fa", role: "moderator", emailVerified: true, twoFactor: true},
+	{name: "admin", role: "admin", emailVerified: true},
+	{name: "admin_2fa", role: "admin", emailVerified: true, twoFactor: true},
+}
+
+// expectedStatus ожидаемые ответы Authorize по правилам доступа и вызывающим
+var expectedStatus = map[Policy]map[string]int{
+	Public: {
+		"anonymous": 200, "user": 200, "user_2fa": 200, "unverified": 200, "unverified_2fa": 200,
+		"moderator": 200, "moderator_2fa": 200, "admin": 200, "admin_2fa": 200,
+	},
+	User: {
+		"anonymous": 401, "user": 200, "user_2fa": 200, "unverified": 200, "unverified_2fa": 200,
+		"moderator": 200, "moderator_2fa": 200, "admin": 200, "admin_2fa": 200,
+	},
+	Verified: {
+		"anonymous": 401, "user": 200, "user_2fa": 200, "unverified": 403, "unverified_2fa": 403,
+		"moderator": 200, "moderator_2fa": 200, "admin": 200, "admin_2fa": 200,
+	},
+	Moderator: {
+		"anonymous": 401, "user": 403, "user_2fa": 403, "unverified": 403, "unverified_2fa": 403,
+		"moderator": 403, "moderator_2fa": 200, "admin": 403, "admin_2fa": 200,
+	},
+	Admin: {
+		"anonymous": 401, "user": 403, "user_2fa": 403, "unverified": 403, "unverified_2fa": 403,
+		"moderator": 403, "moderator_2fa": 403, "admin": 403, "admin_2fa": 200,
+	},
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// testRoutePath подставляет значения параметров в путь маршрута gin
+func testRoutePath(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "1"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	auth := testAuthService{claims: make(map[string]*service.JWTClaims)}
+	for i, caller := range testCallers {
+		if caller.role == "" {
+			continue
+		}
+		auth.claims[caller.name] = &service.JWTClaims{
+			UserID:        i + 1,
+			Username:      caller.name,
+			Role:          caller.role,
+			Type:          "access",
+			EmailVerified: caller.emailVerified,
+			TwoFactor:     caller.twoFactor,
+		}
+	}
+	middleware := NewMiddleware(auth, Config{}, testLogger())
+
+	router := gin.New()
+	router.Use(middleware.Authorize())
+
+	keys := make([]string, 0, len(routePolicies))
+	for key := range routePolicies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		method, route, _ := strings.Cut(key, " ")
+		router.Handle(method, route, func(c *gin.Context) {
+			c.String(http.StatusOK, c.Request.Method+" "+c.FullPath())
+		})
+	}
+
+	for _, key := range keys {
+		policy := routePolicies[key]
+		expected, ok := expectedStatus[policy]
+		if !ok {
+			t.Errorf("%s: no expected statuses for policy %+v", key, policy)
+			continue
+		}
+
+		method, route, _ := strings.Cut(key, " ")
+		for _, caller := range testCallers {
+			t.Run(key+"/"+caller.name, func(t *testing.T) {
+				req := httptest.NewRequest(method, testRoutePath(route), nil)
+				if caller.role != "" {
+					req.Header.Set("Authorization", "Bearer "+caller.name)
+				}
+
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != expected[caller.name] {
+					t.Fatalf("status = %d, want %d (body %s)", rec.Code, expected[caller.name], rec.Body.String())
+				}
+				if rec.Code == http.StatusOK && method != http.MethodHead && rec.Body.String() != key {
+					t.Fatalf("request matched route %q, want %q", rec.Body.String(), key)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorizeInvalidToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	middleware := NewMiddleware(testAuthService{}, Config{}, testLogger())
+	router := gin.New()
+	router.Use(middleware.Authorize())
+	router.GET("/api/manga", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/auth/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		// На публичном маршруте невалидный токен не мешает анонимному доступу
+		{path: "/api/manga", want: http.StatusOK},
+		{path: "/api/auth/me", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer expired")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthorizeUnknownRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	middleware := NewMiddleware(testAuthService{}, Config{}, testLogger())
+	router := gin.New()
+	router.Use(middleware.Authorize())
+	router.GET("/api/unlisted", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/unlisted", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestEditor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		role      string
+		twoFactor bool
+		admin     bool
+		moderator bool
+	}{
+		{role: "user", twoFactor: true},
+		{role: "moderator", twoFactor: false},
+		{role: "moderator", twoFactor: true, moderator: true},
+		{role: "admin", twoFactor: false},
+		{role: "admin", twoFactor: true, admin: true, moderator: true},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", 7)
+		c.Set("user_role", tt.role)
+		c.Set("two_factor", tt.twoFactor)
+
+		id, admin := editor(c)
+		if id != 7 || admin != tt.admin {
+			t.Errorf("editor(%s, 2fa=%v) = %d, %v; want 7, %v", tt.role, tt.twoFactor, id, admin, tt.admin)
+		}
+
+		_, moderator := (&CommentHandler{}).viewer(c)
+		if moderator != tt.moderator {
+			t.Errorf("viewer(%s, 2fa=%v) moderator = %v, want %v", tt.role, tt.twoFactor, moderator, tt.moderator)
+		}
+	}
+}
+
+func TestRegisterCoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	if err := NewHandler(&service.Services{}, Config{}, testLogger()).Register(router); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if err := checkRoutePolicies(router.Routes()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Правила для несуществующих маршрутов указывают на опечатку или удаленный маршрут
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for key := range routePolicies {
+		if !registered[key] {
+			t.Errorf("access policy for unregistered route %s", key)
+		}
+	}
+}
+
+func TestCheckRoutePoliciesMissing(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/api/manga"},
+		{Method: http.MethodPost, Path: "/api/unlisted"},
+	}
+
+	err := checkRoutePolicies(routes)
+	if err == nil || !strings.Contains(err.Error(), "POST /api/unlisted") {
+		t.Fatalf("checkRoutePolicies() = %v, want error for POST /api/unlisted", err)
+	}
+}
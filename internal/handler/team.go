@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// TeamHandler обрабатывает HTTP-запросы, связанные с командами
+type TeamHandler struct {
+	teamService TeamService
+	middleware  *Middleware
+	logger      *slog.Logger
+}
+
+// TeamService интерфейс сервиса команд
+type TeamService interface {
+	List(ctx context.Context) ([]domain.Team, error)
+	GetByID(ctx context.Context, id int) (domain.Team, error)
+	Create(ctx context.Context, input domain.TeamInput) (int, error)
+	Update(ctx context.Context, id int, input domain.TeamInput) error
+	Delete(ctx context.Context, id int) error
+	GetMembers(ctx context.Context, teamID int) ([]domain.TeamMember, error)
+	GetUserTeams(ctx context.Context, userID int) ([]domain.UserTeam, error)
+	SetMember(ctx context.Context, teamID, actorID int, admin bool, userID int, role string) error
+	RemoveMember(ctx context.Context, teamID, actorID int, admin bool, userID int) error
+}
+
+// NewTeamHandler создает новый экземпляр TeamHandler
+func NewTeamHandler(teamService TeamService, middleware *Middleware, logger *slog.Logger) *TeamHandler {
+	return &TeamHandler{
+		teamService: teamService,
+		middleware:  middleware,
+		logger:      logger,
+	}
+}
+
+// Register регистрирует обработчики путей для команд
+func (h *TeamHandler) Register(router *gin.RouterGroup) {
+	teams := router.Group("/teams")
+	{
+		teams.GET("", h.getTeams)
+		teams.GET("/:id", h.getTeam)
+		teams.POST("", h.createTeam)
+		teams.PUT("/:id", h.updateTeam)
+		teams.DELETE("/:id", h.deleteTeam)
+
+		// Участники команды
+		teams.GET("/:id/members", h.getTeamMembers)
+		teams.PUT("/:id/members/:user_id", h.setTeamMember)
+		teams.DELETE("/:id/members/:user_id", h.removeTeamMember)
+	}
+
+	// Команды текущего пользователя
+	router.GET("/users/teams", h.getMyTeams)
+}
+
+// getTeams возвращает список команд
+// @Summary Получить список команд
+// @Description Возвращает список всех команд переводчиков
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.Team
+// @Failure 500 {object} ErrorResponse
+// @Router /api/teams [get]
+func (h *TeamHandler) getTeams(c *gin.Context) {
+	teams, err := h.teamService.List(c.Request.Context())
+	if err != nil {
+		h.logger.Error("failed to get teams", "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// getTeam возвращает команду по ID
+// @Summary Получить команду
+// @Description Возвращает информацию о команде по её ID
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Success 200 {object} domain.Team
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/teams/{id} [get]
+func (h *TeamHandler) getTeam(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	team, err := h.teamService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get team", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to get team")
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// createTeam создает команду
+// @Summary Создать команду
+// @Description Создает команду и назначает ее владельцем пользователя owner_id. Доступно администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param team body domain.TeamInput true "Данные команды"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/teams [post]
+func (h *TeamHandler) createTeam(c *gin.Context) {
+	var input domain.TeamInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid team data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid team data: " + err.Error()})
+		return
+	}
+
+	id, err := h.teamService.Create(c.Request.Context(), input)
+	if err != nil {
+		h.logger.Error("failed to create team", "error", err)
+		h.errorResponse(c, err, "Failed to create team")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Team created successfully"})
+}
+
+// updateTeam изменяет команду
+// @Summary Изменить команду
+// @Description Изменяет название и описание команды. Доступно администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Param team body domain.TeamInput true "Данные команды"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/teams/{id} [put]
+func (h *TeamHandler) updateTeam(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	var input domain.TeamInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid team data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid team data: " + err.Error()})
+		return
+	}
+
+	if err := h.teamService.Update(c.Request.Context(), id, input); err != nil {
+		h.logger.Error("failed to update team", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to update team")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team updated successfully"})
+}
+
+// deleteTeam удаляет команду
+// @Summary Удалить команду
+// @Description Удаляет команду. Манга команды остается без команды, изменять ее может только администратор. Доступно администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/teams/{id} [delete]
+func (h *TeamHandler) deleteTeam(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.teamService.Delete(c.Request.Context(), id); err != nil {
+		h.logger.Error("failed to delete team", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to delete team")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team deleted successfully"})
+}
+
+// getTeamMembers возвращает участников команды
+// @Summary Получить участников команды
+// @Description Возвращает участников команды и их роли (owner, uploader, member)
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Success 200 {array} domain.TeamMember
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/teams/{id}/members [get]
+func (h *TeamHandler) getTeamMembers(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.teamService.GetMembers(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get team members", "id", id, "error", err)
+		h.errorResponse(c, err, "Failed to get team members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// setTeamMember добавляет пользователя в команду или меняет его роль
+// @Summary Добавить участника команды
+// @Description Добавляет пользователя в команду или меняет его роль. Доступно владельцам команды и администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Param user_id path int true "ID пользователя"
+// @Param member body domain.TeamMemberInput true "Роль в команде"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/teams/{id}/members/{user_id} [put]
+func (h *TeamHandler) setTeamMember(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		h.logger.Error("invalid user id format", "user_id", c.Param("user_id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid user ID format"})
+		return
+	}
+
+	var input domain.TeamMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Error("invalid team member data", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid team member data: " + err.Error()})
+		return
+	}
+
+	actorID, admin := editor(c)
+
+	if err := h.teamService.SetMember(c.Request.Context(), id, actorID, admin, userID, input.Role); err != nil {
+		h.logger.Error("failed to set team member", "id", id, "user_id", userID, "error", err)
+		h.errorResponse(c, err, "Failed to set team member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member saved successfully"})
+}
+
+// removeTeamMember исключает пользователя из команды
+// @Summary Исключить участника команды
+// @Description Исключает пользователя из команды. Доступно владельцам команды и администраторам; участник может покинуть команду сам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param id path int true "ID команды"
+// @Param user_id path int true "ID пользователя"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/teams/{id}/members/{user_id} [delete]
+func (h *TeamHandler) removeTeamMember(c *gin.Context) {
+	id, ok := h.teamID(c)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		h.logger.Error("invalid user id format", "user_id", c.Param("user_id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid user ID format"})
+		return
+	}
+
+	actorID, admin := editor(c)
+
+	if err := h.teamService.RemoveMember(c.Request.Context(), id, actorID, admin, userID); err != nil {
+		h.logger.Error("failed to remove team member", "id", id, "user_id", userID, "error", err)
+		h.errorResponse(c, err, "Failed to remove team member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Team member removed successfully"})
+}
+
+// getMyTeams возвращает команды текущего пользователя
+// @Summary Получить свои команды
+// @Description Возвращает команды, в которых состоит текущий пользователь, вместе с его ролью
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.UserTeam
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/users/teams [get]
+func (h *TeamHandler) getMyTeams(c *gin.Context) {
+	userID, _ := editor(c)
+
+	teams, err := h.teamService.GetUserTeams(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to get user teams", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get teams"})
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// teamID разбирает ID команды из пути; при ошибке отправляет ответ 400
+func (h *TeamHandler) teamID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid team id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid team ID format"})
+		return 0, false
+	}
+	return id, true
+}
+
+// errorResponse преобразует ошибку сервиса в HTTP-ответ
+func (h *TeamHandler) errorResponse(c *gin.Context, err error, message string) {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{Message: err.Error()})
+	case strings.Contains(err.Error(), "invalid team"):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	case strings.Contains(err.Error(), "already exists"):
+		c.JSON(http.StatusConflict, ErrorResponse{Message: err.Error()})
+	case strings.Contains(err.Error(), "permission denied"):
+		c.JSON(http.StatusForbidden, ErrorResponse{Message: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: message})
+	}
+}
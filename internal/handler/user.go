@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
@@ -14,9 +15,10 @@ import (
 
 // UserHandler обрабатывает HTTP-запросы, связанные с пользователями
 type UserHandler struct {
-	userService UserService
-	logger      *slog.Logger
-	middleware  *Middleware
+	userService    UserService
+	accountService AccountService
+	logger         *slog.Logger
+	middleware     *Middleware
 }
 
 // UserService интерфейс сервиса пользователей
@@ -26,17 +28,30 @@ type UserService interface {
 	Delete(ctx context.Context, id int) error
 	AddBookmark(ctx context.Context, userID, mangaID int) error
 	RemoveBookmark(ctx context.Context, userID, mangaID int) error
-	GetBookmarks(ctx context.Context, userID int) ([]domain.Manga, error)
+	GetBookmarks(ctx context.Context, userID int, cursor string, limit int) (domain.BookmarkPage, error)
 	SaveReadHistory(ctx context.Context, history domain.ReadHistory) error
-	GetReadHistory(ctx context.Context, userID int) ([]domain.ReadHistory, error)
+	GetReadHistory(ctx context.Context, userID int, cursor string, limit int) (domain.ReadHistoryPage, error)
+	GetContinueReading(ctx context.Context, userID int) ([]domain.ContinueReading, error)
+}
+
+// AccountService интерфейс управления учетными записями пользователей для администраторов
+type AccountService interface {
+	SessionService
+	UnlockAccount(ctx context.Context, adminID, userID int) error
 }
 
 // NewUserHandler создает новый экземпляр UserHandler
-func NewUserHandler(userService UserService, middleware *Middleware, logger *slog.Logger) *UserHandler {
+func NewUserHandler(
+	userService UserService,
+	accountService AccountService,
+	middleware *Middleware,
+	logger *slog.Logger,
+) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		middleware:  middleware,
-		logger:      logger,
+		userService:    userService,
+		accountService: accountService,
+		middleware:     middleware,
+		logger:         logger,
 	}
 }
 
@@ -46,7 +61,6 @@ func (h *UserHandler) Register(router *gin.RouterGroup) {
 	{
 		// Пути, требующие аутентификации
 		authenticated := users.Group("/")
-		authenticated.Use(h.middleware.JWTAuth())
 		{
 			authenticated.GET("/profile", h.getUserProfile)
 			authenticated.PUT("/profile", h.updateUserProfile)
@@ -66,15 +80,20 @@ func (h *UserHandler) Register(router *gin.RouterGroup) {
 				history.GET("", h.getUserReadHistory)
 				history.POST("", h.saveUserReadHistory)
 			}
+
+			// Полка "Продолжить чтение"
+			authenticated.GET("/continue", h.getContinueReading)
 		}
 
 		// Пути, требующие прав администратора
 		admin := users.Group("/")
-		admin.Use(h.middleware.JWTAuth(), h.middleware.RoleAuth("admin"))
 		{
 			admin.GET("/:id", h.getUserByID)
 			admin.PUT("/:id", h.updateUser)
 			admin.DELETE("/:id", h.deleteUser)
+			admin.GET("/:id/sessions", h.getUserSessions)
+			admin.DELETE("/:id/sessions/:session_id", h.revokeUserSession)
+			admin.DELETE("/:id/lock", h.unlockUser)
 		}
 	}
 }
@@ -183,11 +202,14 @@ func (h *UserHandler) deleteUserProfile(c *gin.Context) {
 
 // getUserBookmarks возвращает закладки текущего пользователя
 // @Summary Получить закладки пользователя
-// @Description Возвращает список закладок текущего аутентифицированного пользователя
+// @Description Возвращает закладки текущего аутентифицированного пользователя от новых к старым с курсорной пагинацией
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} domain.Manga
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество закладок (по умолчанию 50, максимум 200)"
+// @Success 200 {object} domain.BookmarkPage
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Security ApiKeyAuth
@@ -196,9 +218,15 @@ func (h *UserHandler) getUserBookmarks(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	id := userID.(int)
 
-	bookmarks, err := h.userService.GetBookmarks(c.Request.Context(), id)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	bookmarks, err := h.userService.GetBookmarks(c.Request.Context(), id, c.Query("cursor"), limit)
 	if err != nil {
 		h.logger.Error("failed to get user bookmarks", "id", id, "error", err)
+		if strings.Contains(err.Error(), "invalid cursor") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get user bookmarks: " + err.Error()})
 		return
 	}
@@ -280,11 +308,14 @@ func (h *UserHandler) removeUserBookmark(c *gin.Context) {
 
 // getUserReadHistory возвращает историю чтения пользователя
 // @Summary Получить историю чтения
-// @Description Возвращает историю чтения текущего аутентифицированного пользователя
+// @Description Возвращает историю чтения текущего аутентифицированного пользователя от новых записей к старым с курсорной пагинацией
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} domain.ReadHistory
+// @Param cursor query string false "Курсор следующей страницы (next_cursor из предыдущего ответа)"
+// @Param limit query int false "Количество записей (по умолчанию 50, максимум 200)"
+// @Success 200 {object} domain.ReadHistoryPage
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Security ApiKeyAuth
@@ -293,9 +324,15 @@ func (h *UserHandler) getUserReadHistory(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 	id := userID.(int)
 
-	history, err := h.userService.GetReadHistory(c.Request.Context(), id)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	history, err := h.userService.GetReadHistory(c.Request.Context(), id, c.Query("cursor"), limit)
 	if err != nil {
 		h.logger.Error("failed to get read history", "user_id", id, "error", err)
+		if strings.Contains(err.Error(), "invalid cursor") {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get read history: " + err.Error()})
 		return
 	}
@@ -303,6 +340,31 @@ func (h *UserHandler) getUserReadHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+// getContinueReading возвращает позиции для продолжения чтения
+// @Summary Продолжить чтение
+// @Description Возвращает для каждой начатой манги последнюю прочитанную главу и страницу, следующую непрочитанную главу и количество непрочитанных глав
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 200 {array} domain.ContinueReading
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/users/continue [get]
+func (h *UserHandler) getContinueReading(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id := userID.(int)
+
+	shelf, err := h.userService.GetContinueReading(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get continue reading", "user_id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get continue reading: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shelf)
+}
+
 // saveUserReadHistory сохраняет историю чтения пользователя
 // @Summary Сохранить историю чтения
 // @Description Сохраняет историю чтения текущего аутентифицированного пользователя
@@ -457,6 +519,101 @@ func (h *UserHandler) deleteUser(c *gin.Context) {
 	})
 }
 
+// getUserSessions возвращает активные сессии пользователя (только для администраторов)
+// @Summary Сессии пользователя
+// @Description Возвращает устройства, с которых пользователь выполнил вход (требуются права администратора)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {array} domain.Session
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/users/{id}/sessions [get]
+func (h *UserHandler) getUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid user id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid user ID format"})
+		return
+	}
+
+	sessions, err := h.accountService.ListSessions(c.Request.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to get user sessions", "id", id, "error", err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to get sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// revokeUserSession завершает сессию пользователя (только для администраторов)
+// @Summary Завершить сессию пользователя
+// @Description Отзывает refresh-токены сессии пользователя (требуются права администратора)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param session_id path string true "ID сессии"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/users/{id}/sessions/{session_id} [delete]
+func (h *UserHandler) revokeUserSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid user id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid user ID format"})
+		return
+	}
+
+	revokeSession(c, h.accountService, h.logger, id, c.Param("session_id"))
+}
+
+// unlockUser снимает блокировку входа пользователя (только для администраторов)
+// @Summary Разблокировать вход пользователя
+// @Description Снимает блокировку и задержку входа, наложенные после неудачных попыток ввода пароля (требуются права администратора)
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security ApiKeyAuth
+// @Router /api/users/{id}/lock [delete]
+func (h *UserHandler) unlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("invalid user id format", "id", c.Param("id"))
+		c.JSON(http.StatusBadRequest, ErrorResponse{Message: "Invalid user ID format"})
+		return
+	}
+
+	err = h.accountService.UnlockAccount(c.Request.Context(), getUserIDFromContext(c), id)
+	if err != nil {
+		h.logger.Error("failed to unlock user", "id", id, "error", err)
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, ErrorResponse{Message: "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Message: "Failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
 // Now возвращает текущее время (для удобства мокирования в тестах)
 func Now() time.Time {
 	return service.Now()
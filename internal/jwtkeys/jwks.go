@@ -0,0 +1,57 @@
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWKS набор открытых ключей в формате RFC 7517
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`   // Модуль RSA
+	E   string `json:"e,omitempty"`   // Экспонента RSA
+	Crv string `json:"crv,omitempty"` // Кривая EC или OKP
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwk возвращает открытую часть ключа в формате JWK
+func (k *Key) jwk() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(pub.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// Координаты дополняются нулями до размера кривой (RFC 7518, раздел 6.2.1.2)
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encode(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encode(pub)
+	}
+
+	return jwk
+}
+
+// encode кодирует значение в base64url без выравнивания
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,268 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Config настройки ключей подписи JWT
+type Config struct {
+	Algorithm string // Алгоритм подписи новых токенов
+	Secret    string // Общий секрет для HS256
+	Dir       string // Каталог с ключами <kid>.pem для асимметричных алгоритмов
+	ActiveKey string // kid ключа, которым подписываются новые токены
+
+	// AcceptSecret разрешает проверять токены HS256 по Secret при асимметричном алгоритме,
+	// чтобы переход с HS256 не завершил сессии пользователей
+	AcceptSecret bool
+}
+
+// Key ключ подписи или проверки JWT
+type Key struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	private   crypto.PrivateKey // nil для ключей, которые только проверяют подпись
+	public    crypto.PublicKey
+	secret    []byte
+}
+
+// KeySet набор ключей: активный ключ подписывает новые токены, остальные только проверяют
+// выпущенные ранее, поэтому ротация ключа не завершает сессии пользователей
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	legacy *Key // Общий секрет HS256 для токенов без kid
+}
+
+// Load загружает ключи согласно настройкам
+func Load(cfg Config) (*KeySet, error) {
+	if cfg.Algorithm == "" || cfg.Algorithm == HS256 {
+		if cfg.Secret == "" {
+			return nil, errors.New("jwt secret is required for HS256")
+		}
+		key := newSecretKey(cfg.Secret)
+		return &KeySet{active: key, keys: map[string]*Key{}, legacy: key}, nil
+	}
+
+	if cfg.Algorithm != RS256 && cfg.Algorithm != ES256 && cfg.Algorithm != EdDSA {
+		return nil, fmt.Errorf("unsupported jwt signing algorithm: %s", cfg.Algorithm)
+	}
+
+	keys, err := loadDir(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	active, ok := keys[cfg.ActiveKey]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q not found in %s", cfg.ActiveKey, cfg.Dir)
+	}
+	if active.private == nil {
+		return nil, fmt.Errorf("active jwt key %q has no private key", active.ID)
+	}
+	if active.Algorithm != cfg.Algorithm {
+		return nil, fmt.Errorf("active jwt key %q is %s, expected %s", active.ID, active.Algorithm, cfg.Algorithm)
+	}
+
+	set := &KeySet{active: active, keys: keys}
+	if cfg.AcceptSecret && cfg.Secret != "" {
+		set.legacy = newSecretKey(cfg.Secret)
+	}
+
+	return set, nil
+}
+
+// Active возвращает ключ, которым подписываются новые токены
+func (s *KeySet) Active() *Key {
+	return s.active
+}
+
+// Sign подписывает claims активным ключом и указывает его kid в заголовке
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.method, claims)
+	if s.active.ID != "" {
+		token.Header["kid"] = s.active.ID
+	}
+
+	if s.active.secret != nil {
+		return token.SignedString(s.active.secret)
+	}
+	return token.SignedString(s.active.private)
+}
+
+// Keyfunc выбирает ключ проверки по kid из заголовка токена для jwt.Parse
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	var key *Key
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key = s.keys[kid]
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+	} else {
+		key = s.legacy
+		if key == nil {
+			return nil, errors.New("token has no key id")
+		}
+	}
+
+	// Алгоритм берется из ключа, а не из токена, иначе можно подписать токен
+	// открытым ключом как секретом HS256
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	if key.secret != nil {
+		return key.secret, nil
+	}
+	return key.public, nil
+}
+
+// Methods возвращает алгоритмы, которые могут встретиться в действительных токенах
+func (s *KeySet) Methods() []string {
+	seen := make(map[string]bool)
+	if s.legacy != nil {
+		seen[s.legacy.Algorithm] = true
+	}
+	for _, key := range s.keys {
+		seen[key.Algorithm] = true
+	}
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// JWKS возвращает открытые ключи для /.well-known/jwks.json. Секреты HS256 не публикуются.
+func (s *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		set.Keys = append(set.Keys, s.keys[id].jwk())
+	}
+	return set
+}
+
+// newSecretKey создает ключ HS256 без kid
+func newSecretKey(secret string) *Key {
+	return &Key{Algorithm: HS256, method: jwt.SigningMethodHS256, secret: []byte(secret)}
+}
+
+// loadDir загружает ключи из файлов <kid>.pem каталога dir
+func loadDir(dir string) (map[string]*Key, error) {
+	if dir == "" {
+		return nil, errors.New("jwt keys directory is required for asymmetric signing")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jwt keys: %w", err)
+	}
+
+	keys := make(map[string]*Key, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt key %s: %w", path, err)
+		}
+
+		key, err := parseKey(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwt key %s: %w", path, err)
+		}
+		keys[id] = key
+	}
+
+	return keys, nil
+}
+
+// parseKey разбирает закрытый (PKCS#8, PKCS#1, SEC 1) или открытый (PKIX) ключ в формате PEM.
+// Файл с открытым ключом оставляет возможность проверять токены ключа, закрытая часть которого уничтожена.
+func parseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.private, key.public = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.private, key.public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.private, key.public = k, k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("rsa key must be at least 2048 bits")
+		}
+		key.Algorithm, key.method = RS256, jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("ecdsa key must use curve P-256")
+		}
+		key.Algorithm, key.method = ES256, jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.Algorithm, key.method = EdDSA, jwt.SigningMethodEdDSA
+	}
+
+	return key, nil
+}
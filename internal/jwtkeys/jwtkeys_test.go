@@ -0,0 +1,334 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// writeKey сохраняет закрытый ключ или, если public, только его открытую часть в файл <kid>.pem
+func writeKey(t *testing.T, dir, kid string, key crypto.Signer, public bool) {
+	t.Helper()
+
+	var (
+		block *pem.Block
+		err   error
+		der   []byte
+	)
+	if public {
+		der, err = x509.MarshalPKIXPublicKey(key.Public())
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	if err != nil {
+		t.Fatalf("failed to marshal key %s: %v", kid, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("failed to write key %s: %v", kid, err)
+	}
+}
+
+func newECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+	return key
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	return key
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+// parse проверяет токен только через Keyfunc, без ограничения алгоритмов в парсере
+func parse(set *KeySet, token string) error {
+	_, err := jwt.Parse(token, set.Keyfunc)
+	return err
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "es", newECKey(t), false)
+	writeKey(t, dir, "es-public", newECKey(t), true)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	writeKey(t, dir, "ed", edKey, false)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+		wantAlg string
+	}{
+		{name: "secret", cfg: Config{Secret: testSecret}, wantAlg: HS256},
+		{name: "explicit HS256", cfg: Config{Algorithm: HS256, Secret: testSecret}, wantAlg: HS256},
+		{name: "missing secret", cfg: Config{Algorithm: HS256}, wantErr: "jwt secret is required"},
+		{name: "unsupported algorithm", cfg: Config{Algorithm: "PS256", Dir: dir, ActiveKey: "es"}, wantErr: "unsupported jwt signing algorithm"},
+		{name: "missing directory", cfg: Config{Algorithm: ES256, ActiveKey: "es"}, wantErr: "directory is required"},
+		{name: "ES256", cfg: Config{Algorithm: ES256, Dir: dir, ActiveKey: "es"}, wantAlg: ES256},
+		{name: "EdDSA", cfg: Config{Algorithm: EdDSA, Dir: dir, ActiveKey: "ed"}, wantAlg: EdDSA},
+		{name: "unknown active key", cfg: Config{Algorithm: ES256, Dir: dir, ActiveKey: "missing"}, wantErr: `active jwt key "missing" not found`},
+		{name: "empty active key", cfg: Config{Algorithm: ES256, Dir: dir}, wantErr: `active jwt key "" not found`},
+		{name: "active key without private key", cfg: Config{Algorithm: ES256, Dir: dir, ActiveKey: "es-public"}, wantErr: "has no private key"},
+		{name: "active key algorithm mismatch", cfg: Config{Algorithm: ES256, Dir: dir, ActiveKey: "ed"}, wantErr: "is EdDSA, expected ES256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := Load(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if set.Active().Algorithm != tt.wantAlg {
+				t.Fatalf("active algorithm = %s, want %s", set.Active().Algorithm, tt.wantAlg)
+			}
+
+			token, err := set.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if err := parse(set, token); err != nil {
+				t.Fatalf("token signed by the active key is rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   func(t *testing.T, dir string)
+		wantErr string
+	}{
+		{
+			name: "not PEM",
+			write: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "bad.pem"), []byte("not a key"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "no PEM block found",
+		},
+		{
+			name:    "short RSA key",
+			write:   func(t *testing.T, dir string) { writeKey(t, dir, "bad", newRSAKey(t, 1024), false) },
+			wantErr: "at least 2048 bits",
+		},
+		{
+			name: "wrong curve",
+			write: func(t *testing.T, dir string) {
+				key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeKey(t, dir, "bad", key, false)
+			},
+			wantErr: "curve P-256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "es", newECKey(t), false)
+			tt.write(t, dir)
+
+			_, err := Load(Config{Algorithm: ES256, Dir: dir, ActiveKey: "es"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyfuncRejectsAlgorithmConfusion(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t, 2048)
+	writeKey(t, dir, "rsa", rsaKey, false)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	// hs256 подписывает токен как HS256 секретом secret с указанным kid
+	hs256 := func(kid string, secret []byte) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	for _, acceptSecret := range []bool{false, true} {
+		set, err := Load(Config{Algorithm: RS256, Dir: dir, ActiveKey: "rsa", Secret: testSecret, AcceptSecret: acceptSecret})
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		// Открытый ключ RSA известен всем, поэтому не должен приниматься как секрет HS256
+		for _, secret := range [][]byte{publicPEM, publicDER} {
+			if err := parse(set, hs256("rsa", secret)); err == nil {
+				t.Errorf("accept secret %v: HS256 token signed with the RSA public key is accepted", acceptSecret)
+			}
+		}
+
+		// Секрет HS256 не действует для kid асимметричного ключа
+		if err := parse(set, hs256("rsa", []byte(testSecret))); err == nil {
+			t.Errorf("accept secret %v: HS256 token with RSA kid is accepted", acceptSecret)
+		}
+
+		// Токены HS256 без kid принимаются только при JWT_ACCEPT_SECRET
+		err = parse(set, hs256("", []byte(testSecret)))
+		if acceptSecret && err != nil {
+			t.Errorf("legacy HS256 token is rejected: %v", err)
+		}
+		if !acceptSecret && err == nil {
+			t.Error("legacy HS256 token is accepted without AcceptSecret")
+		}
+	}
+}
+
+func TestKeyfuncRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKey := newECKey(t), newECKey(t)
+	writeKey(t, dir, "old", oldKey, false)
+
+	oldSet, err := Load(Config{Algorithm: ES256, Dir: dir, ActiveKey: "old"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	oldToken, err := oldSet.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	// Новый ключ становится активным, от старого остается только открытая часть
+	writeKey(t, dir, "old", oldKey, true)
+	writeKey(t, dir, "new", newKey, false)
+
+	set, err := Load(Config{Algorithm: ES256, Dir: dir, ActiveKey: "new"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if err := parse(set, oldToken); err != nil {
+		t.Errorf("token signed by the retired key is rejected: %v", err)
+	}
+
+	newToken, err := set.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := parse(set, newToken); err != nil {
+		t.Errorf("token signed by the active key is rejected: %v", err)
+	}
+
+	// Токен с неизвестным kid
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, testClaims())
+	token.Header["kid"] = "unknown"
+	unknownToken, err := token.SignedString(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parse(set, unknownToken); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Errorf("token with unknown kid: error = %v, want unknown signing key", err)
+	}
+
+	// После удаления старого ключа его токены больше не принимаются
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatal(err)
+	}
+	set, err = Load(Config{Algorithm: ES256, Dir: dir, ActiveKey: "new"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := parse(set, oldToken); err == nil {
+		t.Error("token signed by the removed key is accepted")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "rsa", newRSAKey(t, 2048), false)
+	writeKey(t, dir, "es", newECKey(t), true)
+
+	set, err := Load(Config{Algorithm: RS256, Dir: dir, ActiveKey: "rsa", Secret: testSecret, AcceptSecret: true})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	jwks := set.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2", len(jwks.Keys))
+	}
+
+	want := map[string]string{"es": "EC", "rsa": "RSA"}
+	for _, key := range jwks.Keys {
+		if want[key.Kid] != key.Kty || key.Use != "sig" {
+			t.Errorf("unexpected key %+v", key)
+		}
+	}
+
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"oct"`) || strings.Contains(string(data), `"d"`) ||
+		strings.Contains(string(data), testSecret) {
+		t.Errorf("JWKS exposes secret material: %s", data)
+	}
+
+	// При подписи HS256 публиковать нечего
+	hsSet, err := Load(Config{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if keys := hsSet.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("HS256 JWKS = %+v, want no keys", keys)
+	}
+}
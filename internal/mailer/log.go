@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LogMailer не отправляет письма, а пишет их в лог и, если указан каталог, в файлы .eml.
+// Используется в локальной разработке и тестах.
+type LogMailer struct {
+	from   string
+	dir    string
+	logger *slog.Logger
+	seq    atomic.Int64
+}
+
+// NewLogMailer создает Mailer, записывающий письма в лог и каталог dir
+func NewLogMailer(from, dir string, logger *slog.Logger) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create mail directory: %w", err)
+		}
+	}
+
+	return &LogMailer{
+		from:   from,
+		dir:    dir,
+		logger: logger,
+	}, nil
+}
+
+// Send записывает письмо
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Info("email message", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s_%03d_%s.eml",
+		time.Now().Format("20060102T150405"), m.seq.Add(1)%1000, sanitizeAddress(msg.To))
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0644); err != nil {
+		return fmt.Errorf("failed to write email file: %w", err)
+	}
+
+	return nil
+}
+
+// sanitizeAddress делает адрес пригодным для имени файла
+func sanitizeAddress(address string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		case r == '@':
+			return '_'
+		default:
+			return -1
+		}
+	}, address)
+}
@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(migrationsFS)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: version %d, want %d (versions must be contiguous from 1)", m.Version, m.Name, m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" {
+			t.Errorf("migration %d_%s: empty up file", m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s: empty down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(data string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(data)} }
+
+	fsys := fstest.MapFS{
+		"migrations/0002_second.up.sql":   file("CREATE TABLE b();"),
+		"migrations/0002_second.down.sql": file("DROP TABLE b;"),
+		"migrations/0001_first.up.sql":    file("CREATE TABLE a();"),
+		"migrations/0001_first.down.sql":  file("DROP TABLE a;"),
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "first", Up: "CREATE TABLE a();", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "second", Up: "CREATE TABLE b();", Down: "DROP TABLE b;"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(migrations), len(want))
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	tests := []struct {
+		name  string
+		files []string
+		err   string
+	}{
+		{
+			name:  "no direction",
+			files: []string{"migrations/0001_init.sql"},
+			err:   "invalid migration file name",
+		},
+		{
+			name:  "no version",
+			files: []string{"migrations/init.up.sql"},
+			err:   "invalid migration file name",
+		},
+		{
+			name:  "uppercase name",
+			files: []string{"migrations/0001_Init.up.sql", "migrations/0001_Init.down.sql"},
+			err:   "invalid migration file name",
+		},
+		{
+			name:  "zero version",
+			files: []string{"migrations/0000_init.up.sql", "migrations/0000_init.down.sql"},
+			err:   "invalid migration version",
+		},
+		{
+			name: "duplicate version with different names",
+			files: []string{
+				"migrations/0001_init.up.sql", "migrations/0001_init.down.sql",
+				"migrations/0001_users.up.sql", "migrations/0001_users.down.sql",
+			},
+			err: "duplicate migration version 1",
+		},
+		{
+			name: "duplicate version with different padding",
+			files: []string{
+				"migrations/0001_init.up.sql", "migrations/0001_init.down.sql",
+				"migrations/1_init.up.sql",
+			},
+			err: "duplicate migration file for version 1",
+		},
+		{
+			name:  "missing down file",
+			files: []string{"migrations/0001_init.up.sql"},
+			err:   "must have both up and down files",
+		},
+		{
+			name:  "missing up file",
+			files: []string{"migrations/0001_init.down.sql"},
+			err:   "must have both up and down files",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys[name] = file
+			}
+
+			_, err := Load(fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Load error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
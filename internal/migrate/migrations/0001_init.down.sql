@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS read_history;
+DROP TABLE IF EXISTS bookmarks;
+DROP TABLE IF EXISTS pages;
+DROP TABLE IF EXISTS chapters;
+DROP TABLE IF EXISTS manga_genres;
+DROP TABLE IF EXISTS manga;
+DROP TABLE IF EXISTS genres;
+DROP TABLE IF EXISTS users;
+DROP FUNCTION IF EXISTS update_timestamp();
@@ -1,3 +1,6 @@
+-- Начальная схема. Все операторы идемпотентны, чтобы миграцию можно было
+-- применить к базе, созданной ранее скриптом scripts/init.sql.
+
 -- Создание таблицы пользователей
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
@@ -81,15 +84,15 @@ CREATE TABLE IF NOT EXISTS read_history (
     );
 
 -- Индексы для оптимизации запросов
-CREATE INDEX idx_manga_title ON manga(title);
-CREATE INDEX idx_manga_status ON manga(status);
-CREATE INDEX idx_manga_year ON manga(year);
-CREATE INDEX idx_manga_rating ON manga(rating);
-CREATE INDEX idx_chapters_manga_id ON chapters(manga_id);
-CREATE INDEX idx_pages_chapter_id ON pages(chapter_id);
-CREATE INDEX idx_bookmarks_user_id ON bookmarks(user_id);
-CREATE INDEX idx_read_history_user_id ON read_history(user_id);
-CREATE INDEX idx_read_history_manga_id ON read_history(manga_id);
+CREATE INDEX IF NOT EXISTS idx_manga_title ON manga(title);
+CREATE INDEX IF NOT EXISTS idx_manga_status ON manga(status);
+CREATE INDEX IF NOT EXISTS idx_manga_year ON manga(year);
+CREATE INDEX IF NOT EXISTS idx_manga_rating ON manga(rating);
+CREATE INDEX IF NOT EXISTS idx_chapters_manga_id ON chapters(manga_id);
+CREATE INDEX IF NOT EXISTS idx_pages_chapter_id ON pages(chapter_id);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id ON bookmarks(user_id);
+CREATE INDEX IF NOT EXISTS idx_read_history_user_id ON read_history(user_id);
+CREATE INDEX IF NOT EXISTS idx_read_history_manga_id ON read_history(manga_id);
 
 -- Вставка начальных жанров
 INSERT INTO genres (name) VALUES
@@ -120,17 +123,20 @@ END;
 $$ LANGUAGE plpgsql;
 
 -- Добавление триггеров для автоматического обновления updated_at
+DROP TRIGGER IF EXISTS update_users_timestamp ON users;
 CREATE TRIGGER update_users_timestamp
     BEFORE UPDATE ON users
     FOR EACH ROW
     EXECUTE FUNCTION update_timestamp();
 
+DROP TRIGGER IF EXISTS update_manga_timestamp ON manga;
 CREATE TRIGGER update_manga_timestamp
     BEFORE UPDATE ON manga
     FOR EACH ROW
     EXECUTE FUNCTION update_timestamp();
 
+DROP TRIGGER IF EXISTS update_chapters_timestamp ON chapters;
 CREATE TRIGGER update_chapters_timestamp
     BEFORE UPDATE ON chapters
     FOR EACH ROW
-    EXECUTE FUNCTION update_timestamp();
\ No newline at end of file
+    EXECUTE FUNCTION update_timestamp();
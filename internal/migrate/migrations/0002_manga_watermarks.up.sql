@@ -0,0 +1,12 @@
+-- Таблица настроек водяного знака для манги
+CREATE TABLE IF NOT EXISTS manga_watermarks (
+    manga_id INTEGER PRIMARY KEY REFERENCES manga(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    text VARCHAR(255) NOT NULL DEFAULT '',
+    logo BYTEA DEFAULT NULL,
+    position VARCHAR(20) NOT NULL DEFAULT 'bottom-right',
+    opacity DECIMAL(3,2) NOT NULL DEFAULT 0.5,
+    scale DECIMAL(3,2) NOT NULL DEFAULT 0.2,
+    apply_on VARCHAR(20) NOT NULL DEFAULT 'rendition',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
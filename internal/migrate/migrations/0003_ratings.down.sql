@@ -0,0 +1,13 @@
+-- Приводим оценку к прежнему типу, пока триггер не учитывает изменение rating в updated_at
+UPDATE manga SET rating = LEAST(rating, 9.99) WHERE rating > 9.99;
+
+DROP TRIGGER IF EXISTS update_manga_timestamp ON manga;
+CREATE TRIGGER update_manga_timestamp
+    BEFORE UPDATE ON manga
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+DROP FUNCTION IF EXISTS update_manga_timestamp();
+
+ALTER TABLE manga DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE manga ALTER COLUMN rating TYPE DECIMAL(3,2);
+DROP TABLE IF EXISTS ratings;
@@ -0,0 +1,40 @@
+-- Оценки манги пользователями (один голос на пользователя)
+CREATE TABLE IF NOT EXISTS ratings (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    manga_id INTEGER NOT NULL REFERENCES manga(id) ON DELETE CASCADE,
+    score SMALLINT NOT NULL CHECK (score BETWEEN 1 AND 10),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, manga_id)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_ratings_manga_id ON ratings(manga_id);
+
+DROP TRIGGER IF EXISTS update_ratings_timestamp ON ratings;
+CREATE TRIGGER update_ratings_timestamp
+    BEFORE UPDATE ON ratings
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+-- manga.rating становится средней оценкой пользователей (до 10.00 включительно)
+ALTER TABLE manga ALTER COLUMN rating TYPE DECIMAL(4,2);
+ALTER TABLE manga ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;
+
+-- Пересчет оценки не должен менять updated_at, по которому сортируются обновления манги
+CREATE OR REPLACE FUNCTION update_manga_timestamp()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (to_jsonb(NEW) - 'rating' - 'rating_count' - 'updated_at') =
+       (to_jsonb(OLD) - 'rating' - 'rating_count' - 'updated_at') THEN
+        RETURN NEW;
+    END IF;
+    NEW.updated_at = CURRENT_TIMESTAMP;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_manga_timestamp ON manga;
+CREATE TRIGGER update_manga_timestamp
+    BEFORE UPDATE ON manga
+    FOR EACH ROW
+    EXECUTE FUNCTION update_manga_timestamp();
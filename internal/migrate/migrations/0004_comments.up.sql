@@ -0,0 +1,31 @@
+-- Комментарии к манге и главам
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    manga_id INTEGER NOT NULL REFERENCES manga(id) ON DELETE CASCADE,
+    chapter_id INTEGER REFERENCES chapters(id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    spoiler BOOLEAN NOT NULL DEFAULT FALSE,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    hidden_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP DEFAULT NULL,
+    deleted_at TIMESTAMP DEFAULT NULL,
+    deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+    );
+
+CREATE INDEX IF NOT EXISTS idx_comments_manga_id ON comments(manga_id, id) WHERE chapter_id IS NULL AND parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_chapter_id ON comments(chapter_id, id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id, id);
+
+-- Закрытые ветки комментариев (chapter_id = NULL - ветка манги)
+CREATE TABLE IF NOT EXISTS comment_locks (
+    id SERIAL PRIMARY KEY,
+    manga_id INTEGER NOT NULL REFERENCES manga(id) ON DELETE CASCADE,
+    chapter_id INTEGER REFERENCES chapters(id) ON DELETE CASCADE,
+    locked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    locked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_comment_locks_target ON comment_locks(manga_id, COALESCE(chapter_id, 0));
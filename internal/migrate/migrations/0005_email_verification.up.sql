@@ -0,0 +1,3 @@
+-- Подтверждение email; существующие пользователи считаются подтвержденными
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET email_verified = TRUE;
@@ -0,0 +1,12 @@
+-- Внешние учетные записи (OpenID Connect), привязанные к пользователям
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(128) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
@@ -0,0 +1,23 @@
+-- Расширение pg_trgm не удаляется: оно может использоваться вне приложения
+DROP INDEX IF EXISTS idx_manga_author_trgm;
+DROP INDEX IF EXISTS idx_manga_alter_title_trgm;
+DROP INDEX IF EXISTS idx_manga_title_trgm;
+DROP INDEX IF EXISTS idx_manga_search_vector;
+DROP TRIGGER IF EXISTS manga_genres_search_vector_update ON manga_genres;
+DROP TRIGGER IF EXISTS manga_search_vector_update ON manga;
+DROP FUNCTION IF EXISTS manga_genres_search_vector_update();
+DROP FUNCTION IF EXISTS manga_search_vector_update();
+DROP FUNCTION IF EXISTS manga_search_document(INTEGER, TEXT, TEXT, TEXT, TEXT, TEXT);
+ALTER TABLE manga DROP COLUMN IF EXISTS search_vector;
+
+CREATE OR REPLACE FUNCTION update_manga_timestamp()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (to_jsonb(NEW) - 'rating' - 'rating_count' - 'updated_at') =
+       (to_jsonb(OLD) - 'rating' - 'rating_count' - 'updated_at') THEN
+        RETURN NEW;
+    END IF;
+    NEW.updated_at = CURRENT_TIMESTAMP;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
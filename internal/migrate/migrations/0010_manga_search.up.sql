@@ -0,0 +1,88 @@
+-- Полнотекстовый поиск по манге. Используется конфигурация simple без стемминга: названия и имена
+-- авторов ищутся как есть, а опечатки и частичные слова покрывает триграммный поиск pg_trgm.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE manga ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+-- Пересчет поискового документа не должен менять updated_at, как и пересчет оценки
+CREATE OR REPLACE FUNCTION update_manga_timestamp()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (to_jsonb(NEW) - 'rating' - 'rating_count' - 'search_vector' - 'updated_at') =
+       (to_jsonb(OLD) - 'rating' - 'rating_count' - 'search_vector' - 'updated_at') THEN
+        RETURN NEW;
+    END IF;
+    NEW.updated_at = CURRENT_TIMESTAMP;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Поисковый документ манги с весами: названия - A, автор, художник и жанры - B, описание - C
+CREATE OR REPLACE FUNCTION manga_search_document(
+    p_id INTEGER,
+    p_title TEXT,
+    p_alter_title TEXT,
+    p_author TEXT,
+    p_artist TEXT,
+    p_description TEXT
+)
+RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('simple', COALESCE(p_title, '') || ' ' || COALESCE(p_alter_title, '')), 'A')
+        || setweight(to_tsvector('simple', COALESCE(p_author, '') || ' ' || COALESCE(p_artist, '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE((
+            SELECT string_agg(g.name, ' ')
+            FROM manga_genres mg
+            JOIN genres g ON g.id = mg.genre_id
+            WHERE mg.manga_id = p_id
+        ), '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(p_description, '')), 'C');
+$$ LANGUAGE sql STABLE;
+
+-- Пересчет документа при изменении полей манги
+CREATE OR REPLACE FUNCTION manga_search_vector_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector = manga_search_document(NEW.id, NEW.title, NEW.alter_title, NEW.author, NEW.artist, NEW.description);
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS manga_search_vector_update ON manga;
+CREATE TRIGGER manga_search_vector_update
+    BEFORE INSERT OR UPDATE OF title, alter_title, author, artist, description ON manga
+    FOR EACH ROW
+    EXECUTE FUNCTION manga_search_vector_update();
+
+-- Пересчет документа при изменении жанров манги
+CREATE OR REPLACE FUNCTION manga_genres_search_vector_update()
+RETURNS TRIGGER AS $$
+DECLARE
+    target_id INTEGER;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_id = OLD.manga_id;
+    ELSE
+        target_id = NEW.manga_id;
+    END IF;
+
+    UPDATE manga
+    SET search_vector = manga_search_document(id, title, alter_title, author, artist, description)
+    WHERE id = target_id;
+RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS manga_genres_search_vector_update ON manga_genres;
+CREATE TRIGGER manga_genres_search_vector_update
+    AFTER INSERT OR DELETE ON manga_genres
+    FOR EACH ROW
+    EXECUTE FUNCTION manga_genres_search_vector_update();
+
+-- Заполнение документа для существующей манги
+UPDATE manga
+SET search_vector = manga_search_document(id, title, alter_title, author, artist, description);
+
+CREATE INDEX IF NOT EXISTS idx_manga_search_vector ON manga USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_manga_title_trgm ON manga USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_manga_alter_title_trgm ON manga USING GIN (alter_title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_manga_author_trgm ON manga USING GIN (author gin_trgm_ops);
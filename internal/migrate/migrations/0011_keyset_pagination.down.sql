@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_read_history_user_id_read_at;
+DROP INDEX IF EXISTS idx_bookmarks_user_id_created_at;
+DROP INDEX IF EXISTS idx_chapters_manga_id_number;
+DROP INDEX IF EXISTS idx_manga_updated_at_id;
+DROP INDEX IF EXISTS idx_manga_created_at_id;
+DROP INDEX IF EXISTS idx_manga_rating_id;
+DROP INDEX IF EXISTS idx_manga_title_id;
@@ -0,0 +1,8 @@
+-- Индексы для курсорной пагинации: каждая сортировка дополняется id, чтобы порядок был однозначным
+CREATE INDEX IF NOT EXISTS idx_manga_title_id ON manga(title, id);
+CREATE INDEX IF NOT EXISTS idx_manga_rating_id ON manga((COALESCE(rating, 0)), rating_count, id);
+CREATE INDEX IF NOT EXISTS idx_manga_created_at_id ON manga(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_manga_updated_at_id ON manga(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_chapters_manga_id_number ON chapters(manga_id, number, id);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id_created_at ON bookmarks(user_id, created_at, manga_id);
+CREATE INDEX IF NOT EXISTS idx_read_history_user_id_read_at ON read_history(user_id, read_at, id);
@@ -0,0 +1,126 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderConfig настройки внешнего провайдера OpenID Connect
+type ProviderConfig struct {
+	Name         string // Имя провайдера в пути /api/auth/oidc/{name}
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Адрес /api/auth/oidc/{name}/callback, зарегистрированный у провайдера
+	Scopes       []string
+}
+
+// Claims данные пользователя из ID-токена
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Username      string `json:"preferred_username"`
+	Name          string `json:"name"`
+}
+
+// Provider выполняет вход через провайдера OpenID Connect по authorization code flow с PKCE.
+// Discovery-документ провайдера загружается при первом обращении, поэтому недоступность
+// провайдера не мешает запуску приложения.
+type Provider struct {
+	cfg ProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider создает провайдера OpenID Connect
+func NewProvider(cfg ProviderConfig) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{gooidc.ScopeOpenID, "email", "profile"}
+	}
+	return &Provider{cfg: cfg}
+}
+
+// Name возвращает имя провайдера
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера.
+// verifier - PKCE code verifier, который нужно передать в Exchange вместе с nonce.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oauth, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), gooidc.Nonce(nonce)), nil
+}
+
+// Exchange обменивает код авторизации на токены и возвращает проверенные данные из ID-токена
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	oauth, idVerifier, err := p.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Claims{}, errors.New("token response has no id_token")
+	}
+
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return Claims{}, errors.New("id_token nonce mismatch")
+	}
+
+	var claims Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return Claims{}, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+	claims.Subject = idToken.Subject
+
+	return claims, nil
+}
+
+// discover загружает discovery-документ провайдера и кеширует настройки клиента
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := gooidc.NewProvider(ctx, p.cfg.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover provider %s: %w", p.cfg.Name, err)
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = provider.Verifier(&gooidc.Config{ClientID: p.cfg.ClientID})
+
+	return p.oauth, p.verifier, nil
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval период удаления восстановившихся ключей из MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore хранит ограничения в памяти процесса
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore создает хранилище ограничений в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats: make(map[string]time.Time),
+	}
+}
+
+// Allow реализует Store
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	res, tat := result(limit, s.tats[key], now)
+	if res.Allowed {
+		s.tats[key] = tat
+	}
+
+	return res, nil
+}
+
+// sweep удаляет ключи, запас которых полностью восстановился
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit ограничение частоты запросов: не более Requests за Window.
+// Ограничение работает как token bucket (алгоритм GCRA): запас в Requests запросов
+// расходуется сразу, а затем восстанавливается равномерно по одному запросу за Window/Requests.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Unlimited сообщает, что ограничение не задано
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Window <= 0
+}
+
+// interval возвращает время восстановления одного запроса
+func (l Limit) interval() time.Duration {
+	return l.Window / time.Duration(l.Requests)
+}
+
+// String возвращает ограничение в формате заголовка RateLimit-Policy, например 10;w=60
+func (l Limit) String() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(l.Window.Seconds()))
+}
+
+// Policy ограничения для группы маршрутов: общее и отдельные для ролей
+type Policy struct {
+	Name    string
+	Default Limit
+	Roles   map[string]Limit
+}
+
+// Limit возвращает ограничение для роли; пустая роль означает анонимного пользователя
+func (p Policy) Limit(role string) Limit {
+	if limit, ok := p.Roles[role]; ok {
+		return limit
+	}
+	return p.Default
+}
+
+// Result результат проверки ограничения
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Время до полного восстановления запаса запросов
+	RetryAfter time.Duration // Время до следующего разрешенного запроса, если запрос отклонен
+}
+
+// Store хранит состояние ограничений. RedisStore нужен при нескольких экземплярах приложения,
+// MemoryStore - для одного экземпляра и тестов.
+type Store interface {
+	// Allow учитывает запрос по ключу и сообщает, разрешен ли он
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ParsePolicy разбирает политику вида "300/m;moderator=1000/m;admin=0".
+// Первое значение - ограничение по умолчанию, далее ограничения для ролей; 0 отключает ограничение.
+// Период задается как s, m, h или длительность Go (например 10s, 1h30m).
+func ParsePolicy(name, spec string) (Policy, error) {
+	policy := Policy{Name: name, Roles: make(map[string]Limit)}
+
+	for i, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		role, value, hasRole := strings.Cut(part, "=")
+		if !hasRole {
+			value = part
+		}
+
+		limit, err := parseLimit(strings.TrimSpace(value))
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid rate limit %q for %s: %w", part, name, err)
+		}
+
+		switch {
+		case hasRole:
+			policy.Roles[strings.TrimSpace(role)] = limit
+		case i == 0:
+			policy.Default = limit
+		default:
+			return Policy{}, fmt.Errorf("invalid rate limit %q for %s: role expected", part, name)
+		}
+	}
+
+	return policy, nil
+}
+
+// parseLimit разбирает ограничение вида "20/m" или "0"
+func parseLimit(value string) (Limit, error) {
+	if value == "0" {
+		return Limit{}, nil
+	}
+
+	count, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("expected <requests>/<period>")
+	}
+
+	requests, err := strconv.Atoi(count)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("invalid number of requests: %s", count)
+	}
+
+	if period != "" && period[0] >= 'a' && period[0] <= 'z' {
+		period = "1" + period
+	}
+	window, err := time.ParseDuration(period)
+	if err != nil || window <= 0 {
+		return Limit{}, fmt.Errorf("invalid period: %s", period)
+	}
+
+	return Limit{Requests: requests, Window: window}, nil
+}
+
+// result вычисляет результат проверки по времени теоретического прибытия (TAT) алгоритма GCRA.
+// tat - значение до запроса, now - текущее время; возвращает новое значение TAT.
+func result(limit Limit, tat, now time.Time) (Result, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTAT := tat.Add(limit.interval())
+	allowAt := newTAT.Add(-limit.Window)
+	if now.Before(allowAt) {
+		return Result{
+			Allowed:    false,
+			Limit:      limit.Requests,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}, tat
+	}
+
+	return allowed(limit, newTAT.Sub(now)), newTAT
+}
+
+// allowed возвращает результат для разрешенного запроса по времени до полного восстановления запаса
+func allowed(limit Limit, resetAfter time.Duration) Result {
+	return Result{
+		Allowed:    true,
+		Limit:      limit.Requests,
+		Remaining:  int((limit.Window - resetAfter) / limit.interval()),
+		ResetAfter: resetAfter,
+	}
+}
@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "20/s", want: Limit{Requests: 20, Window: time.Second}},
+		{value: "300/m", want: Limit{Requests: 300, Window: time.Minute}},
+		{value: "1000/h", want: Limit{Requests: 1000, Window: time.Hour}},
+		{value: "10/10s", want: Limit{Requests: 10, Window: 10 * time.Second}},
+		{value: "5/1h30m", want: Limit{Requests: 5, Window: 90 * time.Minute}},
+		{value: "0", want: Limit{}},
+		{value: "", wantErr: true},
+		{value: "20", wantErr: true},
+		{value: "m/20", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "20/", wantErr: true},
+		{value: "20/d", wantErr: true},
+		{value: "20/0s", wantErr: true},
+		{value: "20/-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, err := parseLimit(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseLimit(%q) = %+v, want error", tt.value, limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLimit(%q): %v", tt.value, err)
+			}
+			if limit != tt.want {
+				t.Fatalf("parseLimit(%q) = %+v, want %+v", tt.value, limit, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    Policy
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			spec: "",
+			want: Policy{Name: "api", Roles: map[string]Limit{}},
+		},
+		{
+			name: "default only",
+			spec: "300/m",
+			want: Policy{Name: "api", Default: Limit{Requests: 300, Window: time.Minute}, Roles: map[string]Limit{}},
+		},
+		{
+			name: "role overrides",
+			spec: "300/m;moderator=1000/m;admin=0",
+			want: Policy{
+				Name:    "api",
+				Default: Limit{Requests: 300, Window: time.Minute},
+				Roles: map[string]Limit{
+					"moderator": {Requests: 1000, Window: time.Minute},
+					"admin":     {},
+				},
+			},
+		},
+		{
+			name: "spaces and empty parts",
+			spec: " 10/s ; ; user = 20/s ;",
+			want: Policy{
+				Name:    "api",
+				Default: Limit{Requests: 10, Window: time.Second},
+				Roles:   map[string]Limit{"user": {Requests: 20, Window: time.Second}},
+			},
+		},
+		{
+			name: "roles without default",
+			spec: "admin=0",
+			want: Policy{Name: "api", Roles: map[string]Limit{"admin": {}}},
+		},
+		{name: "second default", spec: "10/m;20/m", wantErr: true},
+		{name: "malformed default", spec: "10 per minute", wantErr: true},
+		{name: "malformed role limit", spec: "10/m;admin=fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePolicy("api", tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParsePolicy(%q) = %+v, want error", tt.spec, policy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePolicy(%q): %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(policy, tt.want) {
+				t.Fatalf("ParsePolicy(%q) = %+v, want %+v", tt.spec, policy, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyLimit(t *testing.T) {
+	policy, err := ParsePolicy("api", "300/m;moderator=1000/m;admin=0")
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+
+	tests := []struct {
+		role      string
+		requests  int
+		unlimited bool
+	}{
+		{role: "", requests: 300},
+		{role: "user", requests: 300},
+		{role: "moderator", requests: 1000},
+		{role: "admin", unlimited: true},
+	}
+
+	for _, tt := range tests {
+		limit := policy.Limit(tt.role)
+		if limit.Unlimited() != tt.unlimited {
+			t.Errorf("role %q: Unlimited() = %v, want %v", tt.role, limit.Unlimited(), tt.unlimited)
+		}
+		if !tt.unlimited && limit.Requests != tt.requests {
+			t.Errorf("role %q: Requests = %d, want %d", tt.role, limit.Requests, tt.requests)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	limit := Limit{Requests: 3, Window: 3 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Запросы выполняются последовательно, TAT переходит от шага к шагу
+	steps := []struct {
+		name string
+		at   time.Duration // Время запроса от start
+		want Result
+	}{
+		{name: "burst 1", at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}},
+		{name: "burst 2", at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 2 * time.Second}},
+		{name: "burst 3", at: 0, want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 3 * time.Second}},
+		{name: "burst exhausted", at: 0, want: Result{Limit: 3, ResetAfter: 3 * time.Second, RetryAfter: time.Second}},
+		{name: "before refill", at: 500 * time.Millisecond, want: Result{Limit: 3, ResetAfter: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{name: "one request refilled", at: time.Second, want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 3 * time.Second}},
+		{name: "refill spent", at: time.Second, want: Result{Limit: 3, ResetAfter: 3 * time.Second, RetryAfter: time.Second}},
+		{name: "two requests refilled", at: 3 * time.Second, want: Result{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 2 * time.Second}},
+		{name: "fully refilled", at: 10 * time.Second, want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}},
+	}
+
+	var tat time.Time
+	for _, step := range steps {
+		var res Result
+		res, tat = result(limit, tat, start.Add(step.at))
+		if res != step.want {
+			t.Fatalf("%s: result = %+v, want %+v", step.name, res, step.want)
+		}
+	}
+}
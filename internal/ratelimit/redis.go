@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript проверяет ограничение по алгоритму GCRA. Время берется у Redis,
+// чтобы расхождение часов экземпляров приложения не влияло на ограничение.
+// KEYS: ключ ограничения. ARGV: время восстановления одного запроса в мкс, окно в мкс.
+// Возвращает {1 если разрешено, время до следующего разрешенного запроса в мкс, время до полного восстановления в мкс}.
+var gcraScript = redis.NewScript(`
+local now = redis.call('TIME')
+now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+local interval = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local newTat = tat + interval
+local allowAt = newTat - window
+if now < allowAt then
+	return {0, allowAt - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%.0f', newTat), 'PX', math.ceil((newTat - now) / 1000))
+return {1, 0, newTat - now}
+`)
+
+// RedisStore хранит ограничения в Redis под ключами rate_limit:<key>
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore создает хранилище ограничений в Redis
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Allow реализует Store
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	values, err := gcraScript.Run(ctx, s.client, []string{"rate_limit:" + key},
+		limit.interval().Microseconds(),
+		limit.Window.Microseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit result: %v", values)
+	}
+
+	resetAfter := time.Duration(values[2]) * time.Microsecond
+	if values[0] == 1 {
+		return allowed(limit, resetAfter), nil
+	}
+
+	return Result{
+		Allowed:    false,
+		Limit:      limit.Requests,
+		ResetAfter: resetAfter,
+		RetryAfter: time.Duration(values[1]) * time.Microsecond,
+	}, nil
+}
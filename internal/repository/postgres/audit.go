@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepo реализует интерфейс repository.AuditRepository
+type AuditRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewAuditRepo создает новый репозиторий для работы с журналом аудита
+func NewAuditRepo(db *sqlx.DB, logger *slog.Logger) *AuditRepo {
+	return &AuditRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create добавляет запись в журнал аудита
+func (r *AuditRepo) Create(ctx context.Context, entry domain.AuditEntry) (int64, error) {
+	r.logger.DebugContext(ctx, "executing Create audit entry query",
+		"action", entry.Action,
+		"entity_type", entry.EntityType,
+		"entity_id", entry.EntityID)
+
+	query := `
+		INSERT INTO audit_log (
+			actor_id, actor_name, actor_role, action, entity_type, entity_id,
+			before, after, request_id, ip
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRowContext(
+		ctx, query,
+		entry.ActorID, entry.ActorName, entry.ActorRole, entry.Action, entry.EntityType, entry.EntityID,
+		jsonValue(entry.Before), jsonValue(entry.After), entry.RequestID, entry.IP,
+	).Scan(&id)
+
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting audit entry", "error", err)
+		return 0, fmt.Errorf("error inserting audit entry: %w", err)
+	}
+
+	return id, nil
+}
+
+// List возвращает записи журнала аудита от новых к старым
+func (r *AuditRepo) List(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error) {
+	r.logger.DebugContext(ctx, "executing List audit entries query",
+		"actor_id", filter.ActorID,
+		"entity_type", filter.EntityType,
+		"entity_id", filter.EntityID,
+		"cursor", filter.Cursor)
+
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	if filter.ActorID != nil {
+		args = append(args, *filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if filter.EntityID != nil {
+		args = append(args, *filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Cursor > 0 {
+		args = append(args, filter.Cursor)
+		conditions = append(conditions, fmt.Sprintf("id < $%d", len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := `
+		SELECT id, actor_id, actor_name, actor_role, action, entity_type, entity_id,
+		before, after, request_id, ip, created_at
+		FROM audit_log
+		WHERE ` + strings.Join(conditions, " AND ") + fmt.Sprintf(`
+		ORDER BY id DESC
+		LIMIT $%d`, len(args))
+
+	var rows []auditRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting audit entries", "error", err)
+		return nil, fmt.Errorf("error selecting audit entries: %w", err)
+	}
+
+	entries := make([]domain.AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := row.AuditEntry
+		entry.Before = json.RawMessage(row.Before)
+		entry.After = json.RawMessage(row.After)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// auditRow запись журнала в виде строки таблицы. Снимки читаются в []byte: драйвер копирует их
+// из буфера соединения, а NULL становится nil
+type auditRow struct {
+	domain.AuditEntry
+	Before []byte
+	After  []byte
+}
+
+// jsonValue возвращает снимок объекта для колонки JSONB; пустой снимок сохраняется как NULL
+func jsonValue(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -25,20 +25,29 @@ func NewChapterRepo(db *sqlx.DB, logger *slog.Logger) *ChapterRepo {
 	}
 }
 
-// GetByMangaID возвращает список глав для указанной манги
-func (r *ChapterRepo) GetByMangaID(ctx context.Context, mangaID int) ([]domain.Chapter, error) {
-	r.logger.Debug("executing GetByMangaID chapters query", "manga_id", mangaID)
+// GetByMangaID возвращает главы манги по возрастанию номера, начиная после позиции after
+func (r *ChapterRepo) GetByMangaID(ctx context.Context, mangaID int, after *domain.PageCursor, limit int) ([]domain.Chapter, error) {
+	r.logger.DebugContext(ctx, "executing GetByMangaID chapters query", "manga_id", mangaID, "limit", limit)
 
 	query := `
 		SELECT id, manga_id, number, title, page_count, created_at, updated_at
 		FROM chapters
 		WHERE manga_id = $1
-		ORDER BY number
 	`
+	args := []interface{}{mangaID}
+
+	// Номер главы уникален в манге, ID добавлен для однозначного порядка
+	if after != nil {
+		query += " AND (number, id) > ($2, $3)"
+		args = append(args, after.Number, after.ID)
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY number, id LIMIT $%d", len(args))
 
 	var chapters []domain.Chapter
-	if err := r.db.SelectContext(ctx, &chapters, query, mangaID); err != nil {
-		r.logger.Error("error selecting chapters by manga_id", "manga_id", mangaID, "error", err)
+	if err := r.db.SelectContext(ctx, &chapters, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting chapters by manga_id", "manga_id", mangaID, "error", err)
 		return nil, fmt.Errorf("error selecting chapters: %w", err)
 	}
 
@@ -47,7 +56,7 @@ func (r *ChapterRepo) GetByMangaID(ctx context.Context, mangaID int) ([]domain.C
 
 // GetByID возвращает главу по ID
 func (r *ChapterRepo) GetByID(ctx context.Context, id int) (domain.Chapter, error) {
-	r.logger.Debug("executing GetByID chapter query", "id", id)
+	r.logger.DebugContext(ctx, "executing GetByID chapter query", "id", id)
 
 	query := `
 		SELECT id, manga_id, number, title, page_count, created_at, updated_at
@@ -60,7 +69,7 @@ func (r *ChapterRepo) GetByID(ctx context.Context, id int) (domain.Chapter, erro
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.Chapter{}, fmt.Errorf("chapter with id %d not found", id)
 		}
-		r.logger.Error("error selecting chapter by id", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting chapter by id", "id", id, "error", err)
 		return domain.Chapter{}, fmt.Errorf("error selecting chapter: %w", err)
 	}
 
@@ -69,7 +78,7 @@ func (r *ChapterRepo) GetByID(ctx context.Context, id int) (domain.Chapter, erro
 
 // Create создает новую главу
 func (r *ChapterRepo) Create(ctx context.Context, chapter domain.Chapter) (int, error) {
-	r.logger.Debug("executing Create chapter query",
+	r.logger.DebugContext(ctx, "executing Create chapter query",
 		"manga_id", chapter.MangaID,
 		"number", chapter.Number,
 		"title", chapter.Title)
@@ -87,7 +96,7 @@ func (r *ChapterRepo) Create(ctx context.Context, chapter domain.Chapter) (int,
 	).Scan(&id)
 
 	if err != nil {
-		r.logger.Error("error inserting chapter", "error", err)
+		r.logger.ErrorContext(ctx, "error inserting chapter", "error", err)
 		return 0, fmt.Errorf("error inserting chapter: %w", err)
 	}
 
@@ -96,7 +105,7 @@ func (r *ChapterRepo) Create(ctx context.Context, chapter domain.Chapter) (int,
 
 // Update обновляет информацию о главе
 func (r *ChapterRepo) Update(ctx context.Context, chapter domain.Chapter) error {
-	r.logger.Debug("executing Update chapter query",
+	r.logger.DebugContext(ctx, "executing Update chapter query",
 		"id", chapter.ID,
 		"title", chapter.Title,
 		"number", chapter.Number)
@@ -115,13 +124,13 @@ func (r *ChapterRepo) Update(ctx context.Context, chapter domain.Chapter) error
 	)
 
 	if err != nil {
-		r.logger.Error("error updating chapter", "id", chapter.ID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating chapter", "id", chapter.ID, "error", err)
 		return fmt.Errorf("error updating chapter: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -134,18 +143,18 @@ func (r *ChapterRepo) Update(ctx context.Context, chapter domain.Chapter) error
 
 // Delete удаляет главу по ID
 func (r *ChapterRepo) Delete(ctx context.Context, id int) error {
-	r.logger.Debug("executing Delete chapter query", "id", id)
+	r.logger.DebugContext(ctx, "executing Delete chapter query", "id", id)
 
 	query := "DELETE FROM chapters WHERE id = $1"
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		r.logger.Error("error deleting chapter", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error deleting chapter", "id", id, "error", err)
 		return fmt.Errorf("error deleting chapter: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -158,7 +167,7 @@ func (r *ChapterRepo) Delete(ctx context.Context, id int) error {
 
 // GetPages возвращает список страниц для указанной главы
 func (r *ChapterRepo) GetPages(ctx context.Context, chapterID int) ([]domain.Page, error) {
-	r.logger.Debug("executing GetPages query", "chapter_id", chapterID)
+	r.logger.DebugContext(ctx, "executing GetPages query", "chapter_id", chapterID)
 
 	query := `
 		SELECT id, chapter_id, number, image_url
@@ -169,22 +178,44 @@ func (r *ChapterRepo) GetPages(ctx context.Context, chapterID int) ([]domain.Pag
 
 	var pages []domain.Page
 	if err := r.db.SelectContext(ctx, &pages, query, chapterID); err != nil {
-		r.logger.Error("error selecting pages", "chapter_id", chapterID, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting pages", "chapter_id", chapterID, "error", err)
 		return nil, fmt.Errorf("error selecting pages: %w", err)
 	}
 
 	return pages, nil
 }
 
+// GetPage возвращает страницу по ID
+func (r *ChapterRepo) GetPage(ctx context.Context, id int) (domain.Page, error) {
+	r.logger.DebugContext(ctx, "executing GetPage query", "id", id)
+
+	query := `
+		SELECT id, chapter_id, number, image_url
+		FROM pages
+		WHERE id = $1
+	`
+
+	var page domain.Page
+	if err := r.db.GetContext(ctx, &page, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Page{}, fmt.Errorf("page with id %d not found", id)
+		}
+		r.logger.ErrorContext(ctx, "error selecting page by id", "id", id, "error", err)
+		return domain.Page{}, fmt.Errorf("error selecting page: %w", err)
+	}
+
+	return page, nil
+}
+
 // AddPage добавляет новую страницу в главу
 func (r *ChapterRepo) AddPage(ctx context.Context, page domain.Page) (int, error) {
-	r.logger.Debug("executing AddPage query",
+	r.logger.DebugContext(ctx, "executing AddPage query",
 		"chapter_id", page.ChapterID,
 		"number", page.Number)
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("error starting transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
 		return 0, fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
@@ -203,7 +234,7 @@ func (r *ChapterRepo) AddPage(ctx context.Context, page domain.Page) (int, error
 	).Scan(&id)
 
 	if err != nil {
-		r.logger.Error("error inserting page", "error", err)
+		r.logger.ErrorContext(ctx, "error inserting page", "error", err)
 		return 0, fmt.Errorf("error inserting page: %w", err)
 	}
 
@@ -215,25 +246,105 @@ func (r *ChapterRepo) AddPage(ctx context.Context, page domain.Page) (int, error
 	`, page.ChapterID)
 
 	if err != nil {
-		r.logger.Error("error updating chapter page count", "chapter_id", page.ChapterID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating chapter page count", "chapter_id", page.ChapterID, "error", err)
 		return 0, fmt.Errorf("error updating chapter page count: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		r.logger.Error("error committing transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
 		return 0, fmt.Errorf("error committing transaction: %w", err)
 	}
 
 	return id, nil
 }
 
+// AddPages добавляет несколько страниц в конец главы в рамках одной транзакции.
+// Глава заблокирована до конца транзакции: save вызывается под блокировкой с номером первой новой
+// страницы и возвращает страницы для добавления, поэтому параллельные загрузки не получают одинаковые номера.
+func (r *ChapterRepo) AddPages(ctx context.Context, chapterID int, save func(firstNumber int) ([]domain.Page, error)) ([]int, error) {
+	r.logger.DebugContext(ctx, "executing AddPages query", "chapter_id", chapterID)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокируем главу, чтобы параллельные загрузки не пересекались по номерам страниц
+	var lockedID int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM chapters WHERE id = $1 FOR UPDATE", chapterID).Scan(&lockedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("chapter with id %d not found", chapterID)
+		}
+		r.logger.ErrorContext(ctx, "error locking chapter", "chapter_id", chapterID, "error", err)
+		return nil, fmt.Errorf("error locking chapter: %w", err)
+	}
+
+	// Номер следующей страницы по максимальному существующему номеру
+	var firstNumber int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(number), 0) + 1 FROM pages WHERE chapter_id = $1", chapterID,
+	).Scan(&firstNumber)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting next page number", "chapter_id", chapterID, "error", err)
+		return nil, fmt.Errorf("error getting next page number: %w", err)
+	}
+
+	pages, err := save(firstNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO pages (chapter_id, number, image_url)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	ids := make([]int, 0, len(pages))
+	for _, page := range pages {
+		var id int
+		err = tx.QueryRowContext(
+			ctx, query,
+			chapterID, page.Number, page.ImageURL,
+		).Scan(&id)
+
+		if err != nil {
+			r.logger.ErrorContext(ctx, "error inserting page", "chapter_id", chapterID, "number", page.Number, "error", err)
+			return nil, fmt.Errorf("error inserting page %d: %w", page.Number, err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Обновляем количество страниц в главе
+	_, err = tx.ExecContext(ctx, `
+		UPDATE chapters SET
+			page_count = (SELECT COUNT(*) FROM pages WHERE chapter_id = $1)
+		WHERE id = $1
+	`, chapterID)
+
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating chapter page count", "chapter_id", chapterID, "error", err)
+		return nil, fmt.Errorf("error updating chapter page count: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return ids, nil
+}
+
 // DeletePage удаляет страницу по ID
 func (r *ChapterRepo) DeletePage(ctx context.Context, id int) error {
-	r.logger.Debug("executing DeletePage query", "id", id)
+	r.logger.DebugContext(ctx, "executing DeletePage query", "id", id)
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("error starting transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
@@ -245,7 +356,7 @@ func (r *ChapterRepo) DeletePage(ctx context.Context, id int) error {
 		if errors.Is(err, sql.ErrNoRows) {
 			return fmt.Errorf("page with id %d not found", id)
 		}
-		r.logger.Error("error getting chapter_id", "page_id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error getting chapter_id", "page_id", id, "error", err)
 		return fmt.Errorf("error getting chapter_id: %w", err)
 	}
 
@@ -253,13 +364,13 @@ func (r *ChapterRepo) DeletePage(ctx context.Context, id int) error {
 	query := "DELETE FROM pages WHERE id = $1"
 	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
-		r.logger.Error("error deleting page", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error deleting page", "id", id, "error", err)
 		return fmt.Errorf("error deleting page: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -281,7 +392,7 @@ func (r *ChapterRepo) DeletePage(ctx context.Context, id int) error {
 	`, chapterID)
 
 	if err != nil {
-		r.logger.Error("error updating page numbers", "chapter_id", chapterID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating page numbers", "chapter_id", chapterID, "error", err)
 		return fmt.Errorf("error updating page numbers: %w", err)
 	}
 
@@ -293,12 +404,12 @@ func (r *ChapterRepo) DeletePage(ctx context.Context, id int) error {
 	`, chapterID)
 
 	if err != nil {
-		r.logger.Error("error updating chapter page count", "chapter_id", chapterID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating chapter page count", "chapter_id", chapterID, "error", err)
 		return fmt.Errorf("error updating chapter page count: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		r.logger.Error("error committing transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
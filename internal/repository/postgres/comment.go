@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// CommentRepo реализует интерфейс repository.CommentRepository
+type CommentRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewCommentRepo создает новый репозиторий для работы с комментариями
+func NewCommentRepo(db *sqlx.DB, logger *slog.Logger) *CommentRepo {
+	return &CommentRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// commentColumns список колонок комментария вместе с именем автора и количеством ответов
+const commentColumns = `
+	c.id, c.manga_id, c.chapter_id, c.parent_id, c.user_id, u.username,
+	c.body, c.spoiler, c.hidden, c.deleted_at IS NOT NULL AS deleted,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count,
+	c.created_at, c.edited_at
+`
+
+// Create создает новый комментарий
+func (r *CommentRepo) Create(ctx context.Context, comment domain.Comment) (int, error) {
+	r.logger.DebugContext(ctx, "executing Create comment query",
+		"manga_id", comment.MangaID,
+		"chapter_id", comment.ChapterID,
+		"user_id", comment.UserID)
+
+	query := `
+		INSERT INTO comments (manga_id, chapter_id, parent_id, user_id, body, spoiler)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	var id int
+	err := r.db.QueryRowContext(
+		ctx, query,
+		comment.MangaID, comment.ChapterID, comment.ParentID, comment.UserID, comment.Body, comment.Spoiler,
+	).Scan(&id)
+
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting comment", "error", err)
+		return 0, fmt.Errorf("error inserting comment: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetByID возвращает комментарий по ID
+func (r *CommentRepo) GetByID(ctx context.Context, id int) (domain.Comment, error) {
+	r.logger.DebugContext(ctx, "executing GetByID comment query", "id", id)
+
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1
+	`
+
+	var comment domain.Comment
+	if err := r.db.GetContext(ctx, &comment, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Comment{}, fmt.Errorf("comment with id %d not found", id)
+		}
+		r.logger.ErrorContext(ctx, "error selecting comment by id", "id", id, "error", err)
+		return domain.Comment{}, fmt.Errorf("error selecting comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// List возвращает страницу комментариев ветки.
+// Комментарии верхнего уровня упорядочены от новых к старым, ответы - от старых к новым.
+func (r *CommentRepo) List(ctx context.Context, filter domain.CommentFilter) ([]domain.Comment, error) {
+	r.logger.DebugContext(ctx, "executing List comments query",
+		"manga_id", filter.Target.MangaID,
+		"chapter_id", filter.Target.ChapterID,
+		"parent_id", filter.ParentID,
+		"cursor", filter.Cursor)
+
+	conditions := []string{}
+	args := []interface{}{}
+
+	if filter.ParentID != nil {
+		args = append(args, *filter.ParentID)
+		conditions = append(conditions, fmt.Sprintf("c.parent_id = $%d", len(args)))
+	} else {
+		args = append(args, filter.Target.MangaID)
+		conditions = append(conditions, fmt.Sprintf("c.manga_id = $%d", len(args)), "c.parent_id IS NULL")
+
+		if filter.Target.ChapterID != nil {
+			args = append(args, *filter.Target.ChapterID)
+			conditions = append(conditions, fmt.Sprintf("c.chapter_id = $%d", len(args)))
+		} else {
+			conditions = append(conditions, "c.chapter_id IS NULL")
+		}
+	}
+
+	// Ответы читаются по порядку, новые комментарии верхнего уровня показываются первыми
+	order := "DESC"
+	cursorOp := "<"
+	if filter.ParentID != nil {
+		order = "ASC"
+		cursorOp = ">"
+	}
+
+	if filter.Cursor > 0 {
+		args = append(args, filter.Cursor)
+		conditions = append(conditions, fmt.Sprintf("c.id %s $%d", cursorOp, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := `SELECT ` + commentColumns + `
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY c.id ` + order + fmt.Sprintf(`
+		LIMIT $%d`, len(args))
+
+	var comments []domain.Comment
+	if err := r.db.SelectContext(ctx, &comments, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting comments", "error", err)
+		return nil, fmt.Errorf("error selecting comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// Update обновляет текст комментария
+func (r *CommentRepo) Update(ctx context.Context, id int, body string, spoiler bool) error {
+	r.logger.DebugContext(ctx, "executing Update comment query", "id", id)
+
+	query := `
+		UPDATE comments SET body = $1, spoiler = $2, edited_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, body, spoiler, id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating comment", "id", id, "error", err)
+		return fmt.Errorf("error updating comment: %w", err)
+	}
+
+	return r.checkAffected(ctx, result, id)
+}
+
+// SetHidden скрывает комментарий или возвращает его в ленту
+func (r *CommentRepo) SetHidden(ctx context.Context, id int, hidden bool, moderatorID int) error {
+	r.logger.DebugContext(ctx, "executing SetHidden comment query", "id", id, "hidden", hidden)
+
+	query := `
+		UPDATE comments SET hidden = $1, hidden_by = CASE WHEN $1 THEN $2::INTEGER END
+		WHERE id = $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, hidden, moderatorID, id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error hiding comment", "id", id, "error", err)
+		return fmt.Errorf("error hiding comment: %w", err)
+	}
+
+	return r.checkAffected(ctx, result, id)
+}
+
+// SoftDelete помечает комментарий удаленным, сохраняя ответы на него
+func (r *CommentRepo) SoftDelete(ctx context.Context, id, deletedBy int) error {
+	r.logger.DebugContext(ctx, "executing SoftDelete comment query", "id", id, "deleted_by", deletedBy)
+
+	query := `
+		UPDATE comments SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, deletedBy, id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting comment", "id", id, "error", err)
+		return fmt.Errorf("error deleting comment: %w", err)
+	}
+
+	return r.checkAffected(ctx, result, id)
+}
+
+// IsLocked проверяет, закрыта ли ветка комментариев
+func (r *CommentRepo) IsLocked(ctx context.Context, target domain.CommentTarget) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM comment_locks
+			WHERE manga_id = $1 AND COALESCE(chapter_id, 0) = COALESCE($2::INTEGER, 0)
+		)
+	`
+
+	var locked bool
+	if err := r.db.GetContext(ctx, &locked, query, target.MangaID, target.ChapterID); err != nil {
+		r.logger.ErrorContext(ctx, "error checking comment lock", "manga_id", target.MangaID, "error", err)
+		return false, fmt.Errorf("error checking comment lock: %w", err)
+	}
+
+	return locked, nil
+}
+
+// Lock закрывает ветку комментариев
+func (r *CommentRepo) Lock(ctx context.Context, target domain.CommentTarget, moderatorID int) error {
+	r.logger.DebugContext(ctx, "executing Lock comments query", "manga_id", target.MangaID, "chapter_id", target.ChapterID)
+
+	query := `
+		INSERT INTO comment_locks (manga_id, chapter_id, locked_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (manga_id, COALESCE(chapter_id, 0)) DO NOTHING
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, target.MangaID, target.ChapterID, moderatorID); err != nil {
+		r.logger.ErrorContext(ctx, "error locking comments", "manga_id", target.MangaID, "error", err)
+		return fmt.Errorf("error locking comments: %w", err)
+	}
+
+	return nil
+}
+
+// Unlock открывает ветку комментариев
+func (r *CommentRepo) Unlock(ctx context.Context, target domain.CommentTarget) error {
+	r.logger.DebugContext(ctx, "executing Unlock comments query", "manga_id", target.MangaID, "chapter_id", target.ChapterID)
+
+	query := `
+		DELETE FROM comment_locks
+		WHERE manga_id = $1 AND COALESCE(chapter_id, 0) = COALESCE($2::INTEGER, 0)
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, target.MangaID, target.ChapterID); err != nil {
+		r.logger.ErrorContext(ctx, "error unlocking comments", "manga_id", target.MangaID, "error", err)
+		return fmt.Errorf("error unlocking comments: %w", err)
+	}
+
+	return nil
+}
+
+// checkAffected возвращает ошибку, если запрос не изменил ни одного комментария
+func (r *CommentRepo) checkAffected(ctx context.Context, result sql.Result, id int) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("comment with id %d not found", id)
+	}
+
+	return nil
+}
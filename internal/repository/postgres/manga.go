@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"unicode"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // MangaRepo реализует интерфейс repository.MangaRepository
@@ -28,17 +30,16 @@ func NewMangaRepo(db *sqlx.DB, logger *slog.Logger) *MangaRepo {
 
 // GetAll возвращает список манги с фильтрацией и пагинацией
 func (r *MangaRepo) GetAll(ctx context.Context, filter domain.MangaFilter) ([]domain.Manga, int, error) {
-	r.logger.Debug("executing GetAll manga query with filter",
-		"genre", filter.Genre,
-		"status", filter.Status,
+	r.logger.DebugContext(ctx, "executing GetAll manga query with filter",
+		"genres", filter.Genres,
+		"statuses", filter.Statuses,
 		"search", filter.Search)
 
 	// Формируем запрос для получения манги
 	query := `
 		SELECT m.id, m.title, m.alter_title, m.description, m.cover_url, 
-		m.year, m.status, m.author, m.artist, m.rating, 
-		m.created_at, m.updated_at
-		FROM manga m
+		m.year, m.status, m.author, m.artist, m.rating, m.rating_count,
+		m.team_id, m.created_at, m.updated_at
 	`
 	// Формируем запрос для подсчета общего количества
 	countQuery := `SELECT COUNT(*) FROM manga m`
@@ -48,87 +49,120 @@ func (r *MangaRepo) GetAll(ctx context.Context, filter domain.MangaFilter) ([]do
 	// Массив для хранения условий WHERE
 	conditions := []string{}
 
-	// Добавляем условие для поиска по жанру
-	if filter.Genre != "" {
-		query = query + " JOIN manga_genres mg ON m.id = mg.manga_id JOIN genres g ON mg.genre_id = g.id"
-		countQuery = countQuery + " JOIN manga_genres mg ON m.id = mg.manga_id JOIN genres g ON mg.genre_id = g.id"
-		conditions = append(conditions, "g.name = ?")
-		args = append(args, filter.Genre)
+	// Поисковый запрос подключается к обоим запросам, поэтому его параметры идут первыми
+	if filter.Search != "" {
+		query += `,
+		ts_headline('simple', ` + escapeHTML("m.title") + `, s.query, '` + headlineOptions + `, HighlightAll=true') AS title_highlight,
+		ts_headline('simple', ` + escapeHTML("COALESCE(m.description, '')") + `, s.query, '` + headlineOptions + `, MaxWords=35, MinWords=15, MaxFragments=2') AS description_highlight,
+		` + searchRank + ` AS search_rank
+		FROM manga m` + searchJoin
+		countQuery += searchJoin
+		args = append(args, searchTSQuery(filter.Search), filter.Search)
+
+		// Совпадение по словам и их началу или, с учетом опечаток, по названиям и автору
+		conditions = append(conditions,
+			"(m.search_vector @@ s.query OR s.raw <% m.title OR s.raw <% m.alter_title OR s.raw <% m.author)")
+	} else {
+		query += " FROM manga m"
 	}
 
-	// Добавляем условие для фильтрации по статусу
-	if filter.Status != "" {
-		conditions = append(conditions, "m.status = ?")
-		args = append(args, filter.Status)
+	// Добавляем условия фильтров
+	filterConditions, filterArgs := mangaFilterConditions(filter)
+	conditions = append(conditions, filterConditions...)
+	args = append(args, filterArgs...)
+
+	// Общее количество не зависит от позиции курсора
+	if len(conditions) > 0 {
+		countQuery += " WHERE " + strings.Join(conditions, " AND ")
 	}
+	countArgs := append([]interface{}{}, args...)
 
-	// Добавляем условие для поиска по тексту
-	if filter.Search != "" {
-		conditions = append(conditions, "(m.title ILIKE ? OR m.alter_title ILIKE ?)")
-		searchPattern := "%" + filter.Search + "%"
-		args = append(args, searchPattern, searchPattern)
+	// Продолжаем после последней манги предыдущей страницы
+	if filter.After != nil {
+		cursorCondition, cursorArgs := mangaCursorCondition(filter)
+		conditions = append(conditions, cursorCondition)
+		args = append(args, cursorArgs...)
 	}
 
 	// Добавляем все условия в запрос
 	if len(conditions) > 0 {
-		whereClause := " WHERE " + strings.Join(conditions, " AND ")
-		query += whereClause
-		countQuery += whereClause
+		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	// Добавляем сортировку
+	// Добавляем сортировку. Каждая сортировка дополняется ID, чтобы порядок был однозначным
+	// и курсор следующей страницы указывал на конкретную позицию.
+	direction := " ASC"
+	if filter.SortDesc {
+		direction = " DESC"
+	}
 	switch filter.SortBy {
 	case "title":
-		query += " ORDER BY m.title"
+		query += " ORDER BY m.title" + direction + ", m.id" + direction
 	case "rating":
-		query += " ORDER BY m.rating"
+		// При равной оценке порядок определяет количество голосов
+		query += " ORDER BY COALESCE(m.rating, 0)" + direction + ", m.rating_count" + direction + ", m.id" + direction
 	case "date":
-		query += " ORDER BY m.created_at"
+		query += " ORDER BY m.created_at" + direction + ", m.id" + direction
+	case "relevance":
+		// Релевантность всегда по убыванию
+		query += " ORDER BY search_rank DESC, m.id ASC"
 	default:
-		query += " ORDER BY m.updated_at"
+		query += " ORDER BY m.updated_at" + direction + ", m.id" + direction
 	}
 
-	// Порядок сортировки
-	if filter.SortDesc {
-		query += " DESC"
+	// Запрашиваем на одну мангу больше, чтобы узнать, есть ли следующая страница
+	limit := filter.PageSize + 1
+	if filter.After != nil {
+		query += " LIMIT ?"
+		args = append(args, limit)
 	} else {
-		query += " ASC"
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, (filter.Page-1)*filter.PageSize)
 	}
 
-	// Пагинация
-	query += " LIMIT ? OFFSET ?"
-	limit := filter.PageSize
-	offset := (filter.Page - 1) * filter.PageSize
-	args = append(args, limit, offset)
-
 	// Заменяем ? на $1, $2 и т.д. для PostgreSQL
 	query = r.replaceQuestionMark(query)
 	countQuery = r.replaceQuestionMark(countQuery)
 
-	// Получаем общее количество
+	// Получаем общее количество, если оно запрошено
 	var total int
-	if err := r.db.GetContext(ctx, &total, countQuery, args[:len(args)-2]...); err != nil {
-		r.logger.Error("error counting total manga", "error", err)
-		return nil, 0, fmt.Errorf("error counting manga: %w", err)
-	}
+	if filter.WithTotal {
+		if err := r.db.GetContext(ctx, &total, countQuery, countArgs...); err != nil {
+			r.logger.ErrorContext(ctx, "error counting total manga", "error", err)
+			return nil, 0, fmt.Errorf("error counting manga: %w", err)
+		}
 
-	// Если общее количество 0, возвращаем пустой слайс
-	if total == 0 {
-		return []domain.Manga{}, 0, nil
+		// Если общее количество 0, возвращаем пустой слайс
+		if total == 0 {
+			return []domain.Manga{}, 0, nil
+		}
 	}
 
 	// Получаем список манги
-	var mangas []domain.Manga
-	if err := r.db.SelectContext(ctx, &mangas, query, args...); err != nil {
-		r.logger.Error("error selecting manga", "error", err)
+	var rows []mangaSearchRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting manga", "error", err)
 		return nil, 0, fmt.Errorf("error selecting manga: %w", err)
 	}
 
+	mangas := make([]domain.Manga, 0, len(rows))
+	for _, row := range rows {
+		manga := row.Manga
+		if filter.Search != "" {
+			manga.Highlight = &domain.MangaHighlight{
+				Title:       row.TitleHighlight.String,
+				Description: row.DescriptionHighlight.String,
+			}
+			manga.Relevance = row.SearchRank.Float64
+		}
+		mangas = append(mangas, manga)
+	}
+
 	// Получаем жанры для каждой манги
 	for i := range mangas {
 		genres, err := r.getMangaGenres(ctx, mangas[i].ID)
 		if err != nil {
-			r.logger.Error("error getting manga genres", "manga_id", mangas[i].ID, "error", err)
+			r.logger.ErrorContext(ctx, "error getting manga genres", "manga_id", mangas[i].ID, "error", err)
 			continue
 		}
 		mangas[i].Genres = genres
@@ -139,12 +173,12 @@ func (r *MangaRepo) GetAll(ctx context.Context, filter domain.MangaFilter) ([]do
 
 // GetByID возвращает мангу по ID
 func (r *MangaRepo) GetByID(ctx context.Context, id int) (domain.Manga, error) {
-	r.logger.Debug("executing GetByID manga query", "id", id)
+	r.logger.DebugContext(ctx, "executing GetByID manga query", "id", id)
 
 	query := `
 		SELECT id, title, alter_title, description, cover_url, 
-		year, status, author, artist, rating, 
-		created_at, updated_at
+		year, status, author, artist, rating, rating_count,
+		team_id, created_at, updated_at
 		FROM manga
 		WHERE id = $1
 	`
@@ -154,14 +188,14 @@ func (r *MangaRepo) GetByID(ctx context.Context, id int) (domain.Manga, error) {
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.Manga{}, fmt.Errorf("manga with id %d not found", id)
 		}
-		r.logger.Error("error selecting manga by id", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting manga by id", "id", id, "error", err)
 		return domain.Manga{}, fmt.Errorf("error selecting manga: %w", err)
 	}
 
 	// Получаем жанры для манги
 	genres, err := r.getMangaGenres(ctx, manga.ID)
 	if err != nil {
-		r.logger.Error("error getting manga genres", "manga_id", manga.ID, "error", err)
+		r.logger.ErrorContext(ctx, "error getting manga genres", "manga_id", manga.ID, "error", err)
 	} else {
 		manga.Genres = genres
 	}
@@ -171,11 +205,11 @@ func (r *MangaRepo) GetByID(ctx context.Context, id int) (domain.Manga, error) {
 
 // Create создает новую мангу
 func (r *MangaRepo) Create(ctx context.Context, manga domain.Manga) (int, error) {
-	r.logger.Debug("executing Create manga query", "title", manga.Title)
+	r.logger.DebugContext(ctx, "executing Create manga query", "title", manga.Title)
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("error starting transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
 		return 0, fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
@@ -183,7 +217,7 @@ func (r *MangaRepo) Create(ctx context.Context, manga domain.Manga) (int, error)
 	query := `
 		INSERT INTO manga (
 			title, alter_title, description, cover_url, 
-			year, status, author, artist, rating
+			year, status, author, artist, team_id
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9
 		) RETURNING id
@@ -193,24 +227,24 @@ func (r *MangaRepo) Create(ctx context.Context, manga domain.Manga) (int, error)
 	err = tx.QueryRowContext(
 		ctx, query,
 		manga.Title, manga.AlterTitle, manga.Description, manga.CoverURL,
-		manga.Year, manga.Status, manga.Author, manga.Artist, manga.Rating,
+		manga.Year, manga.Status, manga.Author, manga.Artist, manga.TeamID,
 	).Scan(&id)
 
 	if err != nil {
-		r.logger.Error("error inserting manga", "error", err)
+		r.logger.ErrorContext(ctx, "error inserting manga", "error", err)
 		return 0, fmt.Errorf("error inserting manga: %w", err)
 	}
 
 	// Добавляем жанры манги
 	if len(manga.Genres) > 0 {
 		if err := r.insertMangaGenres(ctx, tx, id, manga.Genres); err != nil {
-			r.logger.Error("error inserting manga genres", "manga_id", id, "error", err)
+			r.logger.ErrorContext(ctx, "error inserting manga genres", "manga_id", id, "error", err)
 			return 0, fmt.Errorf("error inserting manga genres: %w", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		r.logger.Error("error committing transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
 		return 0, fmt.Errorf("error committing transaction: %w", err)
 	}
 
@@ -219,11 +253,11 @@ func (r *MangaRepo) Create(ctx context.Context, manga domain.Manga) (int, error)
 
 // Update обновляет информацию о манге
 func (r *MangaRepo) Update(ctx context.Context, manga domain.Manga) error {
-	r.logger.Debug("executing Update manga query", "id", manga.ID, "title", manga.Title)
+	r.logger.DebugContext(ctx, "executing Update manga query", "id", manga.ID, "title", manga.Title)
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.logger.Error("error starting transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback()
@@ -237,26 +271,25 @@ func (r *MangaRepo) Update(ctx context.Context, manga domain.Manga) error {
 			year = $5, 
 			status = $6, 
 			author = $7, 
-			artist = $8, 
-			rating = $9
-		WHERE id = $10
+			artist = $8
+		WHERE id = $9
 	`
 
 	result, err := tx.ExecContext(
 		ctx, query,
 		manga.Title, manga.AlterTitle, manga.Description, manga.CoverURL,
-		manga.Year, manga.Status, manga.Author, manga.Artist, manga.Rating,
+		manga.Year, manga.Status, manga.Author, manga.Artist,
 		manga.ID,
 	)
 
 	if err != nil {
-		r.logger.Error("error updating manga", "id", manga.ID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating manga", "id", manga.ID, "error", err)
 		return fmt.Errorf("error updating manga: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -269,19 +302,19 @@ func (r *MangaRepo) Update(ctx context.Context, manga domain.Manga) error {
 		// Удаляем существующие жанры
 		_, err = tx.ExecContext(ctx, "DELETE FROM manga_genres WHERE manga_id = $1", manga.ID)
 		if err != nil {
-			r.logger.Error("error deleting manga genres", "manga_id", manga.ID, "error", err)
+			r.logger.ErrorContext(ctx, "error deleting manga genres", "manga_id", manga.ID, "error", err)
 			return fmt.Errorf("error deleting manga genres: %w", err)
 		}
 
 		// Добавляем новые жанры
 		if err := r.insertMangaGenres(ctx, tx, manga.ID, manga.Genres); err != nil {
-			r.logger.Error("error inserting manga genres", "manga_id", manga.ID, "error", err)
+			r.logger.ErrorContext(ctx, "error inserting manga genres", "manga_id", manga.ID, "error", err)
 			return fmt.Errorf("error inserting manga genres: %w", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		r.logger.Error("error committing transaction", "error", err)
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
 		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
@@ -290,18 +323,18 @@ func (r *MangaRepo) Update(ctx context.Context, manga domain.Manga) error {
 
 // Delete удаляет мангу по ID
 func (r *MangaRepo) Delete(ctx context.Context, id int) error {
-	r.logger.Debug("executing Delete manga query", "id", id)
+	r.logger.DebugContext(ctx, "executing Delete manga query", "id", id)
 
 	query := "DELETE FROM manga WHERE id = $1"
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		r.logger.Error("error deleting manga", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error deleting manga", "id", id, "error", err)
 		return fmt.Errorf("error deleting manga: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -312,20 +345,273 @@ func (r *MangaRepo) Delete(ctx context.Context, id int) error {
 	return nil
 }
 
+// SetTeam назначает манге команду; nil снимает назначение
+func (r *MangaRepo) SetTeam(ctx context.Context, mangaID int, teamID *int) error {
+	r.logger.DebugContext(ctx, "executing SetTeam manga query", "id", mangaID, "team_id", teamID)
+
+	result, err := r.db.ExecContext(ctx, "UPDATE manga SET team_id = $1 WHERE id = $2", teamID, mangaID)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error setting manga team", "id", mangaID, "error", err)
+		return fmt.Errorf("error setting manga team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("manga with id %d not found", mangaID)
+	}
+
+	return nil
+}
+
 // GetGenres возвращает список всех жанров
 func (r *MangaRepo) GetGenres(ctx context.Context) ([]domain.Genre, error) {
-	r.logger.Debug("executing GetGenres query")
+	r.logger.DebugContext(ctx, "executing GetGenres query")
 
 	query := "SELECT id, name FROM genres ORDER BY name"
 	var genres []domain.Genre
 	if err := r.db.SelectContext(ctx, &genres, query); err != nil {
-		r.logger.Error("error selecting genres", "error", err)
+		r.logger.ErrorContext(ctx, "error selecting genres", "error", err)
 		return nil, fmt.Errorf("error selecting genres: %w", err)
 	}
 
 	return genres, nil
 }
 
+// ListSuggestSources возвращает ID, названия, автора и художника всей манги
+func (r *MangaRepo) ListSuggestSources(ctx context.Context) ([]domain.Manga, error) {
+	r.logger.DebugContext(ctx, "executing ListSuggestSources query")
+
+	query := `
+		SELECT id, title, COALESCE(alter_title, '') AS alter_title, author, COALESCE(artist, '') AS artist
+		FROM manga
+		ORDER BY id
+	`
+	var mangas []domain.Manga
+	if err := r.db.SelectContext(ctx, &mangas, query); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting manga suggest sources", "error", err)
+		return nil, fmt.Errorf("error selecting manga suggest sources: %w", err)
+	}
+
+	return mangas, nil
+}
+
+// GetWatermark возвращает настройки водяного знака манги или nil, если они не заданы
+func (r *MangaRepo) GetWatermark(ctx context.Context, mangaID int) (*domain.Watermark, error) {
+	r.logger.DebugContext(ctx, "executing GetWatermark query", "manga_id", mangaID)
+
+	query := `
+		SELECT manga_id, enabled, text, logo, position, opacity, scale, apply_on, updated_at
+		FROM manga_watermarks
+		WHERE manga_id = $1
+	`
+
+	var watermark domain.Watermark
+	if err := r.db.GetContext(ctx, &watermark, query, mangaID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.ErrorContext(ctx, "error selecting watermark", "manga_id", mangaID, "error", err)
+		return nil, fmt.Errorf("error selecting watermark: %w", err)
+	}
+	watermark.HasLogo = len(watermark.Logo) > 0
+
+	return &watermark, nil
+}
+
+// SaveWatermark создает или обновляет настройки водяного знака манги
+func (r *MangaRepo) SaveWatermark(ctx context.Context, watermark domain.Watermark) error {
+	r.logger.DebugContext(ctx, "executing SaveWatermark query", "manga_id", watermark.MangaID)
+
+	query := `
+		INSERT INTO manga_watermarks (manga_id, enabled, text, logo, position, opacity, scale, apply_on, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+		ON CONFLICT (manga_id) DO UPDATE
+		SET enabled = $2, text = $3, logo = $4, position = $5,
+			opacity = $6, scale = $7, apply_on = $8, updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		watermark.MangaID, watermark.Enabled, watermark.Text, watermark.Logo,
+		watermark.Position, watermark.Opacity, watermark.Scale, watermark.ApplyOn,
+	)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error saving watermark", "manga_id", watermark.MangaID, "error", err)
+		return fmt.Errorf("error saving watermark: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteWatermark удаляет настройки водяного знака манги
+func (r *MangaRepo) DeleteWatermark(ctx context.Context, mangaID int) error {
+	r.logger.DebugContext(ctx, "executing DeleteWatermark query", "manga_id", mangaID)
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM manga_watermarks WHERE manga_id = $1", mangaID)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting watermark", "manga_id", mangaID, "error", err)
+		return fmt.Errorf("error deleting watermark: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("watermark for manga %d not found", mangaID)
+	}
+
+	return nil
+}
+
+// SetRating сохраняет оценку пользователя и пересчитывает рейтинг манги в одной транзакции
+func (r *MangaRepo) SetRating(ctx context.Context, userID, mangaID, score int) (domain.MangaRating, error) {
+	r.logger.DebugContext(ctx, "executing SetRating query", "user_id", userID, "manga_id", mangaID, "score", score)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.lockManga(ctx, tx, mangaID); err != nil {
+		return domain.MangaRating{}, err
+	}
+
+	query := `
+		INSERT INTO ratings (user_id, manga_id, score)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, manga_id) DO UPDATE
+		SET score = $3
+	`
+
+	if _, err := tx.ExecContext(ctx, query, userID, mangaID, score); err != nil {
+		r.logger.ErrorContext(ctx, "error saving rating", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error saving rating: %w", err)
+	}
+
+	rating, err := r.refreshRating(ctx, tx, mangaID)
+	if err != nil {
+		return domain.MangaRating{}, err
+	}
+	rating.UserScore = score
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return rating, nil
+}
+
+// DeleteRating удаляет оценку пользователя и пересчитывает рейтинг манги в одной транзакции
+func (r *MangaRepo) DeleteRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error) {
+	r.logger.DebugContext(ctx, "executing DeleteRating query", "user_id", userID, "manga_id", mangaID)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.lockManga(ctx, tx, mangaID); err != nil {
+		return domain.MangaRating{}, err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM ratings WHERE user_id = $1 AND manga_id = $2", userID, mangaID)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting rating", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error deleting rating: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return domain.MangaRating{}, fmt.Errorf("rating for manga %d not found", mangaID)
+	}
+
+	rating, err := r.refreshRating(ctx, tx, mangaID)
+	if err != nil {
+		return domain.MangaRating{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return rating, nil
+}
+
+// GetUserRating возвращает рейтинг манги вместе с оценкой пользователя
+func (r *MangaRepo) GetUserRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error) {
+	r.logger.DebugContext(ctx, "executing GetUserRating query", "user_id", userID, "manga_id", mangaID)
+
+	query := `
+		SELECT m.id AS manga_id, m.rating, m.rating_count, COALESCE(rt.score, 0) AS user_score
+		FROM manga m
+		LEFT JOIN ratings rt ON rt.manga_id = m.id AND rt.user_id = $1
+		WHERE m.id = $2
+	`
+
+	var rating domain.MangaRating
+	if err := r.db.GetContext(ctx, &rating, query, userID, mangaID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MangaRating{}, fmt.Errorf("manga with id %d not found", mangaID)
+		}
+		r.logger.ErrorContext(ctx, "error selecting rating", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error selecting rating: %w", err)
+	}
+
+	return rating, nil
+}
+
+// lockManga блокирует строку манги, чтобы параллельные голоса не теряли пересчет рейтинга
+func (r *MangaRepo) lockManga(ctx context.Context, tx *sqlx.Tx, mangaID int) error {
+	var lockedID int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM manga WHERE id = $1 FOR UPDATE", mangaID).Scan(&lockedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("manga with id %d not found", mangaID)
+		}
+		r.logger.ErrorContext(ctx, "error locking manga", "manga_id", mangaID, "error", err)
+		return fmt.Errorf("error locking manga: %w", err)
+	}
+	return nil
+}
+
+// refreshRating пересчитывает среднюю оценку и количество голосов манги
+func (r *MangaRepo) refreshRating(ctx context.Context, tx *sqlx.Tx, mangaID int) (domain.MangaRating, error) {
+	query := `
+		UPDATE manga SET
+			rating = COALESCE((SELECT ROUND(AVG(score), 2) FROM ratings WHERE manga_id = $1), 0),
+			rating_count = (SELECT COUNT(*) FROM ratings WHERE manga_id = $1)
+		WHERE id = $1
+		RETURNING id AS manga_id, rating, rating_count
+	`
+
+	var rating domain.MangaRating
+	if err := tx.GetContext(ctx, &rating, query, mangaID); err != nil {
+		r.logger.ErrorContext(ctx, "error updating manga rating", "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, fmt.Errorf("error updating manga rating: %w", err)
+	}
+
+	return rating, nil
+}
+
 // getMangaGenres возвращает жанры для указанной манги
 func (r *MangaRepo) getMangaGenres(ctx context.Context, mangaID int) ([]domain.Genre, error) {
 	query := `
@@ -356,6 +642,165 @@ func (r *MangaRepo) insertMangaGenres(ctx context.Context, tx *sql.Tx, mangaID i
 	return nil
 }
 
+// mangaFilterConditions возвращает условия WHERE для фильтров списка манги (таблица manga с псевдонимом m)
+// и их параметры. Значения фильтров передаются только параметрами запроса.
+func mangaFilterConditions(filter domain.MangaFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	// Жанры манги
+	const genresQuery = "SELECT mg.manga_id FROM manga_genres mg JOIN genres g ON g.id = mg.genre_id WHERE g.name = ANY(?)"
+
+	if genres := uniqueStrings(filter.Genres); len(genres) > 0 {
+		if filter.GenreMode == domain.GenreModeAny {
+			conditions = append(conditions, "m.id IN ("+genresQuery+")")
+			args = append(args, pq.Array(genres))
+		} else {
+			conditions = append(conditions, "m.id IN ("+genresQuery+" GROUP BY mg.manga_id HAVING COUNT(*) = ?)")
+			args = append(args, pq.Array(genres), len(genres))
+		}
+	}
+
+	if genres := uniqueStrings(filter.ExcludeGenres); len(genres) > 0 {
+		conditions = append(conditions, "m.id NOT IN ("+genresQuery+")")
+		args = append(args, pq.Array(genres))
+	}
+
+	if statuses := uniqueStrings(filter.Statuses); len(statuses) > 0 {
+		conditions = append(conditions, "m.status = ANY(?)")
+		args = append(args, pq.Array(statuses))
+	}
+
+	if filter.YearFrom != nil {
+		conditions = append(conditions, "m.year >= ?")
+		args = append(args, *filter.YearFrom)
+	}
+	if filter.YearTo != nil {
+		conditions = append(conditions, "m.year <= ?")
+		args = append(args, *filter.YearTo)
+	}
+
+	if filter.MinRating != nil {
+		conditions = append(conditions, "m.rating >= ?")
+		args = append(args, *filter.MinRating)
+	}
+
+	if filter.Author != "" {
+		conditions = append(conditions, "m.author ILIKE ?")
+		args = append(args, containsPattern(filter.Author))
+	}
+	if filter.Artist != "" {
+		conditions = append(conditions, "m.artist ILIKE ?")
+		args = append(args, containsPattern(filter.Artist))
+	}
+
+	// Количество глав считается подзапросом по индексу idx_chapters_manga_id
+	const chaptersQuery = "(SELECT COUNT(*) FROM chapters c WHERE c.manga_id = m.id)"
+	if filter.ChaptersFrom != nil {
+		conditions = append(conditions, chaptersQuery+" >= ?")
+		args = append(args, *filter.ChaptersFrom)
+	}
+	if filter.ChaptersTo != nil {
+		conditions = append(conditions, chaptersQuery+" <= ?")
+		args = append(args, *filter.ChaptersTo)
+	}
+
+	return conditions, args
+}
+
+// containsPattern возвращает шаблон ILIKE для поиска подстроки; символы шаблона в строке экранируются
+func containsPattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// uniqueStrings возвращает непустые значения без повторов в исходном порядке
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
+
+// Параметры полнотекстового поиска
+const (
+	// searchJoin добавляет к запросу поисковый запрос s.query (tsquery) и исходную строку поиска s.raw
+	searchJoin = " CROSS JOIN (SELECT to_tsquery('simple', NULLIF(?, '')) AS query, ?::text AS raw) s"
+	// searchRank релевантность манги поисковому запросу. Значение приводится к float8, чтобы
+	// оно без потерь передавалось в курсоре и сравнивалось с ним в условии следующей страницы.
+	searchRank = `(COALESCE(ts_rank(m.search_vector, s.query), 0)
+			+ GREATEST(word_similarity(s.raw, m.title), word_similarity(s.raw, COALESCE(m.alter_title, ''))))::float8`
+	// headlineOptions общие настройки выделения найденных слов во фрагментах
+	headlineOptions = "StartSel=<mark>, StopSel=</mark>"
+	// maxSearchTerms максимальное количество слов поискового запроса
+	maxSearchTerms = 8
+)
+
+// escapeHTML возвращает SQL-выражение, экранирующее спецсимволы HTML в тексте expr.
+// Текст экранируется до ts_headline, чтобы единственной разметкой во фрагментах был тег <mark>.
+func escapeHTML(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// mangaCursorCondition возвращает условие WHERE для манги, следующей за позицией filter.After
+// в порядке сортировки filter.SortBy
+func mangaCursorCondition(filter domain.MangaFilter) (string, []interface{}) {
+	after := filter.After
+
+	op := ">"
+	if filter.SortDesc {
+		op = "<"
+	}
+
+	switch filter.SortBy {
+	case "title":
+		return "(m.title, m.id) " + op + " (?, ?)", []interface{}{after.Text, after.ID}
+	case "rating":
+		return "(COALESCE(m.rating, 0), m.rating_count, m.id) " + op + " (?, ?, ?)",
+			[]interface{}{after.Number, after.Count, after.ID}
+	case "date":
+		return "(m.created_at, m.id) " + op + " (?, ?)", []interface{}{after.Time, after.ID}
+	case "relevance":
+		// Релевантность убывает, а ID при равной релевантности возрастает
+		return "(" + searchRank + " < ? OR (" + searchRank + " = ? AND m.id > ?))",
+			[]interface{}{after.Number, after.Number, after.ID}
+	default:
+		return "(m.updated_at, m.id) " + op + " (?, ?)", []interface{}{after.Time, after.ID}
+	}
+}
+
+// mangaSearchRow строка списка манги вместе с результатами поиска
+type mangaSearchRow struct {
+	domain.Manga
+	TitleHighlight       sql.NullString
+	DescriptionHighlight sql.NullString
+	SearchRank           sql.NullFloat64
+}
+
+// searchTSQuery преобразует строку поиска в запрос to_tsquery: каждое слово ищется по началу,
+// все слова должны присутствовать. Знаки препинания и операторы tsquery отбрасываются.
+func searchTSQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
 // replaceQuestionMark заменяет ? на $1, $2 и т.д. для PostgreSQL
 func (r *MangaRepo) replaceQuestionMark(query string) string {
 	paramIndex := 0
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/lib/pq"
+)
+
+func intPtr(v int) *int { return &v }
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestMangaFilterConditions(t *testing.T) {
+	const (
+		genresQuery   = "SELECT mg.manga_id FROM manga_genres mg JOIN genres g ON g.id = mg.genre_id WHERE g.name = ANY(?)"
+		chaptersQuery = "(SELECT COUNT(*) FROM chapters c WHERE c.manga_id = m.id)"
+	)
+
+	tests := []struct {
+		name       string
+		filter     domain.MangaFilter
+		conditions []string
+		args       []interface{}
+	}{
+		{
+			name:   "empty filter",
+			filter: domain.MangaFilter{},
+		},
+		{
+			name:       "all genres by default",
+			filter:     domain.MangaFilter{Genres: []string{"Action", "Drama"}},
+			conditions: []string{"m.id IN (" + genresQuery + " GROUP BY mg.manga_id HAVING COUNT(*) = ?)"},
+			args:       []interface{}{pq.Array([]string{"Action", "Drama"}), 2},
+		},
+		{
+			name:       "all genres counts unique names",
+			filter:     domain.MangaFilter{Genres: []string{"Action", "", "Action", "Drama"}, GenreMode: domain.GenreModeAll},
+			conditions: []string{"m.id IN (" + genresQuery + " GROUP BY mg.manga_id HAVING COUNT(*) = ?)"},
+			args:       []interface{}{pq.Array([]string{"Action", "Drama"}), 2},
+		},
+		{
+			name:       "any genre",
+			filter:     domain.MangaFilter{Genres: []string{"Action", "Drama"}, GenreMode: domain.GenreModeAny},
+			conditions: []string{"m.id IN (" + genresQuery + ")"},
+			args:       []interface{}{pq.Array([]string{"Action", "Drama"})},
+		},
+		{
+			name:       "excluded genres",
+			filter:     domain.MangaFilter{ExcludeGenres: []string{"Horror"}},
+			conditions: []string{"m.id NOT IN (" + genresQuery + ")"},
+			args:       []interface{}{pq.Array([]string{"Horror"})},
+		},
+		{
+			name:       "multiple statuses",
+			filter:     domain.MangaFilter{Statuses: []string{"ongoing", "completed", "ongoing"}},
+			conditions: []string{"m.status = ANY(?)"},
+			args:       []interface{}{pq.Array([]string{"ongoing", "completed"})},
+		},
+		{
+			name:       "year range",
+			filter:     domain.MangaFilter{YearFrom: intPtr(2000), YearTo: intPtr(2010)},
+			conditions: []string{"m.year >= ?", "m.year <= ?"},
+			args:       []interface{}{2000, 2010},
+		},
+		{
+			name:       "year upper bound only",
+			filter:     domain.MangaFilter{YearTo: intPtr(1999)},
+			conditions: []string{"m.year <= ?"},
+			args:       []interface{}{1999},
+		},
+		{
+			name:       "minimum rating",
+			filter:     domain.MangaFilter{MinRating: float64Ptr(7.5)},
+			conditions: []string{"m.rating >= ?"},
+			args:       []interface{}{7.5},
+		},
+		{
+			name:       "chapter range",
+			filter:     domain.MangaFilter{ChaptersFrom: intPtr(10), ChaptersTo: intPtr(50)},
+			conditions: []string{chaptersQuery + " >= ?", chaptersQuery + " <= ?"},
+			args:       []interface{}{10, 50},
+		},
+		{
+			name:       "author and artist",
+			filter:     domain.MangaFilter{Author: "Oda", Artist: "Toriyama"},
+			conditions: []string{"m.author ILIKE ?", "m.artist ILIKE ?"},
+			args:       []interface{}{"%Oda%", "%Toriyama%"},
+		},
+		{
+			name:       "ILIKE wildcards are escaped",
+			filter:     domain.MangaFilter{Author: `100%_a\b`},
+			conditions: []string{"m.author ILIKE ?"},
+			args:       []interface{}{`%100\%\_a\\b%`},
+		},
+		{
+			name: "combined filters keep argument order",
+			filter: domain.MangaFilter{
+				Genres:        []string{"Action"},
+				GenreMode:     domain.GenreModeAny,
+				ExcludeGenres: []string{"Horror"},
+				Statuses:      []string{"ongoing"},
+				YearFrom:      intPtr(2000),
+				MinRating:     float64Ptr(8),
+				Artist:        "a_b",
+				ChaptersTo:    intPtr(100),
+			},
+			conditions: []string{
+				"m.id IN (" + genresQuery + ")",
+				"m.id NOT IN (" + genresQuery + ")",
+				"m.status = ANY(?)",
+				"m.year >= ?",
+				"m.rating >= ?",
+				"m.artist ILIKE ?",
+				chaptersQuery + " <= ?",
+			},
+			args: []interface{}{
+				pq.Array([]string{"Action"}),
+				pq.Array([]string{"Horror"}),
+				pq.Array([]string{"ongoing"}),
+				2000,
+				8.0,
+				`%a\_b%`,
+				100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, args := mangaFilterConditions(tt.filter)
+
+			if !reflect.DeepEqual(conditions, tt.conditions) {
+				t.Errorf("conditions = %#v, want %#v", conditions, tt.conditions)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+
+			placeholders := strings.Count(strings.Join(conditions, " "), "?")
+			if placeholders != len(args) {
+				t.Errorf("%d placeholders for %d args", placeholders, len(args))
+			}
+		})
+	}
+}
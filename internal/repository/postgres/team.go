@@ -0,0 +1,350 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// TeamRepo реализует интерфейс repository.TeamRepository
+type TeamRepo struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+// NewTeamRepo создает новый репозиторий для работы с командами
+func NewTeamRepo(db *sqlx.DB, logger *slog.Logger) *TeamRepo {
+	return &TeamRepo{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// teamColumns список колонок команды вместе с количеством участников
+const teamColumns = `
+	t.id, t.name, t.description,
+	(SELECT COUNT(*) FROM team_members tm WHERE tm.team_id = t.id) AS member_count,
+	t.created_at, t.updated_at
+`
+
+// List возвращает список всех команд
+func (r *TeamRepo) List(ctx context.Context) ([]domain.Team, error) {
+	r.logger.DebugContext(ctx, "executing List teams query")
+
+	query := `SELECT ` + teamColumns + ` FROM teams t ORDER BY t.name`
+
+	var teams []domain.Team
+	if err := r.db.SelectContext(ctx, &teams, query); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting teams", "error", err)
+		return nil, fmt.Errorf("error selecting teams: %w", err)
+	}
+
+	return teams, nil
+}
+
+// GetByID возвращает команду по ID
+func (r *TeamRepo) GetByID(ctx context.Context, id int) (domain.Team, error) {
+	r.logger.DebugContext(ctx, "executing GetByID team query", "id", id)
+
+	query := `SELECT ` + teamColumns + ` FROM teams t WHERE t.id = $1`
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Team{}, fmt.Errorf("team with id %d not found", id)
+		}
+		r.logger.ErrorContext(ctx, "error selecting team by id", "id", id, "error", err)
+		return domain.Team{}, fmt.Errorf("error selecting team: %w", err)
+	}
+
+	return team, nil
+}
+
+// GetByName возвращает команду по названию
+func (r *TeamRepo) GetByName(ctx context.Context, name string) (domain.Team, error) {
+	r.logger.DebugContext(ctx, "executing GetByName team query", "name", name)
+
+	query := `SELECT ` + teamColumns + ` FROM teams t WHERE LOWER(t.name) = LOWER($1)`
+
+	var team domain.Team
+	if err := r.db.GetContext(ctx, &team, query, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Team{}, fmt.Errorf("team with name %s not found", name)
+		}
+		r.logger.ErrorContext(ctx, "error selecting team by name", "name", name, "error", err)
+		return domain.Team{}, fmt.Errorf("error selecting team: %w", err)
+	}
+
+	return team, nil
+}
+
+// Create создает команду и добавляет в нее владельца в одной транзакции
+func (r *TeamRepo) Create(ctx context.Context, team domain.Team, ownerID int) (int, error) {
+	r.logger.DebugContext(ctx, "executing Create team query", "name", team.Name, "owner_id", ownerID)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO teams (name, description) VALUES ($1, $2) RETURNING id`,
+		team.Name, team.Description,
+	).Scan(&id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting team", "error", err)
+		return 0, fmt.Errorf("error inserting team: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO team_members (team_id, user_id, role) VALUES ($1, $2, $3)`,
+		id, ownerID, domain.TeamRoleOwner,
+	)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting team owner", "team_id", id, "user_id", ownerID, "error", err)
+		return 0, fmt.Errorf("error inserting team owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// Update обновляет название и описание команды
+func (r *TeamRepo) Update(ctx context.Context, team domain.Team) error {
+	r.logger.DebugContext(ctx, "executing Update team query", "id", team.ID, "name", team.Name)
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE teams SET name = $1, description = $2 WHERE id = $3`,
+		team.Name, team.Description, team.ID,
+	)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating team", "id", team.ID, "error", err)
+		return fmt.Errorf("error updating team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("team with id %d not found", team.ID)
+	}
+
+	return nil
+}
+
+// Delete удаляет команду; манга команды остается без команды
+func (r *TeamRepo) Delete(ctx context.Context, id int) error {
+	r.logger.DebugContext(ctx, "executing Delete team query", "id", id)
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM teams WHERE id = $1", id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting team", "id", id, "error", err)
+		return fmt.Errorf("error deleting team: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("team with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetMembers возвращает участников команды
+func (r *TeamRepo) GetMembers(ctx context.Context, teamID int) ([]domain.TeamMember, error) {
+	r.logger.DebugContext(ctx, "executing GetMembers team query", "team_id", teamID)
+
+	query := `
+		SELECT tm.team_id, tm.user_id, u.username, tm.role, tm.joined_at
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1
+		ORDER BY tm.joined_at, tm.user_id
+	`
+
+	var members []domain.TeamMember
+	if err := r.db.SelectContext(ctx, &members, query, teamID); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting team members", "team_id", teamID, "error", err)
+		return nil, fmt.Errorf("error selecting team members: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetMemberRole возвращает роль пользователя в команде или пустую строку, если он не участник
+func (r *TeamRepo) GetMemberRole(ctx context.Context, teamID, userID int) (string, error) {
+	r.logger.DebugContext(ctx, "executing GetMemberRole query", "team_id", teamID, "user_id", userID)
+
+	var role string
+	err := r.db.GetContext(ctx, &role,
+		`SELECT role FROM team_members WHERE team_id = $1 AND user_id = $2`,
+		teamID, userID,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		r.logger.ErrorContext(ctx, "error selecting team member role", "team_id", teamID, "user_id", userID, "error", err)
+		return "", fmt.Errorf("error selecting team member role: %w", err)
+	}
+
+	return role, nil
+}
+
+// GetUserTeams возвращает команды пользователя вместе с его ролью в них
+func (r *TeamRepo) GetUserTeams(ctx context.Context, userID int) ([]domain.UserTeam, error) {
+	r.logger.DebugContext(ctx, "executing GetUserTeams query", "user_id", userID)
+
+	query := `SELECT ` + teamColumns + `, m.role
+		FROM teams t
+		JOIN team_members m ON m.team_id = t.id
+		WHERE m.user_id = $1
+		ORDER BY t.name
+	`
+
+	var teams []domain.UserTeam
+	if err := r.db.SelectContext(ctx, &teams, query, userID); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting user teams", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("error selecting user teams: %w", err)
+	}
+
+	return teams, nil
+}
+
+// SetMember добавляет пользователя в команду или меняет его роль.
+// Изменение, после которого в команде не остается владельца, отклоняется.
+func (r *TeamRepo) SetMember(ctx context.Context, teamID, userID int, role string) error {
+	r.logger.DebugContext(ctx, "executing SetMember query", "team_id", teamID, "user_id", userID, "role", role)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.lockTeam(ctx, tx, teamID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO team_members (team_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (team_id, user_id) DO UPDATE SET role = $3
+	`
+	if _, err := tx.ExecContext(ctx, query, teamID, userID, role); err != nil {
+		r.logger.ErrorContext(ctx, "error saving team member", "team_id", teamID, "user_id", userID, "error", err)
+		return fmt.Errorf("error saving team member: %w", err)
+	}
+
+	if err := r.checkOwners(ctx, tx, teamID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveMember исключает пользователя из команды.
+// Исключение последнего владельца отклоняется.
+func (r *TeamRepo) RemoveMember(ctx context.Context, teamID, userID int) error {
+	r.logger.DebugContext(ctx, "executing RemoveMember query", "team_id", teamID, "user_id", userID)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := r.lockTeam(ctx, tx, teamID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`,
+		teamID, userID,
+	)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting team member", "team_id", teamID, "user_id", userID, "error", err)
+		return fmt.Errorf("error deleting team member: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("member %d of team %d not found", userID, teamID)
+	}
+
+	if err := r.checkOwners(ctx, tx, teamID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lockTeam блокирует строку команды до конца транзакции, чтобы параллельные изменения состава
+// не оставили команду без владельца
+func (r *TeamRepo) lockTeam(ctx context.Context, tx *sqlx.Tx, teamID int) error {
+	var id int
+	if err := tx.GetContext(ctx, &id, "SELECT id FROM teams WHERE id = $1 FOR UPDATE", teamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("team with id %d not found", teamID)
+		}
+		r.logger.ErrorContext(ctx, "error locking team", "team_id", teamID, "error", err)
+		return fmt.Errorf("error locking team: %w", err)
+	}
+	return nil
+}
+
+// checkOwners проверяет, что в команде остался хотя бы один владелец
+func (r *TeamRepo) checkOwners(ctx context.Context, tx *sqlx.Tx, teamID int) error {
+	var owners int
+	err := tx.GetContext(ctx, &owners,
+		`SELECT COUNT(*) FROM team_members WHERE team_id = $1 AND role = $2`,
+		teamID, domain.TeamRoleOwner,
+	)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error counting team owners", "team_id", teamID, "error", err)
+		return fmt.Errorf("error counting team owners: %w", err)
+	}
+
+	if owners == 0 {
+		return errors.New("invalid team member: team must have at least one owner")
+	}
+
+	return nil
+}
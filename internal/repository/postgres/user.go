@@ -28,7 +28,7 @@ func NewUserRepo(db *sqlx.DB, logger *slog.Logger) *UserRepo {
 
 // Create создает нового пользователя
 func (r *UserRepo) Create(ctx context.Context, user domain.User) (int, error) {
-	r.logger.Debug("executing Create user query", "username", user.Username, "email", user.Email)
+	r.logger.DebugContext(ctx, "executing Create user query", "username", user.Username, "email", user.Email)
 
 	query := `
 		INSERT INTO users (username, email, password_hash, avatar_url, role)
@@ -43,7 +43,7 @@ func (r *UserRepo) Create(ctx context.Context, user domain.User) (int, error) {
 	).Scan(&id)
 
 	if err != nil {
-		r.logger.Error("error inserting user", "error", err)
+		r.logger.ErrorContext(ctx, "error inserting user", "error", err)
 		return 0, fmt.Errorf("error inserting user: %w", err)
 	}
 
@@ -52,10 +52,10 @@ func (r *UserRepo) Create(ctx context.Context, user domain.User) (int, error) {
 
 // GetByID возвращает пользователя по ID
 func (r *UserRepo) GetByID(ctx context.Context, id int) (domain.User, error) {
-	r.logger.Debug("executing GetByID user query", "id", id)
+	r.logger.DebugContext(ctx, "executing GetByID user query", "id", id)
 
 	query := `
-		SELECT id, username, email, password_hash, avatar_url, role, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, email_verified, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
@@ -65,7 +65,7 @@ func (r *UserRepo) GetByID(ctx context.Context, id int) (domain.User, error) {
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.User{}, fmt.Errorf("user with id %d not found", id)
 		}
-		r.logger.Error("error selecting user by id", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting user by id", "id", id, "error", err)
 		return domain.User{}, fmt.Errorf("error selecting user: %w", err)
 	}
 
@@ -74,10 +74,10 @@ func (r *UserRepo) GetByID(ctx context.Context, id int) (domain.User, error) {
 
 // GetByUsername возвращает пользователя по имени пользователя
 func (r *UserRepo) GetByUsername(ctx context.Context, username string) (domain.User, error) {
-	r.logger.Debug("executing GetByUsername query", "username", username)
+	r.logger.DebugContext(ctx, "executing GetByUsername query", "username", username)
 
 	query := `
-		SELECT id, username, email, password_hash, avatar_url, role, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, email_verified, created_at, updated_at
 		FROM users
 		WHERE username = $1
 	`
@@ -87,7 +87,7 @@ func (r *UserRepo) GetByUsername(ctx context.Context, username string) (domain.U
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.User{}, fmt.Errorf("user with username %s not found", username)
 		}
-		r.logger.Error("error selecting user by username", "username", username, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting user by username", "username", username, "error", err)
 		return domain.User{}, fmt.Errorf("error selecting user: %w", err)
 	}
 
@@ -96,10 +96,10 @@ func (r *UserRepo) GetByUsername(ctx context.Context, username string) (domain.U
 
 // GetByEmail возвращает пользователя по email
 func (r *UserRepo) GetByEmail(ctx context.Context, email string) (domain.User, error) {
-	r.logger.Debug("executing GetByEmail query", "email", email)
+	r.logger.DebugContext(ctx, "executing GetByEmail query", "email", email)
 
 	query := `
-		SELECT id, username, email, password_hash, avatar_url, role, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, email_verified, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
@@ -109,7 +109,7 @@ func (r *UserRepo) GetByEmail(ctx context.Context, email string) (domain.User, e
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.User{}, fmt.Errorf("user with email %s not found", email)
 		}
-		r.logger.Error("error selecting user by email", "email", email, "error", err)
+		r.logger.ErrorContext(ctx, "error selecting user by email", "email", email, "error", err)
 		return domain.User{}, fmt.Errorf("error selecting user: %w", err)
 	}
 
@@ -118,7 +118,7 @@ func (r *UserRepo) GetByEmail(ctx context.Context, email string) (domain.User, e
 
 // Update обновляет информацию о пользователе
 func (r *UserRepo) Update(ctx context.Context, user domain.User) error {
-	r.logger.Debug("executing Update user query", "id", user.ID, "username", user.Username)
+	r.logger.DebugContext(ctx, "executing Update user query", "id", user.ID, "username", user.Username)
 
 	query := `
 		UPDATE users SET 
@@ -136,13 +136,13 @@ func (r *UserRepo) Update(ctx context.Context, user domain.User) error {
 	)
 
 	if err != nil {
-		r.logger.Error("error updating user", "id", user.ID, "error", err)
+		r.logger.ErrorContext(ctx, "error updating user", "id", user.ID, "error", err)
 		return fmt.Errorf("error updating user: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -153,20 +153,331 @@ func (r *UserRepo) Update(ctx context.Context, user domain.User) error {
 	return nil
 }
 
+// SetEmailVerified отмечает email пользователя как подтвержденный
+func (r *UserRepo) SetEmailVerified(ctx context.Context, id int) error {
+	r.logger.DebugContext(ctx, "executing SetEmailVerified query", "id", id)
+
+	query := `UPDATE users SET email_verified = TRUE WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error verifying user email", "id", id, "error", err)
+		return fmt.Errorf("error verifying user email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user with id %d not found", id)
+	}
+
+	return nil
+}
+
+// GetByIdentity возвращает пользователя, к которому привязана внешняя учетная запись, или nil
+func (r *UserRepo) GetByIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	r.logger.DebugContext(ctx, "executing GetByIdentity query", "provider", provider, "subject", subject)
+
+	query := `
+		SELECT u.id, u.username, u.email, u.password_hash, u.avatar_url, u.role, u.email_verified, u.created_at, u.updated_at
+		FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.provider = $1 AND i.subject = $2
+	`
+
+	var user domain.User
+	if err := r.db.GetContext(ctx, &user, query, provider, subject); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.ErrorContext(ctx, "error selecting user by identity", "provider", provider, "error", err)
+		return nil, fmt.Errorf("error selecting user by identity: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CreateWithIdentity создает пользователя и привязывает к нему внешнюю учетную запись в одной транзакции
+func (r *UserRepo) CreateWithIdentity(ctx context.Context, user domain.User, identity domain.UserIdentity) (int, error) {
+	r.logger.DebugContext(ctx, "executing CreateWithIdentity query", "username", user.Username, "provider", identity.Provider)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (username, email, password_hash, avatar_url, role, email_verified)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, user.Username, user.Email, user.PasswordHash, user.AvatarURL, user.Role, user.EmailVerified).Scan(&id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting user", "error", err)
+		return 0, fmt.Errorf("error inserting user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+	`, id, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting user identity", "provider", identity.Provider, "error", err)
+		return 0, fmt.Errorf("error inserting user identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+// AddIdentity привязывает внешнюю учетную запись к существующему пользователю
+func (r *UserRepo) AddIdentity(ctx context.Context, identity domain.UserIdentity) error {
+	r.logger.DebugContext(ctx, "executing AddIdentity query", "user_id", identity.UserID, "provider", identity.Provider)
+
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error inserting user identity", "user_id", identity.UserID, "error", err)
+		return fmt.Errorf("error inserting user identity: %w", err)
+	}
+
+	return nil
+}
+
+// GetTOTP возвращает настройки двухфакторной аутентификации пользователя или nil
+func (r *UserRepo) GetTOTP(ctx context.Context, userID int) (*domain.TOTP, error) {
+	r.logger.DebugContext(ctx, "executing GetTOTP query", "user_id", userID)
+
+	query := `
+		SELECT user_id, secret, enabled, created_at, enabled_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	var totp domain.TOTP
+	if err := r.db.GetContext(ctx, &totp, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.logger.ErrorContext(ctx, "error selecting totp", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("error selecting totp: %w", err)
+	}
+
+	return &totp, nil
+}
+
+// SaveTOTPSecret сохраняет новый секрет, ожидающий подтверждения; включенная 2FA не изменяется
+func (r *UserRepo) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	r.logger.DebugContext(ctx, "executing SaveTOTPSecret query", "user_id", userID)
+
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled = FALSE
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error saving totp secret", "user_id", userID, "error", err)
+		return fmt.Errorf("error saving totp secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("two-factor authentication already enabled")
+	}
+
+	return nil
+}
+
+// EnableTOTP включает двухфакторную аутентификацию и сохраняет коды восстановления в одной транзакции
+func (r *UserRepo) EnableTOTP(ctx context.Context, userID int, recoveryCodeHashes []string) error {
+	r.logger.DebugContext(ctx, "executing EnableTOTP query", "user_id", userID)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_totp SET enabled = TRUE, enabled_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND enabled = FALSE
+	`, userID)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error enabling totp", "user_id", userID, "error", err)
+		return fmt.Errorf("error enabling totp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("two-factor authentication already enabled")
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		r.logger.ErrorContext(ctx, "error saving recovery codes", "user_id", userID, "error", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTOTP отключает двухфакторную аутентификацию и удаляет коды восстановления
+func (r *UserRepo) DeleteTOTP(ctx context.Context, userID int) error {
+	r.logger.DebugContext(ctx, "executing DeleteTOTP query", "user_id", userID)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		r.logger.ErrorContext(ctx, "error deleting recovery codes", "user_id", userID, "error", err)
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		r.logger.ErrorContext(ctx, "error deleting totp", "user_id", userID, "error", err)
+		return fmt.Errorf("error deleting totp: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes заменяет все коды восстановления пользователя новыми
+func (r *UserRepo) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	r.logger.DebugContext(ctx, "executing ReplaceRecoveryCodes query", "user_id", userID, "count", len(codeHashes))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error starting transaction", "error", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		r.logger.ErrorContext(ctx, "error saving recovery codes", "user_id", userID, "error", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.logger.ErrorContext(ctx, "error committing transaction", "error", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode отмечает код восстановления использованным; false, если код неизвестен или уже использован
+func (r *UserRepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	r.logger.DebugContext(ctx, "executing UseRecoveryCode query", "user_id", userID)
+
+	query := `
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error using recovery code", "user_id", userID, "error", err)
+		return false, fmt.Errorf("error using recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
+		return false, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// CountRecoveryCodes возвращает количество неиспользованных кодов восстановления
+func (r *UserRepo) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	r.logger.DebugContext(ctx, "executing CountRecoveryCodes query", "user_id", userID)
+
+	var count int
+	err := r.db.GetContext(ctx, &count,
+		`SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error counting recovery codes", "user_id", userID, "error", err)
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// replaceRecoveryCodes удаляет старые коды восстановления и добавляет новые в транзакции tx
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return fmt.Errorf("error inserting recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // Delete удаляет пользователя по ID
 func (r *UserRepo) Delete(ctx context.Context, id int) error {
-	r.logger.Debug("executing Delete user query", "id", id)
+	r.logger.DebugContext(ctx, "executing Delete user query", "id", id)
 
 	query := "DELETE FROM users WHERE id = $1"
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		r.logger.Error("error deleting user", "id", id, "error", err)
+		r.logger.ErrorContext(ctx, "error deleting user", "id", id, "error", err)
 		return fmt.Errorf("error deleting user: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -179,7 +490,7 @@ func (r *UserRepo) Delete(ctx context.Context, id int) error {
 
 // AddBookmark добавляет закладку для пользователя
 func (r *UserRepo) AddBookmark(ctx context.Context, userID, mangaID int) error {
-	r.logger.Debug("executing AddBookmark query", "user_id", userID, "manga_id", mangaID)
+	r.logger.DebugContext(ctx, "executing AddBookmark query", "user_id", userID, "manga_id", mangaID)
 
 	query := `
 		INSERT INTO bookmarks (user_id, manga_id)
@@ -189,7 +500,7 @@ func (r *UserRepo) AddBookmark(ctx context.Context, userID, mangaID int) error {
 
 	_, err := r.db.ExecContext(ctx, query, userID, mangaID)
 	if err != nil {
-		r.logger.Error("error adding bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
+		r.logger.ErrorContext(ctx, "error adding bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
 		return fmt.Errorf("error adding bookmark: %w", err)
 	}
 
@@ -198,18 +509,18 @@ func (r *UserRepo) AddBookmark(ctx context.Context, userID, mangaID int) error {
 
 // RemoveBookmark удаляет закладку пользователя
 func (r *UserRepo) RemoveBookmark(ctx context.Context, userID, mangaID int) error {
-	r.logger.Debug("executing RemoveBookmark query", "user_id", userID, "manga_id", mangaID)
+	r.logger.DebugContext(ctx, "executing RemoveBookmark query", "user_id", userID, "manga_id", mangaID)
 
 	query := "DELETE FROM bookmarks WHERE user_id = $1 AND manga_id = $2"
 	result, err := r.db.ExecContext(ctx, query, userID, mangaID)
 	if err != nil {
-		r.logger.Error("error removing bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
+		r.logger.ErrorContext(ctx, "error removing bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
 		return fmt.Errorf("error removing bookmark: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.logger.Error("error getting rows affected", "error", err)
+		r.logger.ErrorContext(ctx, "error getting rows affected", "error", err)
 		return fmt.Errorf("error getting rows affected: %w", err)
 	}
 
@@ -220,42 +531,51 @@ func (r *UserRepo) RemoveBookmark(ctx context.Context, userID, mangaID int) erro
 	return nil
 }
 
-// GetBookmarks возвращает список закладок пользователя
-func (r *UserRepo) GetBookmarks(ctx context.Context, userID int) ([]domain.Manga, error) {
-	r.logger.Debug("executing GetBookmarks query", "user_id", userID)
+// GetBookmarks возвращает закладки пользователя от новых к старым, начиная после позиции after
+func (r *UserRepo) GetBookmarks(ctx context.Context, userID int, after *domain.PageCursor, limit int) ([]domain.BookmarkedManga, error) {
+	r.logger.DebugContext(ctx, "executing GetBookmarks query", "user_id", userID, "limit", limit)
 
 	query := `
 		SELECT m.id, m.title, m.alter_title, m.description, m.cover_url, 
-		m.year, m.status, m.author, m.artist, m.rating, 
-		m.created_at, m.updated_at
+		m.year, m.status, m.author, m.artist, m.rating, m.rating_count,
+		m.team_id, m.created_at, m.updated_at, b.created_at AS bookmarked_at
 		FROM manga m
 		JOIN bookmarks b ON m.id = b.manga_id
 		WHERE b.user_id = $1
-		ORDER BY b.created_at DESC
 	`
+	args := []interface{}{userID}
+
+	// Закладки, добавленные одновременно, упорядочены по ID манги
+	if after != nil {
+		query += " AND (b.created_at, b.manga_id) < ($2, $3)"
+		args = append(args, after.Time, after.ID)
+	}
 
-	var mangas []domain.Manga
-	if err := r.db.SelectContext(ctx, &mangas, query, userID); err != nil {
-		r.logger.Error("error selecting bookmarks", "user_id", userID, "error", err)
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY b.created_at DESC, b.manga_id DESC LIMIT $%d", len(args))
+
+	var bookmarks []domain.BookmarkedManga
+	if err := r.db.SelectContext(ctx, &bookmarks, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting bookmarks", "user_id", userID, "error", err)
 		return nil, fmt.Errorf("error selecting bookmarks: %w", err)
 	}
 
 	// Получаем жанры для каждой манги
-	for i := range mangas {
-		genres, err := r.getMangaGenres(ctx, mangas[i].ID)
+	for i := range bookmarks {
+		genres, err := r.getMangaGenres(ctx, bookmarks[i].ID)
 		if err != nil {
-			r.logger.Error("error getting manga genres", "manga_id", mangas[i].ID, "error", err)
+			r.logger.ErrorContext(ctx, "error getting manga genres", "manga_id", bookmarks[i].ID, "error", err)
 			continue
 		}
-		mangas[i].Genres = genres
+		bookmarks[i].Genres = genres
 	}
 
-	return mangas, nil
+	return bookmarks, nil
 }
 
 // SaveReadHistory сохраняет историю чтения
 func (r *UserRepo) SaveReadHistory(ctx context.Context, history domain.ReadHistory) error {
-	r.logger.Debug("executing SaveReadHistory query",
+	r.logger.DebugContext(ctx, "executing SaveReadHistory query",
 		"user_id", history.UserID,
 		"manga_id", history.MangaID,
 		"chapter_id", history.ChapterID)
@@ -278,33 +598,66 @@ func (r *UserRepo) SaveReadHistory(ctx context.Context, history domain.ReadHisto
 	)
 
 	if err != nil {
-		r.logger.Error("error saving read history", "error", err)
+		r.logger.ErrorContext(ctx, "error saving read history", "error", err)
 		return fmt.Errorf("error saving read history: %w", err)
 	}
 
 	return nil
 }
 
-// GetReadHistory возвращает историю чтения пользователя
-func (r *UserRepo) GetReadHistory(ctx context.Context, userID int) ([]domain.ReadHistory, error) {
-	r.logger.Debug("executing GetReadHistory query", "user_id", userID)
+// GetReadHistory возвращает историю чтения пользователя от новых записей к старым, начиная после позиции after
+func (r *UserRepo) GetReadHistory(ctx context.Context, userID int, after *domain.PageCursor, limit int) ([]domain.ReadHistory, error) {
+	r.logger.DebugContext(ctx, "executing GetReadHistory query", "user_id", userID, "limit", limit)
 
 	query := `
 		SELECT id, user_id, manga_id, chapter_id, page, read_at
 		FROM read_history
 		WHERE user_id = $1
-		ORDER BY read_at DESC
 	`
+	args := []interface{}{userID}
+
+	if after != nil {
+		query += " AND (read_at, id) < ($2, $3)"
+		args = append(args, after.Time, after.ID)
+	}
+
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY read_at DESC, id DESC LIMIT $%d", len(args))
 
 	var history []domain.ReadHistory
-	if err := r.db.SelectContext(ctx, &history, query, userID); err != nil {
-		r.logger.Error("error selecting read history", "user_id", userID, "error", err)
+	if err := r.db.SelectContext(ctx, &history, query, args...); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting read history", "user_id", userID, "error", err)
 		return nil, fmt.Errorf("error selecting read history: %w", err)
 	}
 
 	return history, nil
 }
 
+// GetChapterReadStates возвращает все главы манги, которую читал пользователь,
+// вместе с его прогрессом по каждой главе. Главы упорядочены по манге и номеру.
+func (r *UserRepo) GetChapterReadStates(ctx context.Context, userID int) ([]domain.ChapterReadState, error) {
+	r.logger.DebugContext(ctx, "executing GetChapterReadStates query", "user_id", userID)
+
+	query := `
+		SELECT c.manga_id, m.title AS manga_title, COALESCE(m.cover_url, '') AS cover_url,
+		c.id AS chapter_id, c.number AS chapter_number, c.title AS chapter_title, c.page_count,
+		rh.page, rh.read_at
+		FROM chapters c
+		JOIN manga m ON m.id = c.manga_id
+		LEFT JOIN read_history rh ON rh.chapter_id = c.id AND rh.user_id = $1
+		WHERE c.manga_id IN (SELECT manga_id FROM read_history WHERE user_id = $1)
+		ORDER BY c.manga_id, c.number
+	`
+
+	var states []domain.ChapterReadState
+	if err := r.db.SelectContext(ctx, &states, query, userID); err != nil {
+		r.logger.ErrorContext(ctx, "error selecting chapter read states", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("error selecting chapter read states: %w", err)
+	}
+
+	return states, nil
+}
+
 // getMangaGenres возвращает жанры для указанной манги
 func (r *UserRepo) getMangaGenres(ctx context.Context, mangaID int) ([]domain.Genre, error) {
 	query := `
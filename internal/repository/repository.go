@@ -7,23 +7,42 @@ import (
 
 // MangaRepository определяет методы для работы с мангой
 type MangaRepository interface {
+	// GetAll возвращает до filter.PageSize+1 манги: лишняя означает, что есть следующая страница.
+	// Общее количество подсчитывается только при filter.WithTotal, иначе возвращается 0.
 	GetAll(ctx context.Context, filter domain.MangaFilter) ([]domain.Manga, int, error)
 	GetByID(ctx context.Context, id int) (domain.Manga, error)
 	Create(ctx context.Context, manga domain.Manga) (int, error)
 	Update(ctx context.Context, manga domain.Manga) error
 	Delete(ctx context.Context, id int) error
 	GetGenres(ctx context.Context) ([]domain.Genre, error)
+	SetTeam(ctx context.Context, mangaID int, teamID *int) error
+	// ListSuggestSources возвращает названия и авторов всей манги для индекса подсказок поиска
+	ListSuggestSources(ctx context.Context) ([]domain.Manga, error)
+
+	// Методы для работы с водяным знаком (GetWatermark возвращает nil, если он не настроен)
+	GetWatermark(ctx context.Context, mangaID int) (*domain.Watermark, error)
+	SaveWatermark(ctx context.Context, watermark domain.Watermark) error
+	DeleteWatermark(ctx context.Context, mangaID int) error
+
+	// Методы для работы с оценками пользователей (рейтинг манги пересчитывается в той же транзакции)
+	SetRating(ctx context.Context, userID, mangaID, score int) (domain.MangaRating, error)
+	DeleteRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error)
+	GetUserRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error)
 }
 
 // ChapterRepository определяет методы для работы с главами
 type ChapterRepository interface {
-	GetByMangaID(ctx context.Context, mangaID int) ([]domain.Chapter, error)
+	// GetByMangaID возвращает до limit глав манги по возрастанию номера, начиная после позиции after (nil - с начала)
+	GetByMangaID(ctx context.Context, mangaID int, after *domain.PageCursor, limit int) ([]domain.Chapter, error)
 	GetByID(ctx context.Context, id int) (domain.Chapter, error)
 	Create(ctx context.Context, chapter domain.Chapter) (int, error)
 	Update(ctx context.Context, chapter domain.Chapter) error
 	Delete(ctx context.Context, id int) error
 	GetPages(ctx context.Context, chapterID int) ([]domain.Page, error)
+	GetPage(ctx context.Context, id int) (domain.Page, error)
 	AddPage(ctx context.Context, page domain.Page) (int, error)
+	// AddPages вызывает save под блокировкой главы с номером первой новой страницы и добавляет возвращенные страницы
+	AddPages(ctx context.Context, chapterID int, save func(firstNumber int) ([]domain.Page, error)) ([]int, error)
 	DeletePage(ctx context.Context, id int) error
 }
 
@@ -34,16 +53,70 @@ type UserRepository interface {
 	GetByUsername(ctx context.Context, username string) (domain.User, error)
 	GetByEmail(ctx context.Context, email string) (domain.User, error)
 	Update(ctx context.Context, user domain.User) error
+	SetEmailVerified(ctx context.Context, id int) error
 	Delete(ctx context.Context, id int) error
 
-	// Методы для работы с закладками
+	// Методы для работы с внешними учетными записями (GetByIdentity возвращает nil, если учетная запись не привязана)
+	GetByIdentity(ctx context.Context, provider, subject string) (*domain.User, error)
+	CreateWithIdentity(ctx context.Context, user domain.User, identity domain.UserIdentity) (int, error)
+	AddIdentity(ctx context.Context, identity domain.UserIdentity) error
+
+	// Методы для работы с двухфакторной аутентификацией (GetTOTP возвращает nil, если 2FA не настраивалась)
+	GetTOTP(ctx context.Context, userID int) (*domain.TOTP, error)
+	SaveTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, recoveryCodeHashes []string) error
+	DeleteTOTP(ctx context.Context, userID int) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+
+	// Методы для работы с закладками (GetBookmarks возвращает до limit закладок от новых к старым после позиции after)
 	AddBookmark(ctx context.Context, userID, mangaID int) error
 	RemoveBookmark(ctx context.Context, userID, mangaID int) error
-	GetBookmarks(ctx context.Context, userID int) ([]domain.Manga, error)
+	GetBookmarks(ctx context.Context, userID int, after *domain.PageCursor, limit int) ([]domain.BookmarkedManga, error)
 
-	// Методы для работы с историей чтения
+	// Методы для работы с историей чтения (GetReadHistory возвращает до limit записей от новых к старым после позиции after)
 	SaveReadHistory(ctx context.Context, history domain.ReadHistory) error
-	GetReadHistory(ctx context.Context, userID int) ([]domain.ReadHistory, error)
+	GetReadHistory(ctx context.Context, userID int, after *domain.PageCursor, limit int) ([]domain.ReadHistory, error)
+	GetChapterReadStates(ctx context.Context, userID int) ([]domain.ChapterReadState, error)
+}
+
+// CommentRepository определяет методы для работы с комментариями
+type CommentRepository interface {
+	Create(ctx context.Context, comment domain.Comment) (int, error)
+	GetByID(ctx context.Context, id int) (domain.Comment, error)
+	List(ctx context.Context, filter domain.CommentFilter) ([]domain.Comment, error)
+	Update(ctx context.Context, id int, body string, spoiler bool) error
+	SetHidden(ctx context.Context, id int, hidden bool, moderatorID int) error
+	SoftDelete(ctx context.Context, id, deletedBy int) error
+
+	// Методы для закрытия веток комментариев
+	IsLocked(ctx context.Context, target domain.CommentTarget) (bool, error)
+	Lock(ctx context.Context, target domain.CommentTarget, moderatorID int) error
+	Unlock(ctx context.Context, target domain.CommentTarget) error
+}
+
+// TeamRepository определяет методы для работы с командами
+type TeamRepository interface {
+	List(ctx context.Context) ([]domain.Team, error)
+	GetByID(ctx context.Context, id int) (domain.Team, error)
+	GetByName(ctx context.Context, name string) (domain.Team, error)
+	Create(ctx context.Context, team domain.Team, ownerID int) (int, error)
+	Update(ctx context.Context, team domain.Team) error
+	Delete(ctx context.Context, id int) error
+
+	// Методы для работы с участниками (GetMemberRole возвращает пустую строку, если пользователь не участник)
+	GetMembers(ctx context.Context, teamID int) ([]domain.TeamMember, error)
+	GetMemberRole(ctx context.Context, teamID, userID int) (string, error)
+	GetUserTeams(ctx context.Context, userID int) ([]domain.UserTeam, error)
+	SetMember(ctx context.Context, teamID, userID int, role string) error
+	RemoveMember(ctx context.Context, teamID, userID int) error
+}
+
+// AuditRepository определяет методы для работы с журналом аудита (записи только добавляются)
+type AuditRepository interface {
+	Create(ctx context.Context, entry domain.AuditEntry) (int64, error)
+	List(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error)
 }
 
 // Repositories объединяет все репозитории для удобного внедрения зависимостей
@@ -51,4 +124,7 @@ type Repositories struct {
 	Manga   MangaRepository
 	Chapter ChapterRepository
 	User    UserRepository
+	Comment CommentRepository
+	Team    TeamRepository
+	Audit   AuditRepository
 }
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/logger"
+)
+
+// Ограничения для журнала аудита
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// Действия в журнале аудита
+const (
+	AuditActionCreate          = "create"
+	AuditActionUpdate          = "update"
+	AuditActionDelete          = "delete"
+	AuditActionSetTeam         = "set_team"
+	AuditActionSaveWatermark   = "save_watermark"
+	AuditActionDeleteWatermark = "delete_watermark"
+	AuditActionAddPages        = "add_pages"
+	AuditActionHide            = "hide"
+	AuditActionUnhide          = "unhide"
+	AuditActionLockThread      = "lock_thread"
+	AuditActionUnlockThread    = "unlock_thread"
+	AuditActionSetMember       = "set_member"
+	AuditActionRemoveMember    = "remove_member"
+	AuditActionUnlockLogin     = "unlock_login"
+	AuditActionLoginLockout    = "login_lockout"
+	AuditActionRevokeSession   = "revoke_session"
+)
+
+// AuditActor описывает автора действия: пользователя запроса и его адрес
+type AuditActor struct {
+	UserID   int
+	Username string
+	Role     string
+	IP       string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor возвращает контекст с автором действий для журнала аудита
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// auditActorFrom возвращает автора действий из контекста
+func auditActorFrom(ctx context.Context) AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor
+}
+
+// AuditService ведет журнал аудита изменений.
+// Сервисы вызывают Record после успешного изменения; автор действия, ID запроса и IP берутся из контекста.
+type AuditService struct {
+	repo   repository.AuditRepository
+	logger *slog.Logger
+}
+
+// NewAuditService создает новый экземпляр AuditService
+func NewAuditService(repo repository.AuditRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Record записывает действие над объектом со снимками состояния до и после изменения
+// (nil - объекта не было или больше нет). Ошибка записи в журнал не отменяет действие и только логируется.
+func (s *AuditService) Record(ctx context.Context, action, entityType string, entityID int, before, after any) {
+	if s == nil {
+		return
+	}
+
+	actor := auditActorFrom(ctx)
+	entry := domain.AuditEntry{
+		ActorID:    actor.UserID,
+		ActorName:  actor.Username,
+		ActorRole:  actor.Role,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  logger.RequestID(ctx),
+		IP:         actor.IP,
+	}
+
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		s.logger.ErrorContext(ctx, "failed to encode audit snapshot", "action", action, "entity_type", entityType, "error", err)
+		return
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		s.logger.ErrorContext(ctx, "failed to encode audit snapshot", "action", action, "entity_type", entityType, "error", err)
+		return
+	}
+
+	// Запись в журнал не должна зависеть от отмены запроса клиентом
+	if _, err := s.repo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write audit entry",
+			"action", action,
+			"entity_type", entityType,
+			"entity_id", entityID,
+			"actor_id", actor.UserID,
+			"error", err)
+	}
+}
+
+// List возвращает страницу журнала аудита, начиная с новых записей
+func (s *AuditService) List(ctx context.Context, filter domain.AuditFilter, cursor string, limit int) (domain.AuditPage, error) {
+	s.logger.DebugContext(ctx, "listing audit entries", "entity_type", filter.EntityType, "cursor", cursor)
+
+	afterID, err := decodeAuditCursor(cursor)
+	if err != nil {
+		return domain.AuditPage{}, err
+	}
+
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		return domain.AuditPage{}, errors.New("invalid time range: from is after to")
+	}
+
+	if limit <= 0 || limit > maxAuditPageSize {
+		limit = defaultAuditPageSize
+	}
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	filter.Cursor = afterID
+	filter.Limit = limit + 1
+
+	entries, err := s.repo.List(ctx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list audit entries", "error", err)
+		return domain.AuditPage{}, err
+	}
+
+	page := domain.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = encodeAuditCursor(entries[limit-1].ID)
+	}
+	if page.Entries == nil {
+		page.Entries = []domain.AuditEntry{}
+	}
+
+	return page, nil
+}
+
+// auditSnapshot кодирует состояние объекта в JSON
+func auditSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// encodeAuditCursor кодирует курсор страницы журнала аудита
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeAuditCursor декодирует курсор страницы журнала аудита; пустой курсор означает первую страницу
+func decodeAuditCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+
+	id, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return id, nil
+}
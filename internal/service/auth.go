@@ -5,9 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/jwtkeys"
+	"github.com/LirikaOne-Back/manga-reader3/internal/mailer"
+	"github.com/LirikaOne-Back/manga-reader3/internal/oidc"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -17,31 +22,60 @@ import (
 
 // AuthService предоставляет методы для работы с аутентификацией и авторизацией
 type AuthService struct {
-	userRepo     repository.UserRepository
-	logger       *slog.Logger
-	jwtSecret    string
-	accessTTL    time.Duration
-	refreshTTL   time.Duration
-	jwtAlgorithm string
-	redisClient  *redis.Client
+	userRepo    repository.UserRepository
+	logger      *slog.Logger
+	keys        *jwtkeys.KeySet
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	redisClient *redis.Client
+	tokens      *refreshTokenStore
+	mailer      mailer.Mailer
+	mailTokens  *mailTokenStore
+	appURL      string
+	verifyTTL   time.Duration
+	resetTTL    time.Duration
+	oidc        map[string]*oidc.Provider
+	oidcStates  *oidcStateStore
+
+	twoFactorRequired bool
+	twoFactorIssuer   string
+	challenges        *twoFactorChallengeStore
+
+	loginThrottle *loginThrottle
+
+	audit *AuditService
 }
 
-// JWTClaims структура для JWT-токена
+// JWTClaims структура для JWT-токена (jti хранится в RegisteredClaims.ID)
 type JWTClaims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Type     string `json:"type"` // "access" или "refresh"
+	UserID        int    `json:"user_id"`
+	Username      string `json:"username"`
+	Role          string `json:"role"`
+	Type          string `json:"type"`          // "access" или "refresh"
+	SessionID     string `json:"sid,omitempty"` // ID сессии (семейства refresh-токенов)
+	EmailVerified bool   `json:"email_verified"`
+	TwoFactor     bool   `json:"2fa"` // Требование 2FA для роли выполнено
 	jwt.RegisteredClaims
 }
 
 // AuthConfig конфигурация для AuthService
 type AuthConfig struct {
-	JWTSecret    string
-	AccessTTL    time.Duration
-	RefreshTTL   time.Duration
-	JWTAlgorithm string
-	RedisClient  *redis.Client
+	Keys        *jwtkeys.KeySet // Ключи подписи JWT
+	AccessTTL   time.Duration
+	RefreshTTL  time.Duration
+	RedisClient *redis.Client
+	Mailer      mailer.Mailer
+	AppURL      string        // Адрес фронтенда для ссылок в письмах
+	VerifyTTL   time.Duration // Время жизни ссылки подтверждения email
+	ResetTTL    time.Duration // Время жизни ссылки сброса пароля
+	OIDC        []*oidc.Provider
+
+	TwoFactorRequired bool   // 2FA обязательна для ролей moderator и admin
+	TwoFactorIssuer   string // Название сервиса в приложении-аутентификаторе
+
+	LoginThrottle LoginThrottleConfig
+
+	Audit *AuditService // Журнал аудита действий администраторов
 }
 
 // NewAuthService создает новый экземпляр AuthService
@@ -50,20 +84,43 @@ func NewAuthService(
 	logger *slog.Logger,
 	cfg AuthConfig,
 ) *AuthService {
-	return &AuthService{
-		userRepo:     userRepo,
-		logger:       logger,
-		jwtSecret:    cfg.JWTSecret,
-		accessTTL:    cfg.AccessTTL,
-		refreshTTL:   cfg.RefreshTTL,
-		jwtAlgorithm: cfg.JWTAlgorithm,
-		redisClient:  cfg.RedisClient,
+	s := &AuthService{
+		userRepo:    userRepo,
+		logger:      logger,
+		keys:        cfg.Keys,
+		accessTTL:   cfg.AccessTTL,
+		refreshTTL:  cfg.RefreshTTL,
+		redisClient: cfg.RedisClient,
+		tokens: &refreshTokenStore{
+			client: cfg.RedisClient,
+			ttl:    cfg.RefreshTTL,
+		},
+		mailer:     cfg.Mailer,
+		mailTokens: &mailTokenStore{client: cfg.RedisClient},
+		appURL:     strings.TrimRight(cfg.AppURL, "/"),
+		verifyTTL:  cfg.VerifyTTL,
+		resetTTL:   cfg.ResetTTL,
+		oidc:       make(map[string]*oidc.Provider, len(cfg.OIDC)),
+		oidcStates: &oidcStateStore{client: cfg.RedisClient},
+
+		twoFactorRequired: cfg.TwoFactorRequired,
+		twoFactorIssuer:   cfg.TwoFactorIssuer,
+		challenges:        &twoFactorChallengeStore{client: cfg.RedisClient},
+
+		loginThrottle: &loginThrottle{client: cfg.RedisClient, cfg: cfg.LoginThrottle},
+
+		audit: cfg.Audit,
+	}
+	for _, provider := range cfg.OIDC {
+		s.oidc[provider.Name()] = provider
 	}
+
+	return s
 }
 
 // Register регистрирует нового пользователя
 func (s *AuthService) Register(ctx context.Context, input domain.UserSignup) (domain.User, error) {
-	s.logger.Info("registering new user", "username", input.Username, "email", input.Email)
+	s.logger.InfoContext(ctx, "registering new user", "username", input.Username, "email", input.Email)
 
 	// Проверяем, существует ли пользователь с таким именем
 	_, err := s.userRepo.GetByUsername(ctx, input.Username)
@@ -80,7 +137,7 @@ func (s *AuthService) Register(ctx context.Context, input domain.UserSignup) (do
 	// Хешируем пароль
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		s.logger.Error("failed to hash password", "error", err)
+		s.logger.ErrorContext(ctx, "failed to hash password", "error", err)
 		return domain.User{}, fmt.Errorf("failed to hash password: %w", err)
 	}
 
@@ -96,145 +153,362 @@ func (s *AuthService) Register(ctx context.Context, input domain.UserSignup) (do
 	// Создаем пользователя
 	id, err := s.userRepo.Create(ctx, user)
 	if err != nil {
-		s.logger.Error("failed to create user", "error", err)
+		s.logger.ErrorContext(ctx, "failed to create user", "error", err)
 		return domain.User{}, fmt.Errorf("failed to create user: %w", err)
 	}
 
 	user.ID = id
-	s.logger.Info("user registered successfully", "id", id)
+	s.logger.InfoContext(ctx, "user registered successfully", "id", id)
+
+	// Ошибка отправки не отменяет регистрацию: письмо можно запросить повторно
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.logger.WarnContext(ctx, "failed to send verification email", "user_id", id, "error", err)
+	}
 
 	return user, nil
 }
 
+// SendVerificationEmail повторно отправляет письмо для подтверждения email
+func (s *AuthService) SendVerificationEmail(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return errors.New("user not found")
+	}
+
+	if user.EmailVerified {
+		return errors.New("email already verified")
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		s.logger.ErrorContext(ctx, "failed to send verification email", "user_id", userID, "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// VerifyEmail подтверждает email по токену из письма
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.mailTokens.consume(ctx, tokenPurposeEmailVerification, token)
+	if err != nil {
+		if !errors.Is(err, errMailTokenInvalid) {
+			s.logger.ErrorContext(ctx, "failed to check verification token", "error", err)
+		}
+		return err
+	}
+
+	if err := s.userRepo.SetEmailVerified(ctx, userID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to verify email", "user_id", userID, "error", err)
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "email verified successfully", "user_id", userID)
+	return nil
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой для сброса пароля.
+// Неизвестный email не считается ошибкой, чтобы по ответу нельзя было узнать, зарегистрирован ли адрес.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	s.logger.InfoContext(ctx, "password reset requested", "email", email)
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		s.logger.WarnContext(ctx, "password reset for unknown email", "email", email)
+		return nil
+	}
+
+	token, err := s.mailTokens.issue(ctx, tokenPurposePasswordReset, user.ID, s.resetTTL)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to issue password reset token", "user_id", user.ID, "error", err)
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Чтобы задать новый пароль, перейдите по ссылке:\n%s/reset-password?token=%s\n\n"+
+			"Ссылка действительна %d ч. Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n",
+			user.Username, s.appURL, token, int(s.resetTTL.Hours())),
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to send password reset email", "user_id", user.ID, "error", err)
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма и завершает все сессии пользователя
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	userID, err := s.mailTokens.consume(ctx, tokenPurposePasswordReset, token)
+	if err != nil {
+		if !errors.Is(err, errMailTokenInvalid) {
+			s.logger.ErrorContext(ctx, "failed to check password reset token", "error", err)
+		}
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "resetting password", "user_id", userID)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return errMailTokenInvalid
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to hash password", "error", err)
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user.PasswordHash = string(hashedPassword)
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update user", "error", err)
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	// Письмо пришло на адрес пользователя, значит email подтвержден
+	if !user.EmailVerified {
+		if err := s.userRepo.SetEmailVerified(ctx, userID); err != nil {
+			s.logger.WarnContext(ctx, "failed to verify email", "user_id", userID, "error", err)
+		}
+	}
+
+	if err := s.Logout(ctx, userID); err != nil {
+		s.logger.WarnContext(ctx, "failed to invalidate tokens", "error", err)
+	}
+
+	s.logger.InfoContext(ctx, "password reset successfully", "user_id", userID)
+	return nil
+}
+
+// sendVerificationEmail выдает токен подтверждения и отправляет письмо со ссылкой
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user domain.User) error {
+	token, err := s.mailTokens.issue(ctx, tokenPurposeEmailVerification, user.ID, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Чтобы подтвердить адрес электронной почты, перейдите по ссылке:\n%s/verify-email?token=%s\n\n"+
+			"Ссылка действительна %d ч. До подтверждения нельзя оставлять комментарии и загружать файлы.\n",
+			user.Username, s.appURL, token, int(s.verifyTTL.Hours())),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
 // Login выполняет вход пользователя и возвращает токены
-func (s *AuthService) Login(ctx context.Context, input domain.UserLogin) (domain.TokenResponse, error) {
-	s.logger.Info("processing login request", "username", input.Username)
+func (s *AuthService) Login(ctx context.Context, input domain.UserLogin, client domain.ClientInfo) (domain.TokenResponse, error) {
+	s.logger.InfoContext(ctx, "processing login request", "username", input.Username)
+
+	// Пока действует задержка или блокировка, пароль не проверяется
+	retryAfter, err := s.loginThrottle.check(ctx, input.Username, client.IP)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check login throttle", "error", err)
+		return domain.TokenResponse{}, err
+	}
+	if retryAfter > 0 {
+		s.logger.WarnContext(ctx, "login throttled", "username", input.Username, "ip", client.IP, "retry_after", retryAfter)
+		return domain.TokenResponse{}, &LoginThrottledError{RetryAfter: retryAfter}
+	}
 
 	// Получаем пользователя
 	user, err := s.userRepo.GetByUsername(ctx, input.Username)
 	if err != nil {
-		s.logger.Warn("user not found", "username", input.Username)
+		s.logger.WarnContext(ctx, "user not found", "username", input.Username)
+		s.recordLoginFailure(ctx, 0, input.Username, client.IP)
 		return domain.TokenResponse{}, errors.New("invalid username or password")
 	}
 
 	// Проверяем пароль
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password))
 	if err != nil {
-		s.logger.Warn("invalid password", "username", input.Username)
+		s.logger.WarnContext(ctx, "invalid password", "username", input.Username)
+		s.recordLoginFailure(ctx, user.ID, input.Username, client.IP)
 		return domain.TokenResponse{}, errors.New("invalid username or password")
 	}
 
-	// Генерируем токены
-	accessToken, err := s.generateToken(user, "access")
-	if err != nil {
-		s.logger.Error("failed to generate access token", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to generate access token: %w", err)
+	if err := s.loginThrottle.reset(ctx, input.Username); err != nil {
+		s.logger.WarnContext(ctx, "failed to reset login throttle", "username", input.Username, "error", err)
 	}
 
-	refreshToken, err := s.generateToken(user, "refresh")
+	return s.authenticate(ctx, user, client)
+}
+
+// recordLoginFailure учитывает неудачную попытку входа и пишет в лог начавшиеся блокировки.
+// Блокировка существующего пользователя (userID > 0) по имени также записывается в журнал аудита.
+func (s *AuthService) recordLoginFailure(ctx context.Context, userID int, username, ip string) {
+	lockouts, err := s.loginThrottle.fail(ctx, username, ip)
 	if err != nil {
-		s.logger.Error("failed to generate refresh token", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to generate refresh token: %w", err)
+		s.logger.ErrorContext(ctx, "failed to record login failure", "username", username, "error", err)
 	}
 
-	// Сохраняем refresh token в Redis
-	refreshTokenID := uuid.New().String()
-	err = s.redisClient.Set(ctx,
-		fmt.Sprintf("refresh_token:%d:%s", user.ID, refreshTokenID),
-		refreshToken,
-		s.refreshTTL).Err()
+	for _, lockout := range lockouts {
+		s.logger.WarnContext(ctx, "login locked after failed attempts",
+			"audit", "login_lockout",
+			"scope", lockout.scope,
+			"username", username,
+			"ip", ip,
+			"attempts", lockout.attempts,
+			"duration", lockout.duration,
+		)
+
+		if lockout.scope == "user" && userID > 0 {
+			s.audit.Record(ctx, AuditActionLoginLockout, domain.AuditEntityUser, userID, nil,
+				map[string]any{"attempts": lockout.attempts, "duration": lockout.duration.String()})
+		}
+	}
+}
+
+// UnlockAccount снимает блокировку входа для пользователя (для администраторов)
+func (s *AuthService) UnlockAccount(ctx context.Context, adminID, userID int) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		s.logger.Error("failed to save refresh token to Redis", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to save refresh token: %w", err)
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return errors.New("user not found")
 	}
 
-	s.logger.Info("user logged in successfully", "id", user.ID)
+	unlocked, err := s.loginThrottle.unlock(ctx, user.Username)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to unlock account", "user_id", userID, "error", err)
+		return err
+	}
 
-	return domain.TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int64(s.accessTTL.Seconds()),
-	}, nil
+	s.logger.InfoContext(ctx, "account unlocked",
+		"user_id", userID,
+		"username", user.Username,
+		"admin_id", adminID,
+		"was_locked", unlocked,
+	)
+	s.audit.Record(ctx, AuditActionUnlockLogin, domain.AuditEntityUser, userID,
+		map[string]any{"login_locked": unlocked}, map[string]any{"login_locked": false})
+	return nil
 }
 
 // RefreshToken обновляет токены по refresh-токену
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (domain.TokenResponse, error) {
-	s.logger.Info("refreshing token")
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, client domain.ClientInfo) (domain.TokenResponse, error) {
+	s.logger.InfoContext(ctx, "refreshing token")
 
 	// Парсим refresh token
 	claims, err := s.parseToken(refreshToken)
 	if err != nil {
-		s.logger.Warn("invalid refresh token", "error", err)
+		s.logger.WarnContext(ctx, "invalid refresh token", "error", err)
 		return domain.TokenResponse{}, errors.New("invalid refresh token")
 	}
 
 	// Проверяем тип токена
 	if claims.Type != "refresh" {
-		s.logger.Warn("invalid token type", "type", claims.Type)
+		s.logger.WarnContext(ctx, "invalid token type", "type", claims.Type)
 		return domain.TokenResponse{}, errors.New("invalid token type")
 	}
 
-	// Проверяем наличие токена в Redis
-	// Для простоты пропустим эту проверку, так как у нас нет ID токена в claims
-	// В реальном приложении следует добавить ID токена в claims и проверять его наличие в Redis
+	if claims.ID == "" || claims.SessionID == "" {
+		s.logger.WarnContext(ctx, "refresh token without jti", "user_id", claims.UserID)
+		return domain.TokenResponse{}, errors.New("invalid refresh token")
+	}
 
 	// Получаем пользователя
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
-		s.logger.Warn("user not found", "id", claims.UserID)
+		s.logger.WarnContext(ctx, "user not found", "id", claims.UserID)
 		return domain.TokenResponse{}, errors.New("user not found")
 	}
 
-	// Генерируем новые токены
-	newAccessToken, err := s.generateToken(user, "access")
+	totp, err := s.userRepo.GetTOTP(ctx, user.ID)
 	if err != nil {
-		s.logger.Error("failed to generate access token", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to generate access token: %w", err)
+		s.logger.ErrorContext(ctx, "failed to get totp", "user_id", user.ID, "error", err)
+		return domain.TokenResponse{}, err
 	}
+	twoFactor := (totp != nil && totp.Enabled) || !s.twoFactorRequiredFor(user.Role)
 
-	newRefreshToken, err := s.generateToken(user, "refresh")
+	// Генерируем новые токены
+	refreshTokenID := uuid.New().String()
+	tokens, err := s.generateTokenPair(ctx, user, twoFactor, claims.SessionID, refreshTokenID)
 	if err != nil {
-		s.logger.Error("failed to generate refresh token", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to generate refresh token: %w", err)
+		return domain.TokenResponse{}, err
 	}
 
-	// Сохраняем новый refresh token в Redis
-	refreshTokenID := uuid.New().String()
-	err = s.redisClient.Set(ctx,
-		fmt.Sprintf("refresh_token:%d:%s", user.ID, refreshTokenID),
-		newRefreshToken,
-		s.refreshTTL).Err()
+	// Атомарно заменяем старый refresh token новым
+	family, err := s.tokens.rotate(ctx, user.ID, claims.ID, refreshTokenID, client.IP)
 	if err != nil {
-		s.logger.Error("failed to save refresh token to Redis", "error", err)
-		return domain.TokenResponse{}, fmt.Errorf("failed to save refresh token: %w", err)
+		switch {
+		case errors.Is(err, errRefreshTokenReused):
+			s.logger.WarnContext(ctx, "refresh token reuse detected, token family revoked",
+				"user_id", user.ID, "jti", claims.ID, "family", family)
+			return domain.TokenResponse{}, errRefreshTokenReused
+		case errors.Is(err, errRefreshTokenInvalid):
+			s.logger.WarnContext(ctx, "refresh token revoked or expired", "user_id", user.ID, "jti", claims.ID)
+			return domain.TokenResponse{}, errRefreshTokenInvalid
+		default:
+			s.logger.ErrorContext(ctx, "failed to rotate refresh token", "error", err)
+			return domain.TokenResponse{}, err
+		}
 	}
 
-	// Инвалидируем старый refresh token
-	// Опять же, для простоты пропустим эту операцию
-
-	s.logger.Info("tokens refreshed successfully", "user_id", user.ID)
+	s.logger.InfoContext(ctx, "tokens refreshed successfully", "user_id", user.ID, "family", family)
 
-	return domain.TokenResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-		TokenType:    "Bearer",
-		ExpiresIn:    int64(s.accessTTL.Seconds()),
-	}, nil
+	return tokens, nil
 }
 
 // Logout выход пользователя (инвалидация всех токенов)
 func (s *AuthService) Logout(ctx context.Context, userID int) error {
-	s.logger.Info("logging out user", "id", userID)
+	s.logger.InfoContext(ctx, "logging out user", "id", userID)
 
-	// Удаляем все refresh токены для пользователя из Redis
-	err := s.redisClient.Del(ctx, fmt.Sprintf("refresh_token:%d:*", userID)).Err()
+	// Отзываем все семейства refresh-токенов пользователя
+	err := s.tokens.revokeAll(ctx, userID)
 	if err != nil {
-		s.logger.Error("failed to remove refresh tokens", "error", err)
+		s.logger.ErrorContext(ctx, "failed to remove refresh tokens", "error", err)
 		return fmt.Errorf("failed to remove refresh tokens: %w", err)
 	}
 
-	s.logger.Info("user logged out successfully", "id", userID)
+	s.logger.InfoContext(ctx, "user logged out successfully", "id", userID)
+	return nil
+}
+
+// ListSessions возвращает активные сессии пользователя, последние использованные первыми
+func (s *AuthService) ListSessions(ctx context.Context, userID int) ([]domain.Session, error) {
+	sessions, err := s.tokens.list(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list sessions", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession завершает одну сессию пользователя
+func (s *AuthService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	s.logger.InfoContext(ctx, "revoking session", "user_id", userID, "session", sessionID)
+
+	err := s.tokens.revoke(ctx, userID, sessionID)
+	if err != nil {
+		if !errors.Is(err, errSessionNotFound) {
+			s.logger.ErrorContext(ctx, "failed to revoke session", "user_id", userID, "session", sessionID, "error", err)
+		}
+		return err
+	}
+
+	// Завершение чужой сессии - действие администратора
+	if actor := auditActorFrom(ctx); actor.UserID != 0 && actor.UserID != userID {
+		s.audit.Record(ctx, AuditActionRevokeSession, domain.AuditEntityUser, userID,
+			map[string]any{"session_id": sessionID}, nil)
+	}
+
+	s.logger.InfoContext(ctx, "session revoked successfully", "user_id", userID, "session", sessionID)
 	return nil
 }
 
@@ -243,28 +517,33 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return s.parseToken(tokenString)
 }
 
+// JWKS возвращает открытые ключи для проверки токенов другими сервисами
+func (s *AuthService) JWKS() jwtkeys.JWKS {
+	return s.keys.JWKS()
+}
+
 // ChangePassword изменяет пароль пользователя
 func (s *AuthService) ChangePassword(ctx context.Context, userID int, oldPassword, newPassword string) error {
-	s.logger.Info("changing password", "user_id", userID)
+	s.logger.InfoContext(ctx, "changing password", "user_id", userID)
 
 	// Получаем пользователя
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		s.logger.Warn("user not found", "id", userID)
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
 		return errors.New("user not found")
 	}
 
 	// Проверяем старый пароль
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword))
 	if err != nil {
-		s.logger.Warn("invalid old password", "user_id", userID)
+		s.logger.WarnContext(ctx, "invalid old password", "user_id", userID)
 		return errors.New("invalid old password")
 	}
 
 	// Хешируем новый пароль
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
-		s.logger.Error("failed to hash password", "error", err)
+		s.logger.ErrorContext(ctx, "failed to hash password", "error", err)
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
@@ -272,22 +551,80 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int, oldPasswor
 	user.PasswordHash = string(hashedPassword)
 	err = s.userRepo.Update(ctx, user)
 	if err != nil {
-		s.logger.Error("failed to update user", "error", err)
+		s.logger.ErrorContext(ctx, "failed to update user", "error", err)
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
 	// Инвалидируем все токены пользователя
 	err = s.Logout(ctx, userID)
 	if err != nil {
-		s.logger.Warn("failed to invalidate tokens", "error", err)
+		s.logger.WarnContext(ctx, "failed to invalidate tokens", "error", err)
 	}
 
-	s.logger.Info("password changed successfully", "user_id", userID)
+	s.logger.InfoContext(ctx, "password changed successfully", "user_id", userID)
 	return nil
 }
 
+// startSession начинает новую сессию пользователя - семейство refresh-токенов - и возвращает токены.
+// twoFactor - вход подтвержден вторым фактором.
+func (s *AuthService) startSession(ctx context.Context, user domain.User, client domain.ClientInfo, twoFactor bool) (domain.TokenResponse, error) {
+	family := uuid.New().String()
+	refreshTokenID := uuid.New().String()
+
+	// Генерируем токены
+	tokens, err := s.generateTokenPair(ctx, user, twoFactor || !s.twoFactorRequiredFor(user.Role), family, refreshTokenID)
+	if err != nil {
+		return domain.TokenResponse{}, err
+	}
+
+	device := client.Device
+	if device == "" {
+		device = deviceName(client.UserAgent)
+	}
+
+	now := Now()
+	err = s.tokens.create(ctx, user.ID, family, refreshTokenID, domain.Session{
+		Device:     device,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to save refresh token to Redis", "error", err)
+		return domain.TokenResponse{}, err
+	}
+
+	s.logger.InfoContext(ctx, "user logged in successfully", "id", user.ID, "session", family)
+
+	return tokens, nil
+}
+
+// generateTokenPair генерирует access и refresh токены сессии, refreshTokenID становится jti refresh-токена.
+// twoFactor - выполнено ли требование 2FA для роли пользователя.
+func (s *AuthService) generateTokenPair(ctx context.Context, user domain.User, twoFactor bool, sessionID, refreshTokenID string) (domain.TokenResponse, error) {
+	accessToken, err := s.generateToken(user, twoFactor, "access", sessionID, uuid.New().String())
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to generate access token", "error", err)
+		return domain.TokenResponse{}, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	refreshToken, err := s.generateToken(user, twoFactor, "refresh", sessionID, refreshTokenID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to generate refresh token", "error", err)
+		return domain.TokenResponse{}, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return domain.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
+}
+
 // generateToken генерирует JWT токен
-func (s *AuthService) generateToken(user domain.User, tokenType string) (string, error) {
+func (s *AuthService) generateToken(user domain.User, twoFactor bool, tokenType, sessionID, tokenID string) (string, error) {
 	var expiresAt time.Time
 	if tokenType == "access" {
 		expiresAt = time.Now().Add(s.accessTTL)
@@ -296,19 +633,22 @@ func (s *AuthService) generateToken(user domain.User, tokenType string) (string,
 	}
 
 	claims := JWTClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-		Type:     tokenType,
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		Type:          tokenType,
+		SessionID:     sessionID,
+		EmailVerified: user.EmailVerified,
+		TwoFactor:     twoFactor,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.jwtSecret))
+	signedToken, err := s.keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -318,13 +658,8 @@ func (s *AuthService) generateToken(user domain.User, tokenType string) (string,
 
 // parseToken разбирает JWT токен
 func (s *AuthService) parseToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Проверяем алгоритм подписи
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	// Ключ и алгоритм подписи выбираются по kid из заголовка токена
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keys.Keyfunc, jwt.WithValidMethods(s.keys.Methods()))
 
 	if err != nil {
 		return nil, err
@@ -336,3 +671,47 @@ func (s *AuthService) parseToken(tokenString string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// deviceName определяет название устройства по User-Agent, например "Chrome on Windows"
+func deviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	platform := "Unknown OS"
+	for _, p := range []struct{ marker, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Macintosh", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, p.marker) {
+			platform = p.name
+			break
+		}
+	}
+
+	// Порядок важен: User-Agent Edge и Opera содержит "Chrome", а Chrome - "Safari"
+	browser := ""
+	for _, b := range []struct{ marker, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, b.marker) {
+			browser = b.name
+			break
+		}
+	}
+
+	if browser == "" {
+		return platform
+	}
+	return browser + " on " + platform
+}
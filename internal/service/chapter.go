@@ -2,66 +2,117 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+	"github.com/LirikaOne-Back/manga-reader3/internal/storage"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/utils"
 )
 
-// ChapterService предоставляет методы для работы с главами
+const (
+	maxPageImageSize       = 5 * 1024 * 1024   // Максимальный размер изображения одной страницы
+	maxPageImagePixels     = 50_000_000        // Максимальное количество пикселей изображения страницы
+	maxArchiveEntries      = 500               // Максимальное количество файлов в архиве главы
+	maxArchiveSize         = 300 * 1024 * 1024 // Максимальный размер файлов архива главы после распаковки
+	defaultChapterPageSize = 100               // Количество глав в списке по умолчанию
+	maxChapterPageSize     = 500
+)
+
+// ChapterService предоставляет методы для работы с главами.
+// Изменять главы могут участники команды, которой принадлежит манга, с правом загрузки и администраторы.
 type ChapterService struct {
-	repo       repository.ChapterRepository
-	mangaRepo  repository.MangaRepository
-	logger     *slog.Logger
-	imagesPath string
+	repo         repository.ChapterRepository
+	mangaRepo    repository.MangaRepository
+	teamRepo     repository.TeamRepository
+	audit        *AuditService
+	logger       *slog.Logger
+	storage      storage.Storage
+	imageOptions utils.ProcessImageOptions
+	renditions   map[string]utils.Rendition
 }
 
 // NewChapterService создает новый экземпляр ChapterService
 func NewChapterService(
 	repo repository.ChapterRepository,
 	mangaRepo repository.MangaRepository,
+	teamRepo repository.TeamRepository,
+	audit *AuditService,
 	logger *slog.Logger,
-	imagesPath string,
+	storage storage.Storage,
+	imageOptions utils.ProcessImageOptions,
 ) *ChapterService {
+	// Полный вариант ограничиваем теми же размерами, что и загружаемые страницы
+	renditions := utils.DefaultRenditions()
+	full := renditions[utils.RenditionFull]
+	full.MaxWidth, full.MaxHeight = imageOptions.MaxWidth, imageOptions.MaxHeight
+	renditions[utils.RenditionFull] = full
+
 	return &ChapterService{
-		repo:       repo,
-		mangaRepo:  mangaRepo,
-		logger:     logger,
-		imagesPath: imagesPath,
+		repo:         repo,
+		mangaRepo:    mangaRepo,
+		teamRepo:     teamRepo,
+		audit:        audit,
+		logger:       logger,
+		storage:      storage,
+		imageOptions: imageOptions,
+		renditions:   renditions,
 	}
 }
 
-// GetByMangaID возвращает список глав для указанной манги
-func (s *ChapterService) GetByMangaID(ctx context.Context, mangaID int) ([]domain.Chapter, error) {
-	s.logger.Debug("getting chapters by manga id", "manga_id", mangaID)
+// GetByMangaID возвращает страницу списка глав манги по возрастанию номера
+func (s *ChapterService) GetByMangaID(ctx context.Context, mangaID int, cursor string, limit int) (domain.ChapterPage, error) {
+	s.logger.DebugContext(ctx, "getting chapters by manga id", "manga_id", mangaID, "cursor", cursor)
+
+	after, err := decodePageCursor(cursor, cursorChapters, false)
+	if err != nil {
+		return domain.ChapterPage{}, err
+	}
+
+	if limit <= 0 || limit > maxChapterPageSize {
+		limit = defaultChapterPageSize
+	}
 
 	// Проверяем, существует ли манга
-	_, err := s.mangaRepo.GetByID(ctx, mangaID)
+	_, err = s.mangaRepo.GetByID(ctx, mangaID)
 	if err != nil {
-		s.logger.Error("manga not found", "manga_id", mangaID, "error", err)
-		return nil, fmt.Errorf("manga with id %d not found: %w", mangaID, err)
+		s.logger.ErrorContext(ctx, "manga not found", "manga_id", mangaID, "error", err)
+		return domain.ChapterPage{}, fmt.Errorf("manga with id %d not found: %w", mangaID, err)
 	}
 
-	chapters, err := s.repo.GetByMangaID(ctx, mangaID)
+	// Запрашиваем на одну главу больше, чтобы узнать, есть ли следующая страница
+	chapters, err := s.repo.GetByMangaID(ctx, mangaID, after, limit+1)
 	if err != nil {
-		s.logger.Error("failed to get chapters", "manga_id", mangaID, "error", err)
-		return nil, err
+		s.logger.ErrorContext(ctx, "failed to get chapters", "manga_id", mangaID, "error", err)
+		return domain.ChapterPage{}, err
+	}
+
+	page := domain.ChapterPage{Chapters: chapters}
+	if len(chapters) > limit {
+		last := chapters[limit-1]
+		page.Chapters = chapters[:limit]
+		page.NextCursor = encodePageCursor(domain.PageCursor{Sort: cursorChapters, ID: last.ID, Number: last.Number})
+	}
+	if page.Chapters == nil {
+		page.Chapters = []domain.Chapter{}
 	}
 
-	return chapters, nil
+	return page, nil
 }
 
 // GetByID возвращает главу по ID
 func (s *ChapterService) GetByID(ctx context.Context, id int) (domain.Chapter, error) {
-	s.logger.Debug("getting chapter by id", "id", id)
+	s.logger.DebugContext(ctx, "getting chapter by id", "id", id)
 
 	chapter, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get chapter", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to get chapter", "id", id, "error", err)
 		return domain.Chapter{}, err
 	}
 
@@ -69,8 +120,8 @@ func (s *ChapterService) GetByID(ctx context.Context, id int) (domain.Chapter, e
 }
 
 // Create создает новую главу
-func (s *ChapterService) Create(ctx context.Context, chapter domain.Chapter) (int, error) {
-	s.logger.Info("creating new chapter", "manga_id", chapter.MangaID, "number", chapter.Number)
+func (s *ChapterService) Create(ctx context.Context, userID int, admin bool, chapter domain.Chapter) (int, error) {
+	s.logger.InfoContext(ctx, "creating new chapter", "manga_id", chapter.MangaID, "number", chapter.Number, "user_id", userID)
 
 	if chapter.MangaID == 0 {
 		return 0, errors.New("manga id is required")
@@ -84,33 +135,27 @@ func (s *ChapterService) Create(ctx context.Context, chapter domain.Chapter) (in
 		return 0, errors.New("chapter title is required")
 	}
 
-	// Проверяем, существует ли манга
-	_, err := s.mangaRepo.GetByID(ctx, chapter.MangaID)
-	if err != nil {
-		s.logger.Error("manga not found", "manga_id", chapter.MangaID, "error", err)
-		return 0, fmt.Errorf("manga with id %d not found: %w", chapter.MangaID, err)
-	}
-
-	// Создаем каталог для изображений главы
-	err = s.createChapterImageDir(chapter.MangaID, chapter.Number)
-	if err != nil {
-		s.logger.Error("failed to create chapter image directory", "error", err)
-		return 0, fmt.Errorf("failed to create chapter image directory: %w", err)
+	// Проверяем, существует ли манга, и права пользователя на нее
+	if err := s.checkUploadPermission(ctx, userID, admin, chapter.MangaID); err != nil {
+		return 0, err
 	}
 
 	id, err := s.repo.Create(ctx, chapter)
 	if err != nil {
-		s.logger.Error("failed to create chapter", "error", err)
+		s.logger.ErrorContext(ctx, "failed to create chapter", "error", err)
 		return 0, err
 	}
 
-	s.logger.Info("chapter created successfully", "id", id)
+	chapter.ID = id
+	s.audit.Record(ctx, AuditActionCreate, domain.AuditEntityChapter, id, nil, chapter)
+
+	s.logger.InfoContext(ctx, "chapter created successfully", "id", id)
 	return id, nil
 }
 
 // Update обновляет информацию о главе
-func (s *ChapterService) Update(ctx context.Context, chapter domain.Chapter) error {
-	s.logger.Info("updating chapter", "id", chapter.ID)
+func (s *ChapterService) Update(ctx context.Context, userID int, admin bool, chapter domain.Chapter) error {
+	s.logger.InfoContext(ctx, "updating chapter", "id", chapter.ID, "user_id", userID)
 
 	if chapter.ID == 0 {
 		return errors.New("chapter id is required")
@@ -119,81 +164,233 @@ func (s *ChapterService) Update(ctx context.Context, chapter domain.Chapter) err
 	// Проверяем, существует ли глава
 	existingChapter, err := s.repo.GetByID(ctx, chapter.ID)
 	if err != nil {
-		s.logger.Error("chapter not found", "id", chapter.ID, "error", err)
+		s.logger.ErrorContext(ctx, "chapter not found", "id", chapter.ID, "error", err)
+		return err
+	}
+
+	if err := s.checkUploadPermission(ctx, userID, admin, existingChapter.MangaID); err != nil {
 		return err
 	}
 
 	// Если номер главы изменился, обновляем каталог для изображений
 	if existingChapter.Number != chapter.Number {
-		err = s.updateChapterImageDir(existingChapter.MangaID, existingChapter.Number, chapter.Number)
+		err = s.updateChapterImageDir(ctx, existingChapter.MangaID, existingChapter.Number, chapter.Number)
 		if err != nil {
-			s.logger.Error("failed to update chapter image directory", "error", err)
+			s.logger.ErrorContext(ctx, "failed to update chapter image directory", "error", err)
 			return fmt.Errorf("failed to update chapter image directory: %w", err)
 		}
 	}
 
 	err = s.repo.Update(ctx, chapter)
 	if err != nil {
-		s.logger.Error("failed to update chapter", "id", chapter.ID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to update chapter", "id", chapter.ID, "error", err)
 		return err
 	}
 
-	s.logger.Info("chapter updated successfully", "id", chapter.ID)
+	s.audit.Record(ctx, AuditActionUpdate, domain.AuditEntityChapter, chapter.ID, existingChapter, chapter)
+
+	s.logger.InfoContext(ctx, "chapter updated successfully", "id", chapter.ID)
 	return nil
 }
 
 // Delete удаляет главу по ID
-func (s *ChapterService) Delete(ctx context.Context, id int) error {
-	s.logger.Info("deleting chapter", "id", id)
+func (s *ChapterService) Delete(ctx context.Context, userID int, admin bool, id int) error {
+	s.logger.InfoContext(ctx, "deleting chapter", "id", id, "user_id", userID)
 
 	// Получаем информацию о главе перед удалением
 	chapter, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("chapter not found", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "chapter not found", "id", id, "error", err)
+		return err
+	}
+
+	if err := s.checkUploadPermission(ctx, userID, admin, chapter.MangaID); err != nil {
 		return err
 	}
 
 	// Удаляем главу из БД
 	err = s.repo.Delete(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to delete chapter", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to delete chapter", "id", id, "error", err)
 		return err
 	}
 
+	s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityChapter, id, chapter, nil)
+
 	// Удаляем каталог с изображениями главы
-	err = s.deleteChapterImageDir(chapter.MangaID, chapter.Number)
+	err = s.deleteChapterImageDir(ctx, chapter.MangaID, chapter.Number)
 	if err != nil {
-		s.logger.Warn("failed to delete chapter image directory", "id", id, "error", err)
+		s.logger.WarnContext(ctx, "failed to delete chapter image directory", "id", id, "error", err)
 		// Не возвращаем ошибку, так как глава уже удалена из БД
 	}
 
-	s.logger.Info("chapter deleted successfully", "id", id)
+	s.logger.InfoContext(ctx, "chapter deleted successfully", "id", id)
 	return nil
 }
 
 // GetPages возвращает список страниц для указанной главы
 func (s *ChapterService) GetPages(ctx context.Context, chapterID int) ([]domain.Page, error) {
-	s.logger.Debug("getting pages for chapter", "chapter_id", chapterID)
+	s.logger.DebugContext(ctx, "getting pages for chapter", "chapter_id", chapterID)
 
 	// Проверяем, существует ли глава
-	_, err := s.repo.GetByID(ctx, chapterID)
+	chapter, err := s.repo.GetByID(ctx, chapterID)
 	if err != nil {
-		s.logger.Error("chapter not found", "chapter_id", chapterID, "error", err)
+		s.logger.ErrorContext(ctx, "chapter not found", "chapter_id", chapterID, "error", err)
 		return nil, err
 	}
 
 	pages, err := s.repo.GetPages(ctx, chapterID)
 	if err != nil {
-		s.logger.Error("failed to get pages", "chapter_id", chapterID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to get pages", "chapter_id", chapterID, "error", err)
+		return nil, err
+	}
+
+	// Если водяной знак накладывается при генерации вариантов, исходное изображение без него
+	// не должно быть доступно: вместо адреса в хранилище отдается адрес изображения с водяным знаком
+	watermark, _, err := s.pageWatermark(ctx, chapter.MangaID, WatermarkApplyOnRendition)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", chapter.MangaID, "error", err)
 		return nil, err
 	}
 
+	// В базе хранится ключ изображения в хранилище, клиенту отдается его публичный адрес
+	for i := range pages {
+		if watermark != nil {
+			pages[i].ImageURL = fmt.Sprintf("/api/pages/%d/image", pages[i].ID)
+		} else {
+			pages[i].ImageURL = s.storage.URL(pages[i].ImageURL)
+		}
+		pages[i].Renditions = s.renditionURLs(pages[i].ID)
+	}
+
 	return pages, nil
 }
 
+// GetPageImage возвращает изображение страницы в указанном варианте и формате.
+// Варианты генерируются при первом запросе и кэшируются в хранилище.
+// Пустой variant означает исходное изображение, пустой format - формат исходного изображения.
+func (s *ChapterService) GetPageImage(ctx context.Context, pageID int, variant, format string) (domain.PageImage, error) {
+	s.logger.DebugContext(ctx, "getting page image", "page_id", pageID, "variant", variant, "format", format)
+
+	if format != "" && !utils.IsSupportedFormat(format) {
+		return domain.PageImage{}, fmt.Errorf("invalid format: %s", format)
+	}
+
+	var rendition utils.Rendition
+	if variant != "" {
+		var ok bool
+		rendition, ok = s.renditions[variant]
+		if !ok {
+			return domain.PageImage{}, fmt.Errorf("invalid variant: %s", variant)
+		}
+	}
+
+	page, err := s.repo.GetPage(ctx, pageID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "page not found", "page_id", pageID, "error", err)
+		return domain.PageImage{}, err
+	}
+
+	chapter, err := s.repo.GetByID(ctx, page.ChapterID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "chapter not found", "chapter_id", page.ChapterID, "error", err)
+		return domain.PageImage{}, err
+	}
+
+	// Водяной знак, применяемый при генерации вариантов
+	watermark, fingerprint, err := s.pageWatermark(ctx, chapter.MangaID, WatermarkApplyOnRendition)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", chapter.MangaID, "error", err)
+		return domain.PageImage{}, err
+	}
+
+	original, err := s.storage.Get(ctx, page.ImageURL)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get page image", "page_id", pageID, "key", page.ImageURL, "error", err)
+		return domain.PageImage{}, fmt.Errorf("failed to get page image: %w", err)
+	}
+
+	originalFormat := utils.DetectImageFormat(original)
+	if format == "" {
+		format = originalFormat
+	}
+
+	// Исходное изображение в исходном формате без водяного знака отдаем без обработки
+	if variant == "" && format == originalFormat && watermark == nil {
+		return newPageImage(original, format), nil
+	}
+
+	if variant == "" {
+		rendition = utils.Rendition{Name: "original", Quality: s.imageOptions.Quality}
+	}
+
+	// Отпечаток настроек водяного знака в ключе сбрасывает кэш при их изменении
+	name := rendition.Name
+	if watermark != nil {
+		name += "_w" + fingerprint
+	}
+	key := renditionKey(page.ImageURL, name, format)
+
+	// Пробуем получить вариант из кэша
+	data, err := s.storage.Get(ctx, key)
+	if err == nil {
+		return newPageImage(data, format), nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		s.logger.WarnContext(ctx, "failed to get cached rendition", "key", key, "error", err)
+	}
+
+	// Генерируем вариант
+	options := rendition.Options(format)
+	options.Watermark = watermark
+
+	data, format, err = utils.ProcessImage(original, options)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to generate rendition", "page_id", pageID, "variant", rendition.Name, "error", err)
+		return domain.PageImage{}, fmt.Errorf("failed to generate rendition: %w", err)
+	}
+
+	if err := s.storage.Put(ctx, key, data, utils.FormatContentType(format)); err != nil {
+		// Не возвращаем ошибку: вариант будет сгенерирован повторно при следующем запросе
+		s.logger.WarnContext(ctx, "failed to cache rendition", "key", key, "error", err)
+	}
+
+	return newPageImage(data, format), nil
+}
+
+// GetStoredImage возвращает изображение из хранилища по ключу для раздачи по адресу /images/{key}.
+// Изображения манги с водяным знаком, накладываемым при генерации вариантов, так не отдаются:
+// они доступны только через GetPageImage, иначе водяной знак можно обойти.
+func (s *ChapterService) GetStoredImage(ctx context.Context, key string) (domain.PageImage, error) {
+	// Проверяем нормализованный ключ, по которому объект будет прочитан из хранилища
+	key, err := storage.CleanKey(key)
+	if err != nil {
+		return domain.PageImage{}, storage.ErrNotFound
+	}
+
+	var mangaID int
+	if _, err := fmt.Sscanf(key, "manga_%d/", &mangaID); err == nil {
+		watermark, _, err := s.pageWatermark(ctx, mangaID, WatermarkApplyOnRendition)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", mangaID, "error", err)
+			return domain.PageImage{}, err
+		}
+		if watermark != nil {
+			return domain.PageImage{}, storage.ErrNotFound
+		}
+	}
+
+	data, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return domain.PageImage{}, err
+	}
+
+	return domain.PageImage{Data: data, ContentType: storage.ContentType(key, data)}, nil
+}
+
 // AddPage добавляет новую страницу в главу
-func (s *ChapterService) AddPage(ctx context.Context, page domain.Page, imageData []byte) (int, error) {
-	s.logger.Info("adding page to chapter", "chapter_id", page.ChapterID, "number", page.Number)
+func (s *ChapterService) AddPage(ctx context.Context, userID int, admin bool, page domain.Page, imageData []byte) (int, error) {
+	s.logger.InfoContext(ctx, "adding page to chapter", "chapter_id", page.ChapterID, "number", page.Number, "user_id", userID)
 
 	if page.ChapterID == 0 {
 		return 0, errors.New("chapter id is required")
@@ -202,7 +399,11 @@ func (s *ChapterService) AddPage(ctx context.Context, page domain.Page, imageDat
 	// Проверяем, существует ли глава
 	chapter, err := s.repo.GetByID(ctx, page.ChapterID)
 	if err != nil {
-		s.logger.Error("chapter not found", "chapter_id", page.ChapterID, "error", err)
+		s.logger.ErrorContext(ctx, "chapter not found", "chapter_id", page.ChapterID, "error", err)
+		return 0, err
+	}
+
+	if err := s.checkUploadPermission(ctx, userID, admin, chapter.MangaID); err != nil {
 		return 0, err
 	}
 
@@ -211,16 +412,30 @@ func (s *ChapterService) AddPage(ctx context.Context, page domain.Page, imageDat
 		// Получаем существующие страницы
 		pages, err := s.repo.GetPages(ctx, page.ChapterID)
 		if err != nil {
-			s.logger.Error("failed to get pages", "chapter_id", page.ChapterID, "error", err)
+			s.logger.ErrorContext(ctx, "failed to get pages", "chapter_id", page.ChapterID, "error", err)
 			return 0, err
 		}
 		page.Number = len(pages) + 1
 	}
 
+	// Водяной знак, применяемый при загрузке
+	watermark, _, err := s.pageWatermark(ctx, chapter.MangaID, WatermarkApplyOnUpload)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", chapter.MangaID, "error", err)
+		return 0, err
+	}
+
+	// Проверяем и нормализуем изображение
+	processed, format, err := s.processPageImage(imageData, watermark)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to process page image", "error", err)
+		return 0, err
+	}
+
 	// Сохраняем изображение
-	imagePath, err := s.savePageImage(chapter.MangaID, chapter.Number, page.Number, imageData)
+	imagePath, err := s.savePageImage(ctx, chapter.MangaID, chapter.Number, page.Number, processed, format)
 	if err != nil {
-		s.logger.Error("failed to save page image", "error", err)
+		s.logger.ErrorContext(ctx, "failed to save page image", "error", err)
 		return 0, fmt.Errorf("failed to save page image: %w", err)
 	}
 
@@ -229,115 +444,336 @@ func (s *ChapterService) AddPage(ctx context.Context, page domain.Page, imageDat
 
 	id, err := s.repo.AddPage(ctx, page)
 	if err != nil {
-		s.logger.Error("failed to add page", "error", err)
+		s.logger.ErrorContext(ctx, "failed to add page", "error", err)
 		// Удаляем изображение, если не удалось добавить страницу
-		_ = os.Remove(filepath.Join(s.imagesPath, imagePath))
+		_ = s.storage.Delete(ctx, imagePath)
 		return 0, err
 	}
 
-	s.logger.Info("page added successfully", "id", id, "chapter_id", page.ChapterID)
+	page.ID = id
+	s.audit.Record(ctx, AuditActionCreate, domain.AuditEntityPage, id, nil, page)
+
+	s.logger.InfoContext(ctx, "page added successfully", "id", id, "chapter_id", page.ChapterID)
 	return id, nil
 }
 
-// DeletePage удаляет страницу по ID
-func (s *ChapterService) DeletePage(ctx context.Context, id int) error {
-	s.logger.Info("deleting page", "id", id)
+// AddPagesFromArchive добавляет в главу все страницы из CBZ/ZIP архива.
+// Страницы добавляются в конец главы в естественном порядке имен файлов.
+// При ошибке глава и каталог ее изображений остаются в исходном состоянии.
+func (s *ChapterService) AddPagesFromArchive(ctx context.Context, userID int, admin bool, chapterID int, archiveData []byte) ([]int, error) {
+	s.logger.InfoContext(ctx, "adding pages from archive", "chapter_id", chapterID, "size", len(archiveData), "user_id", userID)
 
-	// Получаем информацию о странице перед удалением
-	pages, err := s.repo.GetPages(ctx, 0) // 0 - заглушка, так как GetPages не использует chapterID
+	if chapterID == 0 {
+		return nil, errors.New("chapter id is required")
+	}
+
+	// Проверяем, существует ли глава
+	chapter, err := s.repo.GetByID(ctx, chapterID)
 	if err != nil {
-		s.logger.Error("failed to get pages", "error", err)
-		return err
+		s.logger.ErrorContext(ctx, "chapter not found", "chapter_id", chapterID, "error", err)
+		return nil, err
+	}
+
+	if err := s.checkUploadPermission(ctx, userID, admin, chapter.MangaID); err != nil {
+		return nil, err
+	}
+
+	// Архив распаковывается в память целиком, поэтому количество и размер файлов ограничены
+	entries, err := utils.ReadImageArchive(archiveData, utils.ArchiveLimits{
+		MaxEntries:   maxArchiveEntries,
+		MaxEntrySize: maxPageImageSize,
+		MaxTotalSize: maxArchiveSize,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to read archive", "chapter_id", chapterID, "error", err)
+		return nil, err
 	}
 
-	var targetPage domain.Page
-	for _, p := range pages {
-		if p.ID == id {
-			targetPage = p
-			break
+	// Водяной знак, применяемый при загрузке
+	watermark, _, err := s.pageWatermark(ctx, chapter.MangaID, WatermarkApplyOnUpload)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", chapter.MangaID, "error", err)
+		return nil, err
+	}
+
+	// Проверяем и обрабатываем все изображения до того, как что-либо записать
+	images := make([]processedImage, 0, len(entries))
+	for _, entry := range entries {
+		data, format, err := s.processPageImage(entry.Data, watermark)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "invalid image in archive", "chapter_id", chapterID, "entry", entry.Name, "error", err)
+			return nil, fmt.Errorf("invalid archive: entry %s: %w", entry.Name, err)
 		}
+		images = append(images, processedImage{name: entry.Name, data: data, format: format})
 	}
 
-	if targetPage.ID == 0 {
-		return fmt.Errorf("page with id %d not found", id)
+	var pages []domain.Page
+	backups := make([]pageImageBackup, 0, len(images))
+
+	// rollback возвращает каталог главы в исходное состояние
+	rollback := func() {
+		for i := len(backups) - 1; i >= 0; i-- {
+			if err := backups[i].restore(ctx, s.storage); err != nil {
+				s.logger.WarnContext(ctx, "failed to restore page image", "key", backups[i].key, "error", err)
+			}
+		}
 	}
 
-	// Получаем информацию о главе
+	// Изображения записываются под блокировкой главы: номера страниц и ключи изображений
+	// не пересекаются с параллельной загрузкой в ту же главу
+	ids, err := s.repo.AddPages(ctx, chapterID, func(firstNumber int) ([]domain.Page, error) {
+		pages = make([]domain.Page, 0, len(images))
+
+		for i, img := range images {
+			number := firstNumber + i
+
+			backup, err := s.backupPageImage(ctx, chapter.MangaID, chapter.Number, number, img.format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to backup page image: %w", err)
+			}
+			backups = append(backups, backup)
+
+			imagePath, err := s.savePageImage(ctx, chapter.MangaID, chapter.Number, number, img.data, img.format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to save page image %s: %w", img.name, err)
+			}
+
+			pages = append(pages, domain.Page{
+				ChapterID: chapterID,
+				Number:    number,
+				ImageURL:  imagePath,
+			})
+		}
+
+		return pages, nil
+	})
+	if err != nil {
+		rollback()
+		s.logger.ErrorContext(ctx, "failed to add pages", "chapter_id", chapterID, "error", err)
+		return nil, err
+	}
+
+	for i := range pages {
+		pages[i].ID = ids[i]
+	}
+	s.audit.Record(ctx, AuditActionAddPages, domain.AuditEntityChapter, chapterID, nil, pages)
+
+	s.logger.InfoContext(ctx, "pages added from archive successfully", "chapter_id", chapterID, "count", len(ids))
+	return ids, nil
+}
+
+// DeletePage удаляет страницу по ID
+func (s *ChapterService) DeletePage(ctx context.Context, userID int, admin bool, id int) error {
+	s.logger.InfoContext(ctx, "deleting page", "id", id, "user_id", userID)
+
+	// Получаем информацию о странице перед удалением
+	targetPage, err := s.repo.GetPage(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "page not found", "id", id, "error", err)
+		return err
+	}
+
+	// Проверяем, существует ли глава
 	chapter, err := s.repo.GetByID(ctx, targetPage.ChapterID)
 	if err != nil {
-		s.logger.Error("failed to get chapter", "chapter_id", targetPage.ChapterID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to get chapter", "chapter_id", targetPage.ChapterID, "error", err)
+		return err
+	}
+
+	if err := s.checkUploadPermission(ctx, userID, admin, chapter.MangaID); err != nil {
 		return err
 	}
 
 	// Удаляем страницу из БД
 	err = s.repo.DeletePage(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to delete page", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to delete page", "id", id, "error", err)
 		return err
 	}
 
-	// Удаляем изображение
-	err = s.deletePageImage(chapter.MangaID, chapter.Number, targetPage.Number)
+	s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityPage, id, targetPage, nil)
+
+	// Удаляем изображение и его варианты
+	err = s.storage.Delete(ctx, targetPage.ImageURL)
 	if err != nil {
-		s.logger.Warn("failed to delete page image", "id", id, "error", err)
+		s.logger.WarnContext(ctx, "failed to delete page image", "id", id, "error", err)
 		// Не возвращаем ошибку, так как страница уже удалена из БД
 	}
+	s.deleteRenditions(ctx, targetPage.ImageURL)
 
-	s.logger.Info("page deleted successfully", "id", id)
+	s.logger.InfoContext(ctx, "page deleted successfully", "id", id)
 	return nil
 }
 
-// createChapterImageDir создает каталог для изображений главы
-func (s *ChapterService) createChapterImageDir(mangaID int, chapterNumber float64) error {
-	dirPath := filepath.Join(s.imagesPath, fmt.Sprintf("manga_%d/chapter_%.2f", mangaID, chapterNumber))
-	return os.MkdirAll(dirPath, 0755)
+// checkUploadPermission проверяет, что манга существует и пользователь может изменять ее главы
+func (s *ChapterService) checkUploadPermission(ctx context.Context, userID int, admin bool, mangaID int) error {
+	manga, err := s.mangaRepo.GetByID(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "manga not found", "manga_id", mangaID, "error", err)
+		return fmt.Errorf("manga with id %d not found: %w", mangaID, err)
+	}
+
+	return checkTeamPermission(ctx, s.teamRepo, userID, admin, manga.TeamID, domain.PermissionUpload)
 }
 
-// updateChapterImageDir обновляет каталог для изображений главы при изменении номера главы
-func (s *ChapterService) updateChapterImageDir(mangaID int, oldNumber, newNumber float64) error {
-	oldPath := filepath.Join(s.imagesPath, fmt.Sprintf("manga_%d/chapter_%.2f", mangaID, oldNumber))
-	newPath := filepath.Join(s.imagesPath, fmt.Sprintf("manga_%d/chapter_%.2f", mangaID, newNumber))
+// chapterImagePrefix возвращает префикс ключей изображений главы
+func chapterImagePrefix(mangaID int, chapterNumber float64) string {
+	return fmt.Sprintf("manga_%d/chapter_%.2f/", mangaID, chapterNumber)
+}
 
-	// Проверяем, существует ли старый каталог
-	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
-		// Если старого каталога нет, просто создаем новый
-		return os.MkdirAll(newPath, 0755)
-	}
+// pageImageKey возвращает ключ изображения страницы в хранилище
+func pageImageKey(mangaID int, chapterNumber float64, pageNumber int, format string) string {
+	return fmt.Sprintf("manga_%d/chapter_%.2f/page_%03d%s", mangaID, chapterNumber, pageNumber, utils.FormatExtension(format))
+}
 
-	// Переименовываем каталог
-	return os.Rename(oldPath, newPath)
+// processedImage изображение страницы после обработки
+type processedImage struct {
+	name   string
+	data   []byte
+	format string
 }
 
-// deleteChapterImageDir удаляет каталог с изображениями главы
-func (s *ChapterService) deleteChapterImageDir(mangaID int, chapterNumber float64) error {
-	dirPath := filepath.Join(s.imagesPath, fmt.Sprintf("manga_%d/chapter_%.2f", mangaID, chapterNumber))
-	return os.RemoveAll(dirPath)
+// processPageImage проверяет изображение и приводит его к настроенным размерам и формату.
+// Если watermark не nil, на изображение накладывается водяной знак.
+func (s *ChapterService) processPageImage(imageData []byte, watermark *utils.WatermarkOptions) ([]byte, string, error) {
+	info, err := utils.GetImageInfo(imageData)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %w", err)
+	}
+
+	// Небольшой файл может содержать изображение огромного размера, которое не поместится в память при декодировании
+	if info.Width*info.Height > maxPageImagePixels {
+		return nil, "", fmt.Errorf("invalid image: %dx%d exceeds %d pixels", info.Width, info.Height, maxPageImagePixels)
+	}
+
+	options := s.imageOptions
+	options.Watermark = watermark
+
+	processed, format, err := utils.ProcessImage(imageData, options)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %w", err)
+	}
+
+	return processed, format, nil
 }
 
-// savePageImage сохраняет изображение страницы и возвращает относительный путь
-func (s *ChapterService) savePageImage(mangaID int, chapterNumber float64, pageNumber int, imageData []byte) (string, error) {
-	// Формируем относительный путь к изображению
-	relPath := fmt.Sprintf("manga_%d/chapter_%.2f/page_%03d.jpg", mangaID, chapterNumber, pageNumber)
-	absPath := filepath.Join(s.imagesPath, relPath)
+// pageWatermark возвращает водяной знак манги, если он включен и применяется на этапе applyOn.
+// Вторым значением возвращается отпечаток настроек для ключей кэша вариантов.
+func (s *ChapterService) pageWatermark(ctx context.Context, mangaID int, applyOn string) (*utils.WatermarkOptions, string, error) {
+	watermark, err := s.mangaRepo.GetWatermark(ctx, mangaID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get watermark: %w", err)
+	}
+	if watermark == nil || !watermark.Enabled || watermark.ApplyOn != applyOn {
+		return nil, "", nil
+	}
 
-	// Создаем каталог, если он не существует
-	err := os.MkdirAll(filepath.Dir(absPath), 0755)
+	options, err := watermarkOptions(*watermark)
 	if err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+		return nil, "", err
 	}
 
-	// Сохраняем изображение
-	err = os.WriteFile(absPath, imageData, 0644)
+	return options, watermarkFingerprint(*watermark), nil
+}
+
+// watermarkFingerprint возвращает отпечаток настроек водяного знака. Он вычисляется по содержимому
+// настроек, поэтому любое изменение, даже в пределах одной секунды, дает новый отпечаток.
+func watermarkFingerprint(watermark domain.Watermark) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%g\x00%g\x00", watermark.Text, watermark.Position, watermark.Opacity, watermark.Scale)
+	hash.Write(watermark.Logo)
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// updateChapterImageDir переносит изображения главы при изменении номера главы
+func (s *ChapterService) updateChapterImageDir(ctx context.Context, mangaID int, oldNumber, newNumber float64) error {
+	return storage.MovePrefix(ctx, s.storage,
+		chapterImagePrefix(mangaID, oldNumber),
+		chapterImagePrefix(mangaID, newNumber))
+}
+
+// deleteChapterImageDir удаляет изображения главы
+func (s *ChapterService) deleteChapterImageDir(ctx context.Context, mangaID int, chapterNumber float64) error {
+	return storage.DeletePrefix(ctx, s.storage, chapterImagePrefix(mangaID, chapterNumber))
+}
+
+// savePageImage сохраняет изображение страницы и возвращает его ключ в хранилище
+func (s *ChapterService) savePageImage(ctx context.Context, mangaID int, chapterNumber float64, pageNumber int, imageData []byte, format string) (string, error) {
+	key := pageImageKey(mangaID, chapterNumber, pageNumber, format)
+
+	err := s.storage.Put(ctx, key, imageData, utils.FormatContentType(format))
 	if err != nil {
-		return "", fmt.Errorf("failed to write image file: %w", err)
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+
+	// Варианты перезаписанного изображения больше не актуальны
+	s.deleteRenditions(ctx, key)
+
+	return key, nil
+}
+
+// renditionKey возвращает ключ закэшированного варианта изображения
+func renditionKey(imageKey, variant, format string) string {
+	dir, file := path.Split(imageKey)
+	base := strings.TrimSuffix(file, path.Ext(file))
+	return fmt.Sprintf("%srenditions/%s_%s%s", dir, base, variant, utils.FormatExtension(format))
+}
+
+// renditionURLs возвращает адреса вариантов изображения страницы
+func (s *ChapterService) renditionURLs(pageID int) map[string]string {
+	urls := make(map[string]string, len(s.renditions))
+	for name := range s.renditions {
+		urls[name] = fmt.Sprintf("/api/pages/%d/image?variant=%s", pageID, name)
+	}
+	return urls
+}
+
+// deleteRenditions удаляет закэшированные варианты изображения
+func (s *ChapterService) deleteRenditions(ctx context.Context, imageKey string) {
+	dir, file := path.Split(imageKey)
+	prefix := dir + "renditions/" + strings.TrimSuffix(file, path.Ext(file)) + "_"
+
+	if err := storage.DeletePrefix(ctx, s.storage, prefix); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete renditions", "key", imageKey, "error", err)
 	}
+}
+
+// newPageImage формирует ответ с изображением и его ETag
+func newPageImage(data []byte, format string) domain.PageImage {
+	sum := sha256.Sum256(data)
+	return domain.PageImage{
+		Data:        data,
+		ContentType: utils.FormatContentType(format),
+		ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// pageImageBackup хранит состояние изображения страницы до перезаписи
+type pageImageBackup struct {
+	key     string
+	data    []byte
+	existed bool
+}
 
-	return relPath, nil
+// restore возвращает изображение страницы в сохраненное состояние
+func (b pageImageBackup) restore(ctx context.Context, st storage.Storage) error {
+	if !b.existed {
+		return st.Delete(ctx, b.key)
+	}
+	return st.Put(ctx, b.key, b.data, storage.ContentType(b.key, b.data))
 }
 
-// deletePageImage удаляет изображение страницы
-func (s *ChapterService) deletePageImage(mangaID int, chapterNumber float64, pageNumber int) error {
-	relPath := fmt.Sprintf("manga_%d/chapter_%.2f/page_%03d.jpg", mangaID, chapterNumber, pageNumber)
-	absPath := filepath.Join(s.imagesPath, relPath)
-	return os.Remove(absPath)
+// backupPageImage запоминает текущее содержимое изображения страницы, если оно существует
+func (s *ChapterService) backupPageImage(ctx context.Context, mangaID int, chapterNumber float64, pageNumber int, format string) (pageImageBackup, error) {
+	key := pageImageKey(mangaID, chapterNumber, pageNumber, format)
+
+	data, err := s.storage.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return pageImageBackup{key: key}, nil
+		}
+		return pageImageBackup{}, err
+	}
+
+	return pageImageBackup{key: key, data: data, existed: true}, nil
 }
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+)
+
+// Ограничения для комментариев
+const (
+	maxCommentLength       = 5000 // Максимальная длина комментария в символах
+	defaultCommentPageSize = 20
+	maxCommentPageSize     = 100
+)
+
+// CommentService предоставляет методы для работы с комментариями
+type CommentService struct {
+	repo        repository.CommentRepository
+	mangaRepo   repository.MangaRepository
+	chapterRepo repository.ChapterRepository
+	audit       *AuditService
+	logger      *slog.Logger
+	editWindow  time.Duration
+}
+
+// NewCommentService создает новый экземпляр CommentService.
+// editWindow - время после публикации, в течение которого автор может редактировать комментарий.
+func NewCommentService(
+	repo repository.CommentRepository,
+	mangaRepo repository.MangaRepository,
+	chapterRepo repository.ChapterRepository,
+	audit *AuditService,
+	logger *slog.Logger,
+	editWindow time.Duration,
+) *CommentService {
+	return &CommentService{
+		repo:        repo,
+		mangaRepo:   mangaRepo,
+		chapterRepo: chapterRepo,
+		audit:       audit,
+		logger:      logger,
+		editWindow:  editWindow,
+	}
+}
+
+// MangaTarget возвращает ветку комментариев манги
+func (s *CommentService) MangaTarget(ctx context.Context, mangaID int) (domain.CommentTarget, error) {
+	if _, err := s.mangaRepo.GetByID(ctx, mangaID); err != nil {
+		return domain.CommentTarget{}, err
+	}
+	return domain.CommentTarget{MangaID: mangaID}, nil
+}
+
+// ChapterTarget возвращает ветку комментариев главы
+func (s *CommentService) ChapterTarget(ctx context.Context, chapterID int) (domain.CommentTarget, error) {
+	chapter, err := s.chapterRepo.GetByID(ctx, chapterID)
+	if err != nil {
+		return domain.CommentTarget{}, err
+	}
+	return domain.CommentTarget{MangaID: chapter.MangaID, ChapterID: &chapter.ID}, nil
+}
+
+// List возвращает страницу комментариев верхнего уровня ветки, начиная с самых новых.
+// Скрытые комментарии видны полностью только модераторам и их авторам.
+func (s *CommentService) List(ctx context.Context, target domain.CommentTarget, cursor string, limit, viewerID int, moderator bool) (domain.CommentPage, error) {
+	s.logger.DebugContext(ctx, "listing comments", "manga_id", target.MangaID, "chapter_id", target.ChapterID, "cursor", cursor)
+
+	filter := domain.CommentFilter{Target: target}
+	page, err := s.list(ctx, filter, cursor, limit, viewerID, moderator)
+	if err != nil {
+		return domain.CommentPage{}, err
+	}
+
+	page.Locked, err = s.repo.IsLocked(ctx, target)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check comment lock", "manga_id", target.MangaID, "error", err)
+		return domain.CommentPage{}, err
+	}
+
+	return page, nil
+}
+
+// ListReplies возвращает страницу ответов на комментарий в порядке публикации
+func (s *CommentService) ListReplies(ctx context.Context, commentID int, cursor string, limit, viewerID int, moderator bool) (domain.CommentPage, error) {
+	s.logger.DebugContext(ctx, "listing comment replies", "comment_id", commentID, "cursor", cursor)
+
+	parent, err := s.repo.GetByID(ctx, commentID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "comment not found", "id", commentID, "error", err)
+		return domain.CommentPage{}, err
+	}
+
+	target := domain.CommentTarget{MangaID: parent.MangaID, ChapterID: parent.ChapterID}
+	filter := domain.CommentFilter{Target: target, ParentID: &parent.ID}
+	page, err := s.list(ctx, filter, cursor, limit, viewerID, moderator)
+	if err != nil {
+		return domain.CommentPage{}, err
+	}
+
+	page.Locked, err = s.repo.IsLocked(ctx, target)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check comment lock", "manga_id", target.MangaID, "error", err)
+		return domain.CommentPage{}, err
+	}
+
+	return page, nil
+}
+
+// Create публикует комментарий или ответ в ветке
+func (s *CommentService) Create(ctx context.Context, target domain.CommentTarget, userID int, moderator bool, input domain.CommentInput) (domain.Comment, error) {
+	s.logger.InfoContext(ctx, "creating comment", "manga_id", target.MangaID, "chapter_id", target.ChapterID, "user_id", userID)
+
+	body, err := normalizeCommentBody(input.Body)
+	if err != nil {
+		return domain.Comment{}, err
+	}
+
+	if err := s.checkLock(ctx, target, moderator); err != nil {
+		return domain.Comment{}, err
+	}
+
+	// Ответ должен относиться к той же ветке, что и родительский комментарий
+	if input.ParentID != nil {
+		parent, err := s.repo.GetByID(ctx, *input.ParentID)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "parent comment not found", "parent_id", *input.ParentID, "error", err)
+			return domain.Comment{}, err
+		}
+		if parent.MangaID != target.MangaID || !sameChapter(parent.ChapterID, target.ChapterID) {
+			return domain.Comment{}, errors.New("invalid comment: parent comment belongs to another thread")
+		}
+		if parent.Deleted {
+			return domain.Comment{}, errors.New("invalid comment: cannot reply to a deleted comment")
+		}
+	}
+
+	id, err := s.repo.Create(ctx, domain.Comment{
+		MangaID:   target.MangaID,
+		ChapterID: target.ChapterID,
+		ParentID:  input.ParentID,
+		UserID:    userID,
+		Body:      body,
+		Spoiler:   input.Spoiler,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create comment", "error", err)
+		return domain.Comment{}, err
+	}
+
+	comment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get created comment", "id", id, "error", err)
+		return domain.Comment{}, err
+	}
+
+	s.logger.InfoContext(ctx, "comment created successfully", "id", id)
+	return comment, nil
+}
+
+// Update редактирует комментарий. Автор может редактировать комментарий только в течение editWindow.
+func (s *CommentService) Update(ctx context.Context, id, userID int, moderator bool, update domain.CommentUpdate) (domain.Comment, error) {
+	s.logger.InfoContext(ctx, "updating comment", "id", id, "user_id", userID)
+
+	body, err := normalizeCommentBody(update.Body)
+	if err != nil {
+		return domain.Comment{}, err
+	}
+
+	comment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "comment not found", "id", id, "error", err)
+		return domain.Comment{}, err
+	}
+
+	if comment.UserID != userID {
+		return domain.Comment{}, errors.New("permission denied: only the author can edit a comment")
+	}
+	if comment.Deleted {
+		return domain.Comment{}, fmt.Errorf("comment with id %d not found", id)
+	}
+	if comment.Hidden {
+		return domain.Comment{}, errors.New("permission denied: comment is hidden by a moderator")
+	}
+	if Now().Sub(comment.CreatedAt) > s.editWindow {
+		return domain.Comment{}, errors.New("permission denied: edit window has expired")
+	}
+
+	target := domain.CommentTarget{MangaID: comment.MangaID, ChapterID: comment.ChapterID}
+	if err := s.checkLock(ctx, target, moderator); err != nil {
+		return domain.Comment{}, err
+	}
+
+	if err := s.repo.Update(ctx, id, body, update.Spoiler); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update comment", "id", id, "error", err)
+		return domain.Comment{}, err
+	}
+
+	comment, err = s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get updated comment", "id", id, "error", err)
+		return domain.Comment{}, err
+	}
+
+	s.logger.InfoContext(ctx, "comment updated successfully", "id", id)
+	return comment, nil
+}
+
+// Delete мягко удаляет комментарий. Удалить комментарий может автор или модератор.
+func (s *CommentService) Delete(ctx context.Context, id, userID int, moderator bool) error {
+	s.logger.InfoContext(ctx, "deleting comment", "id", id, "user_id", userID, "moderator", moderator)
+
+	comment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "comment not found", "id", id, "error", err)
+		return err
+	}
+
+	if comment.UserID != userID && !moderator {
+		return errors.New("permission denied: only the author or a moderator can delete a comment")
+	}
+
+	if err := s.repo.SoftDelete(ctx, id, userID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete comment", "id", id, "error", err)
+		return err
+	}
+
+	// В журнал аудита попадает только модерация: удаление чужого комментария
+	if comment.UserID != userID {
+		s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityComment, id, comment, nil)
+	}
+
+	s.logger.InfoContext(ctx, "comment deleted successfully", "id", id)
+	return nil
+}
+
+// SetHidden скрывает комментарий или возвращает его в ленту (только для модераторов)
+func (s *CommentService) SetHidden(ctx context.Context, id, moderatorID int, hidden bool) error {
+	s.logger.InfoContext(ctx, "changing comment visibility", "id", id, "moderator_id", moderatorID, "hidden", hidden)
+
+	comment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "comment not found", "id", id, "error", err)
+		return err
+	}
+
+	if err := s.repo.SetHidden(ctx, id, hidden, moderatorID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to change comment visibility", "id", id, "error", err)
+		return err
+	}
+
+	action := AuditActionHide
+	if !hidden {
+		action = AuditActionUnhide
+	}
+	updated := comment
+	updated.Hidden = hidden
+	s.audit.Record(ctx, action, domain.AuditEntityComment, id, comment, updated)
+
+	s.logger.InfoContext(ctx, "comment visibility changed successfully", "id", id, "hidden", hidden)
+	return nil
+}
+
+// SetLocked закрывает ветку для новых комментариев или открывает ее (только для модераторов)
+func (s *CommentService) SetLocked(ctx context.Context, target domain.CommentTarget, moderatorID int, locked bool) error {
+	s.logger.InfoContext(ctx, "changing comment thread lock",
+		"manga_id", target.MangaID,
+		"chapter_id", target.ChapterID,
+		"moderator_id", moderatorID,
+		"locked", locked)
+
+	var err error
+	if locked {
+		err = s.repo.Lock(ctx, target, moderatorID)
+	} else {
+		err = s.repo.Unlock(ctx, target)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to change comment thread lock", "manga_id", target.MangaID, "error", err)
+		return err
+	}
+
+	// Ветка принадлежит главе или манге, в журнал аудита она попадает как изменение этого объекта
+	action, entityType, entityID := AuditActionLockThread, domain.AuditEntityManga, target.MangaID
+	if !locked {
+		action = AuditActionUnlockThread
+	}
+	if target.ChapterID != nil {
+		entityType, entityID = domain.AuditEntityChapter, *target.ChapterID
+	}
+	s.audit.Record(ctx, action, entityType, entityID, nil, map[string]any{
+		"manga_id":        target.MangaID,
+		"chapter_id":      target.ChapterID,
+		"comments_locked": locked,
+	})
+
+	return nil
+}
+
+// list загружает страницу комментариев и скрывает содержимое удаленных и скрытых комментариев
+func (s *CommentService) list(ctx context.Context, filter domain.CommentFilter, cursor string, limit, viewerID int, moderator bool) (domain.CommentPage, error) {
+	afterID, err := decodeCommentCursor(cursor)
+	if err != nil {
+		return domain.CommentPage{}, err
+	}
+
+	if limit <= 0 || limit > maxCommentPageSize {
+		limit = defaultCommentPageSize
+	}
+
+	// Запрашиваем на один комментарий больше, чтобы узнать, есть ли следующая страница
+	filter.Cursor = afterID
+	filter.Limit = limit + 1
+
+	comments, err := s.repo.List(ctx, filter)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list comments", "error", err)
+		return domain.CommentPage{}, err
+	}
+
+	page := domain.CommentPage{Comments: make([]domain.Comment, 0, limit)}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		page.NextCursor = encodeCommentCursor(comments[limit-1].ID)
+	}
+
+	for _, comment := range comments {
+		page.Comments = append(page.Comments, presentComment(comment, viewerID, moderator))
+	}
+
+	return page, nil
+}
+
+// checkLock возвращает ошибку, если ветка закрыта (модераторы могут писать в закрытые ветки)
+func (s *CommentService) checkLock(ctx context.Context, target domain.CommentTarget, moderator bool) error {
+	if moderator {
+		return nil
+	}
+
+	locked, err := s.repo.IsLocked(ctx, target)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check comment lock", "manga_id", target.MangaID, "error", err)
+		return err
+	}
+	if locked {
+		return errors.New("permission denied: comment thread is locked")
+	}
+
+	return nil
+}
+
+// presentComment убирает содержимое удаленных комментариев и скрытых комментариев для посторонних
+func presentComment(comment domain.Comment, viewerID int, moderator bool) domain.Comment {
+	if comment.Deleted {
+		comment.Body = ""
+		comment.Spoiler = false
+		return comment
+	}
+	if comment.Hidden && !moderator && comment.UserID != viewerID {
+		comment.Body = ""
+	}
+	return comment
+}
+
+// normalizeCommentBody проверяет текст комментария
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errors.New("invalid comment: body is required")
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return "", fmt.Errorf("invalid comment: body is longer than %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// sameChapter сравнивает главы двух веток (nil - ветка манги)
+func sameChapter(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// encodeCommentCursor кодирует курсор страницы комментариев
+func encodeCommentCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCommentCursor декодирует курсор страницы комментариев; пустой курсор означает первую страницу
+func decodeCommentCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+
+	id, err := strconv.Atoi(string(data))
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return id, nil
+}
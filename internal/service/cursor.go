@@ -0,0 +1,46 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+)
+
+// Списки с курсорной пагинацией по ключу сортировки (значение PageCursor.Sort)
+const (
+	cursorChapters    = "chapters"
+	cursorBookmarks   = "bookmarks"
+	cursorReadHistory = "history"
+)
+
+// encodePageCursor кодирует позицию в списке в непрозрачный курсор
+func encodePageCursor(cursor domain.PageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageCursor декодирует курсор списка sort с направлением сортировки desc.
+// Пустой курсор означает первую страницу; курсор другого списка или сортировки считается невалидным.
+func decodePageCursor(cursor, sort string, desc bool) (*domain.PageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var position domain.PageCursor
+	if err := json.Unmarshal(data, &position); err != nil || position.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+
+	if position.Sort != sort || position.Desc != desc {
+		return nil, errors.New("invalid cursor: it was issued for a different sort order")
+	}
+
+	return &position, nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginThrottleConfig настройки защиты входа от подбора пароля
+type LoginThrottleConfig struct {
+	FreeAttempts  int           // Неудачные попытки без задержки
+	BaseDelay     time.Duration // Задержка после первой попытки сверх FreeAttempts, далее удваивается
+	MaxAttempts   int           // Неудачные попытки для одного имени пользователя до блокировки
+	IPMaxAttempts int           // Неудачные попытки с одного IP до блокировки
+	Lockout       time.Duration // Длительность блокировки, она же окно подсчета неудачных попыток
+}
+
+// LoginThrottledError возвращается, когда вход временно запрещен после неудачных попыток
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+// Error реализует интерфейс error
+func (e *LoginThrottledError) Error() string {
+	return "too many login attempts"
+}
+
+// Схема хранения в Redis:
+//
+//	login_fail:<scope>:<value>  -> число неудачных попыток за окно Lockout
+//	login_lock:<scope>:<value>  -> "1" при блокировке, "0" при задержке; вход запрещен, пока ключ существует
+//
+// scope - user (имя пользователя в нижнем регистре) или ip.
+const (
+	loginFailKeyFmt = "login_fail:%s:%s"
+	loginLockKeyFmt = "login_lock:%s:%s"
+)
+
+// loginFailScript учитывает неудачную попытку и при необходимости запрещает вход.
+// KEYS: счетчик, ключ запрета.
+// ARGV: окно в мс, попытки без задержки, базовая задержка в мс, попытки до блокировки, блокировка в мс.
+// Возвращает {попытки, задержка в мс, 1 если началась блокировка}.
+var loginFailScript = redis.NewScript(`
+local attempts = redis.call('INCR', KEYS[1])
+if attempts == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+
+local free = tonumber(ARGV[2])
+local lockout = tonumber(ARGV[5])
+if attempts >= tonumber(ARGV[4]) then
+	redis.call('DEL', KEYS[1])
+	redis.call('SET', KEYS[2], '1', 'PX', lockout)
+	return {attempts, lockout, 1}
+end
+
+local delay = 0
+if attempts > free then
+	delay = math.floor(math.min(tonumber(ARGV[3]) * 2 ^ (attempts - free - 1), lockout))
+end
+if delay > 0 then
+	redis.call('SET', KEYS[2], '0', 'PX', delay)
+end
+
+return {attempts, delay, 0}
+`)
+
+// loginLockout блокировка, начавшаяся после неудачной попытки
+type loginLockout struct {
+	scope    string
+	value    string
+	attempts int64
+	duration time.Duration
+}
+
+// loginThrottle ограничивает частоту неудачных попыток входа по имени пользователя и по IP
+type loginThrottle struct {
+	client *redis.Client
+	cfg    LoginThrottleConfig
+}
+
+// scopes возвращает ограничения, которые применяются к попытке входа
+func (t *loginThrottle) scopes(username, ip string) map[string]string {
+	scopes := map[string]string{"user": strings.ToLower(username)}
+	if ip != "" {
+		scopes["ip"] = ip
+	}
+	return scopes
+}
+
+// maxAttempts возвращает число попыток до блокировки для ограничения
+func (t *loginThrottle) maxAttempts(scope string) int {
+	if scope == "ip" {
+		return t.cfg.IPMaxAttempts
+	}
+	return t.cfg.MaxAttempts
+}
+
+// check возвращает, через сколько можно повторить вход; 0, если вход разрешен
+func (t *loginThrottle) check(ctx context.Context, username, ip string) (time.Duration, error) {
+	var retryAfter time.Duration
+	for scope, value := range t.scopes(username, ip) {
+		ttl, err := t.client.PTTL(ctx, fmt.Sprintf(loginLockKeyFmt, scope, value)).Result()
+		if err != nil {
+			return 0, fmt.Errorf("failed to check login throttle: %w", err)
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	return retryAfter, nil
+}
+
+// fail учитывает неудачную попытку и возвращает начавшиеся блокировки
+func (t *loginThrottle) fail(ctx context.Context, username, ip string) ([]loginLockout, error) {
+	var lockouts []loginLockout
+	for scope, value := range t.scopes(username, ip) {
+		keys := []string{
+			fmt.Sprintf(loginFailKeyFmt, scope, value),
+			fmt.Sprintf(loginLockKeyFmt, scope, value),
+		}
+		result, err := loginFailScript.Run(ctx, t.client, keys,
+			t.cfg.Lockout.Milliseconds(),
+			t.cfg.FreeAttempts,
+			t.cfg.BaseDelay.Milliseconds(),
+			t.maxAttempts(scope),
+			t.cfg.Lockout.Milliseconds(),
+		).Int64Slice()
+		if err != nil {
+			return lockouts, fmt.Errorf("failed to record login failure: %w", err)
+		}
+
+		if len(result) == 3 && result[2] == 1 {
+			lockouts = append(lockouts, loginLockout{
+				scope:    scope,
+				value:    value,
+				attempts: result[0],
+				duration: time.Duration(result[1]) * time.Millisecond,
+			})
+		}
+	}
+	return lockouts, nil
+}
+
+// reset сбрасывает счетчик имени пользователя после успешного входа.
+// Счетчик IP не сбрасывается, иначе вход в свою учетную запись позволял бы продолжать подбор.
+func (t *loginThrottle) reset(ctx context.Context, username string) error {
+	if err := t.client.Del(ctx, fmt.Sprintf(loginFailKeyFmt, "user", strings.ToLower(username))).Err(); err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+// unlock снимает блокировку и задержку входа для имени пользователя
+func (t *loginThrottle) unlock(ctx context.Context, username string) (bool, error) {
+	value := strings.ToLower(username)
+	deleted, err := t.client.Del(ctx,
+		fmt.Sprintf(loginFailKeyFmt, "user", value),
+		fmt.Sprintf(loginLockKeyFmt, "user", value),
+	).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock account: %w", err)
+	}
+	return deleted > 0, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Назначения одноразовых токенов, отправляемых по почте
+const (
+	tokenPurposeEmailVerification = "email_verification"
+	tokenPurposePasswordReset     = "password_reset"
+)
+
+// errMailTokenInvalid возвращается для неизвестного, истекшего или уже использованного токена
+var errMailTokenInvalid = errors.New("invalid or expired token")
+
+// mailTokenStore хранит одноразовые токены подтверждения email и сброса пароля в Redis.
+//
+//	<purpose>:<sha256(token)>  -> ID пользователя
+//	<purpose>_user:<user>      -> sha256 последнего выданного токена; новый токен отменяет предыдущий
+//
+// В Redis хранится только хеш токена, сам токен есть только в письме.
+type mailTokenStore struct {
+	client *redis.Client
+}
+
+// issue выдает новый токен и отменяет ранее выданный токен того же назначения
+func (s *mailTokenStore) issue(ctx context.Context, purpose string, userID int, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	hash := hashMailToken(token)
+
+	userKey := fmt.Sprintf("%s_user:%d", purpose, userID)
+	previous, err := s.client.Get(ctx, userKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", fmt.Errorf("failed to get previous token: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, purpose+":"+previous)
+		}
+		pipe.Set(ctx, purpose+":"+hash, userID, ttl)
+		pipe.Set(ctx, userKey, hash, ttl)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+
+	return token, nil
+}
+
+// consume проверяет токен, удаляет его и возвращает ID пользователя
+func (s *mailTokenStore) consume(ctx context.Context, purpose, token string) (int, error) {
+	value, err := s.client.GetDel(ctx, purpose+":"+hashMailToken(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, errMailTokenInvalid
+		}
+		return 0, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errMailTokenInvalid
+	}
+
+	if err := s.client.Del(ctx, fmt.Sprintf("%s_user:%d", purpose, userID)).Err(); err != nil {
+		return 0, fmt.Errorf("failed to delete token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// hashMailToken возвращает ключ токена в Redis
+func hashMailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
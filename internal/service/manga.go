@@ -1,35 +1,66 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"image/png"
 	"log/slog"
+	"strings"
+	"sync/atomic"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/utils"
+	"github.com/redis/go-redis/v9"
 )
 
 // MangaService предоставляет методы для работы с мангой
+// Изменять мангу могут участники команды, которой она принадлежит, с соответствующим правом
+// и администраторы.
 type MangaService struct {
-	repo   repository.MangaRepository
-	logger *slog.Logger
+	repo     repository.MangaRepository
+	teamRepo repository.TeamRepository
+	audit    *AuditService
+	suggest  *suggestIndex
+	logger   *slog.Logger
+
+	// suggestRebuilding выставлен, пока идет фоновое перестроение индекса подсказок
+	suggestRebuilding atomic.Bool
 }
 
-// NewMangaService создает новый экземпляр MangaService
-func NewMangaService(repo repository.MangaRepository, logger *slog.Logger) *MangaService {
+// NewMangaService создает новый экземпляр MangaService.
+// Индекс подсказок поиска хранится в Redis и обновляется при создании, изменении и удалении манги.
+func NewMangaService(
+	repo repository.MangaRepository,
+	teamRepo repository.TeamRepository,
+	audit *AuditService,
+	redisClient *redis.Client,
+	logger *slog.Logger,
+) *MangaService {
 	return &MangaService{
-		repo:   repo,
-		logger: logger,
+		repo:     repo,
+		teamRepo: teamRepo,
+		audit:    audit,
+		suggest:  &suggestIndex{client: redisClient},
+		logger:   logger,
 	}
 }
 
-// GetAll возвращает список манги с фильтрацией и пагинацией
-func (s *MangaService) GetAll(ctx context.Context, filter domain.MangaFilter) ([]domain.Manga, int, error) {
-	s.logger.Debug("getting manga list with filter",
-		"genre", filter.Genre,
-		"status", filter.Status,
+// GetAll возвращает страницу списка манги с фильтрацией.
+// Следующая страница запрашивается по курсору из предыдущей; без курсора используется номер страницы filter.Page.
+func (s *MangaService) GetAll(ctx context.Context, filter domain.MangaFilter, cursor string) (domain.MangaPage, error) {
+	s.logger.DebugContext(ctx, "getting manga list with filter",
+		"genres", filter.Genres,
+		"statuses", filter.Statuses,
 		"search", filter.Search,
-		"page", filter.Page)
+		"page", filter.Page,
+		"cursor", cursor)
+
+	if err := validateMangaFilter(&filter); err != nil {
+		return domain.MangaPage{}, err
+	}
 
 	// Устанавливаем значения по умолчанию для пагинации
 	if filter.Page < 1 {
@@ -39,103 +70,546 @@ func (s *MangaService) GetAll(ctx context.Context, filter domain.MangaFilter) ([
 		filter.PageSize = 20
 	}
 
+	// Результаты поиска по умолчанию сортируются по релевантности
+	filter.Search = strings.TrimSpace(filter.Search)
+	if filter.Search != "" && filter.SortBy == "" {
+		filter.SortBy = "relevance"
+	}
+	normalizeMangaSort(&filter)
+
+	after, err := decodePageCursor(cursor, mangaCursorSort(filter.SortBy), filter.SortDesc)
+	if err != nil {
+		return domain.MangaPage{}, err
+	}
+	filter.After = after
+
 	// Получаем данные из репозитория
 	mangas, total, err := s.repo.GetAll(ctx, filter)
 	if err != nil {
-		s.logger.Error("failed to get manga list", "error", err)
-		return nil, 0, err
+		s.logger.ErrorContext(ctx, "failed to get manga list", "error", err)
+		return domain.MangaPage{}, err
+	}
+
+	page := domain.MangaPage{Mangas: mangas}
+	if filter.WithTotal {
+		page.Total = &total
+	}
+	if len(mangas) > filter.PageSize {
+		page.Mangas = mangas[:filter.PageSize]
+		page.NextCursor = encodePageCursor(mangaCursor(mangas[filter.PageSize-1], filter))
+	}
+	if page.Mangas == nil {
+		page.Mangas = []domain.Manga{}
 	}
 
-	s.logger.Debug("got manga list", "count", len(mangas), "total", total)
-	return mangas, total, nil
+	s.logger.DebugContext(ctx, "got manga list", "count", len(page.Mangas), "total", total)
+	return page, nil
 }
 
 // GetByID возвращает мангу по ID
 func (s *MangaService) GetByID(ctx context.Context, id int) (domain.Manga, error) {
-	s.logger.Debug("getting manga by id", "id", id)
+	s.logger.DebugContext(ctx, "getting manga by id", "id", id)
 
 	manga, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get manga by id", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to get manga by id", "id", id, "error", err)
 		return domain.Manga{}, err
 	}
 
 	return manga, nil
 }
 
-// Create создает новую мангу
-func (s *MangaService) Create(ctx context.Context, manga domain.Manga) (int, error) {
-	s.logger.Info("creating new manga", "title", manga.Title)
+// Create создает новую мангу команды manga.TeamID.
+// Пользователь должен иметь право загрузки в этой команде; мангу без команды создает только администратор.
+func (s *MangaService) Create(ctx context.Context, userID int, admin bool, manga domain.Manga) (int, error) {
+	s.logger.InfoContext(ctx, "creating new manga", "title", manga.Title, "team_id", manga.TeamID, "user_id", userID)
 
 	if manga.Title == "" {
 		return 0, errors.New("manga title is required")
 	}
 
+	if manga.TeamID != nil {
+		if _, err := s.teamRepo.GetByID(ctx, *manga.TeamID); err != nil {
+			s.logger.ErrorContext(ctx, "team not found", "team_id", *manga.TeamID, "error", err)
+			return 0, err
+		}
+	}
+
+	if err := checkTeamPermission(ctx, s.teamRepo, userID, admin, manga.TeamID, domain.PermissionUpload); err != nil {
+		return 0, err
+	}
+
 	id, err := s.repo.Create(ctx, manga)
 	if err != nil {
-		s.logger.Error("failed to create manga", "title", manga.Title, "error", err)
+		s.logger.ErrorContext(ctx, "failed to create manga", "title", manga.Title, "error", err)
 		return 0, err
 	}
 
-	s.logger.Info("manga created successfully", "id", id, "title", manga.Title)
+	manga.ID = id
+	s.audit.Record(ctx, AuditActionCreate, domain.AuditEntityManga, id, nil, manga)
+	s.updateSuggestions(ctx, manga)
+
+	s.logger.InfoContext(ctx, "manga created successfully", "id", id, "title", manga.Title)
 	return id, nil
 }
 
-// Update обновляет информацию о манге
-func (s *MangaService) Update(ctx context.Context, manga domain.Manga) error {
-	s.logger.Info("updating manga", "id", manga.ID, "title", manga.Title)
+// Update обновляет информацию о манге. Команда манги не меняется, для этого используется SetTeam.
+func (s *MangaService) Update(ctx context.Context, userID int, admin bool, manga domain.Manga) error {
+	s.logger.InfoContext(ctx, "updating manga", "id", manga.ID, "title", manga.Title, "user_id", userID)
 
 	if manga.ID == 0 {
 		return errors.New("manga id is required")
 	}
 
 	// Проверяем, существует ли манга
-	_, err := s.repo.GetByID(ctx, manga.ID)
+	existing, err := s.repo.GetByID(ctx, manga.ID)
 	if err != nil {
-		s.logger.Error("manga not found for update", "id", manga.ID, "error", err)
+		s.logger.ErrorContext(ctx, "manga not found for update", "id", manga.ID, "error", err)
+		return err
+	}
+
+	if err := checkTeamPermission(ctx, s.teamRepo, userID, admin, existing.TeamID, domain.PermissionUpload); err != nil {
 		return err
 	}
 
 	err = s.repo.Update(ctx, manga)
 	if err != nil {
-		s.logger.Error("failed to update manga", "id", manga.ID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to update manga", "id", manga.ID, "error", err)
 		return err
 	}
 
-	s.logger.Info("manga updated successfully", "id", manga.ID)
+	s.audit.Record(ctx, AuditActionUpdate, domain.AuditEntityManga, manga.ID, existing, manga)
+	s.updateSuggestions(ctx, manga)
+
+	s.logger.InfoContext(ctx, "manga updated successfully", "id", manga.ID)
 	return nil
 }
 
 // Delete удаляет мангу по ID
-func (s *MangaService) Delete(ctx context.Context, id int) error {
-	s.logger.Info("deleting manga", "id", id)
+func (s *MangaService) Delete(ctx context.Context, userID int, admin bool, id int) error {
+	s.logger.InfoContext(ctx, "deleting manga", "id", id, "user_id", userID)
 
 	// Проверяем, существует ли манга
-	_, err := s.repo.GetByID(ctx, id)
+	manga, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("manga not found for deletion", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "manga not found for deletion", "id", id, "error", err)
+		return err
+	}
+
+	if err := checkTeamPermission(ctx, s.teamRepo, userID, admin, manga.TeamID, domain.PermissionDeleteManga); err != nil {
 		return err
 	}
 
 	err = s.repo.Delete(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to delete manga", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to delete manga", "id", id, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityManga, id, manga, nil)
+	if err := s.suggest.remove(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "failed to remove manga suggestions", "id", id, "error", err)
+	}
+
+	s.logger.InfoContext(ctx, "manga deleted successfully", "id", id)
+	return nil
+}
+
+// SetTeam назначает манге команду; nil снимает назначение
+func (s *MangaService) SetTeam(ctx context.Context, mangaID int, teamID *int) error {
+	s.logger.InfoContext(ctx, "setting manga team", "id", mangaID, "team_id", teamID)
+
+	if teamID != nil {
+		if _, err := s.teamRepo.GetByID(ctx, *teamID); err != nil {
+			s.logger.ErrorContext(ctx, "team not found", "team_id", *teamID, "error", err)
+			return err
+		}
+	}
+
+	existing, err := s.repo.GetByID(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "manga not found", "id", mangaID, "error", err)
+		return err
+	}
+
+	if err := s.repo.SetTeam(ctx, mangaID, teamID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to set manga team", "id", mangaID, "error", err)
+		return err
+	}
+
+	updated := existing
+	updated.TeamID = teamID
+	s.audit.Record(ctx, AuditActionSetTeam, domain.AuditEntityManga, mangaID, existing, updated)
+
+	s.logger.InfoContext(ctx, "manga team set successfully", "id", mangaID, "team_id", teamID)
+	return nil
+}
+
+// Suggest возвращает подсказки для строки поиска: названия, альтернативные названия, авторов и жанры,
+// начинающиеся с q или содержащие слово, начинающееся с q
+func (s *MangaService) Suggest(ctx context.Context, q string, limit int) ([]domain.Suggestion, error) {
+	if limit <= 0 || limit > maxSuggestSize {
+		limit = defaultSuggestSize
+	}
+
+	// Индекс строится при запуске, но мог пропасть вместе с данными Redis. Тогда индекс
+	// перестраивается в фоне, а до конца перестроения подсказок нет.
+	built, err := s.suggest.built(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check suggest index", "error", err)
+		return nil, err
+	}
+	if !built {
+		s.rebuildSuggestIndexAsync(ctx)
+		return []domain.Suggestion{}, nil
+	}
+
+	suggestions, err := s.suggest.search(ctx, q, limit)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get suggestions", "q", q, "error", err)
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// RebuildSuggestIndex заново строит индекс подсказок поиска по всей манге и жанрам
+func (s *MangaService) RebuildSuggestIndex(ctx context.Context) error {
+	s.logger.InfoContext(ctx, "rebuilding suggest index")
+
+	mangas, err := s.repo.ListSuggestSources(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get manga for suggest index", "error", err)
+		return err
+	}
+
+	genres, err := s.repo.GetGenres(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get genres for suggest index", "error", err)
+		return err
+	}
+
+	if err := s.suggest.rebuild(ctx, mangas, genres); err != nil {
+		s.logger.ErrorContext(ctx, "failed to rebuild suggest index", "error", err)
 		return err
 	}
 
-	s.logger.Info("manga deleted successfully", "id", id)
+	s.logger.InfoContext(ctx, "suggest index rebuilt", "manga", len(mangas), "genres", len(genres))
 	return nil
 }
 
+// rebuildSuggestIndexAsync запускает перестроение индекса подсказок в фоне, если оно еще не идет
+func (s *MangaService) rebuildSuggestIndexAsync(ctx context.Context) {
+	if !s.suggestRebuilding.CompareAndSwap(false, true) {
+		return
+	}
+
+	s.logger.WarnContext(ctx, "suggest index is missing, rebuilding in background")
+
+	go func() {
+		defer s.suggestRebuilding.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), suggestRebuildTimeout)
+		defer cancel()
+
+		// Ошибка уже залогирована, следующий запрос подсказок повторит попытку
+		_ = s.RebuildSuggestIndex(ctx)
+	}()
+}
+
+// updateSuggestions обновляет подсказки манги. Ошибка не отменяет изменение манги и только логируется.
+func (s *MangaService) updateSuggestions(ctx context.Context, manga domain.Manga) {
+	if err := s.suggest.put(ctx, manga); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update manga suggestions", "id", manga.ID, "error", err)
+	}
+}
+
 // GetGenres возвращает список всех жанров
 func (s *MangaService) GetGenres(ctx context.Context) ([]domain.Genre, error) {
-	s.logger.Debug("getting genres list")
+	s.logger.DebugContext(ctx, "getting genres list")
 
 	genres, err := s.repo.GetGenres(ctx)
 	if err != nil {
-		s.logger.Error("failed to get genres list", "error", err)
+		s.logger.ErrorContext(ctx, "failed to get genres list", "error", err)
 		return nil, err
 	}
 
 	return genres, nil
 }
+
+// RateManga сохраняет оценку манги пользователем и возвращает обновленный рейтинг
+func (s *MangaService) RateManga(ctx context.Context, userID, mangaID, score int) (domain.MangaRating, error) {
+	s.logger.InfoContext(ctx, "rating manga", "user_id", userID, "manga_id", mangaID, "score", score)
+
+	if score < 1 || score > 10 {
+		return domain.MangaRating{}, fmt.Errorf("invalid score: %d (must be from 1 to 10)", score)
+	}
+
+	rating, err := s.repo.SetRating(ctx, userID, mangaID, score)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to rate manga", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, err
+	}
+
+	s.logger.InfoContext(ctx, "manga rated successfully", "manga_id", mangaID, "rating", rating.Rating, "votes", rating.RatingCount)
+	return rating, nil
+}
+
+// RemoveRating удаляет оценку манги пользователем и возвращает обновленный рейтинг
+func (s *MangaService) RemoveRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error) {
+	s.logger.InfoContext(ctx, "removing manga rating", "user_id", userID, "manga_id", mangaID)
+
+	rating, err := s.repo.DeleteRating(ctx, userID, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to remove manga rating", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, err
+	}
+
+	s.logger.InfoContext(ctx, "manga rating removed successfully", "manga_id", mangaID, "rating", rating.Rating, "votes", rating.RatingCount)
+	return rating, nil
+}
+
+// GetRating возвращает рейтинг манги и оценку пользователя
+func (s *MangaService) GetRating(ctx context.Context, userID, mangaID int) (domain.MangaRating, error) {
+	s.logger.DebugContext(ctx, "getting manga rating", "user_id", userID, "manga_id", mangaID)
+
+	rating, err := s.repo.GetUserRating(ctx, userID, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get manga rating", "user_id", userID, "manga_id", mangaID, "error", err)
+		return domain.MangaRating{}, err
+	}
+
+	return rating, nil
+}
+
+// GetWatermark возвращает настройки водяного знака манги
+func (s *MangaService) GetWatermark(ctx context.Context, userID int, admin bool, mangaID int) (*domain.Watermark, error) {
+	s.logger.DebugContext(ctx, "getting watermark", "manga_id", mangaID)
+
+	if err := s.checkUploadPermission(ctx, userID, admin, mangaID); err != nil {
+		return nil, err
+	}
+
+	watermark, err := s.repo.GetWatermark(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", mangaID, "error", err)
+		return nil, err
+	}
+	if watermark == nil {
+		return nil, fmt.Errorf("watermark for manga %d not found", mangaID)
+	}
+
+	return watermark, nil
+}
+
+// SaveWatermark сохраняет настройки водяного знака манги.
+// Если новый логотип не передан, сохраняется ранее загруженный.
+func (s *MangaService) SaveWatermark(ctx context.Context, userID int, admin bool, watermark domain.Watermark) error {
+	s.logger.InfoContext(ctx, "saving watermark", "manga_id", watermark.MangaID, "user_id", userID)
+
+	if err := s.checkUploadPermission(ctx, userID, admin, watermark.MangaID); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetWatermark(ctx, watermark.MangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", watermark.MangaID, "error", err)
+		return err
+	}
+	if watermark.Logo == nil && existing != nil {
+		watermark.Logo = existing.Logo
+	}
+
+	if watermark.Position == "" {
+		watermark.Position = utils.PositionBottomRight
+	}
+	if watermark.ApplyOn == "" {
+		watermark.ApplyOn = WatermarkApplyOnRendition
+	}
+
+	if err := validateWatermark(watermark); err != nil {
+		return err
+	}
+
+	err = s.repo.SaveWatermark(ctx, watermark)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to save watermark", "manga_id", watermark.MangaID, "error", err)
+		return err
+	}
+
+	watermark.HasLogo = len(watermark.Logo) > 0
+	s.audit.Record(ctx, AuditActionSaveWatermark, domain.AuditEntityManga, watermark.MangaID, existing, watermark)
+
+	s.logger.InfoContext(ctx, "watermark saved successfully", "manga_id", watermark.MangaID)
+	return nil
+}
+
+// DeleteWatermark удаляет настройки водяного знака манги
+func (s *MangaService) DeleteWatermark(ctx context.Context, userID int, admin bool, mangaID int) error {
+	s.logger.InfoContext(ctx, "deleting watermark", "manga_id", mangaID, "user_id", userID)
+
+	if err := s.checkUploadPermission(ctx, userID, admin, mangaID); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetWatermark(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get watermark", "manga_id", mangaID, "error", err)
+		return err
+	}
+
+	err = s.repo.DeleteWatermark(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete watermark", "manga_id", mangaID, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionDeleteWatermark, domain.AuditEntityManga, mangaID, existing, nil)
+
+	s.logger.InfoContext(ctx, "watermark deleted successfully", "manga_id", mangaID)
+	return nil
+}
+
+// checkUploadPermission проверяет, что пользователь может изменять мангу
+func (s *MangaService) checkUploadPermission(ctx context.Context, userID int, admin bool, mangaID int) error {
+	manga, err := s.repo.GetByID(ctx, mangaID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "manga not found", "manga_id", mangaID, "error", err)
+		return err
+	}
+
+	return checkTeamPermission(ctx, s.teamRepo, userID, admin, manga.TeamID, domain.PermissionUpload)
+}
+
+// validateMangaFilter проверяет фильтры списка манги и устанавливает способ сочетания жанров по умолчанию
+func validateMangaFilter(filter *domain.MangaFilter) error {
+	switch filter.GenreMode {
+	case "":
+		filter.GenreMode = domain.GenreModeAll
+	case domain.GenreModeAll, domain.GenreModeAny:
+	default:
+		return fmt.Errorf("invalid filter: unknown genre mode %s", filter.GenreMode)
+	}
+
+	if filter.YearFrom != nil && filter.YearTo != nil && *filter.YearFrom > *filter.YearTo {
+		return errors.New("invalid filter: yearFrom is greater than yearTo")
+	}
+
+	if filter.MinRating != nil && (*filter.MinRating < 0 || *filter.MinRating > 10) {
+		return errors.New("invalid filter: minRating must be from 0 to 10")
+	}
+
+	if (filter.ChaptersFrom != nil && *filter.ChaptersFrom < 0) || (filter.ChaptersTo != nil && *filter.ChaptersTo < 0) {
+		return errors.New("invalid filter: chapter count cannot be negative")
+	}
+	if filter.ChaptersFrom != nil && filter.ChaptersTo != nil && *filter.ChaptersFrom > *filter.ChaptersTo {
+		return errors.New("invalid filter: chaptersFrom is greater than chaptersTo")
+	}
+
+	return nil
+}
+
+// normalizeMangaSort приводит сортировку списка манги к одному из поддерживаемых вариантов:
+// неизвестная сортировка и релевантность без поискового запроса заменяются сортировкой по обновлению,
+// а релевантность всегда убывает
+func normalizeMangaSort(filter *domain.MangaFilter) {
+	switch filter.SortBy {
+	case "title", "rating", "date", "updated":
+	case "relevance":
+		if filter.Search == "" {
+			filter.SortBy = "updated"
+		} else {
+			filter.SortDesc = true
+		}
+	default:
+		filter.SortBy = "updated"
+	}
+}
+
+// mangaCursorSort возвращает значение PageCursor.Sort для сортировки списка манги
+func mangaCursorSort(sortBy string) string {
+	return "manga_" + sortBy
+}
+
+// mangaCursor возвращает позицию манги в списке с сортировкой filter.SortBy
+func mangaCursor(manga domain.Manga, filter domain.MangaFilter) domain.PageCursor {
+	cursor := domain.PageCursor{
+		Sort: mangaCursorSort(filter.SortBy),
+		Desc: filter.SortDesc,
+		ID:   manga.ID,
+	}
+
+	switch filter.SortBy {
+	case "title":
+		cursor.Text = manga.Title
+	case "rating":
+		cursor.Number = manga.Rating
+		cursor.Count = manga.RatingCount
+	case "date":
+		cursor.Time = manga.CreatedAt
+	case "relevance":
+		cursor.Number = manga.Relevance
+	default:
+		cursor.Time = manga.UpdatedAt
+	}
+
+	return cursor
+}
+
+// Этапы, на которых применяется водяной знак
+const (
+	WatermarkApplyOnUpload    = "upload"    // При загрузке страницы (исходное изображение изменяется)
+	WatermarkApplyOnRendition = "rendition" // При генерации вариантов изображения
+)
+
+// validateWatermark проверяет настройки водяного знака
+func validateWatermark(watermark domain.Watermark) error {
+	switch watermark.Position {
+	case utils.PositionTopLeft, utils.PositionTopRight, utils.PositionBottomLeft,
+		utils.PositionBottomRight, utils.PositionCenter:
+	default:
+		return fmt.Errorf("invalid watermark position: %s", watermark.Position)
+	}
+
+	if watermark.ApplyOn != WatermarkApplyOnUpload && watermark.ApplyOn != WatermarkApplyOnRendition {
+		return fmt.Errorf("invalid watermark apply_on: %s", watermark.ApplyOn)
+	}
+
+	if watermark.Opacity <= 0 || watermark.Opacity > 1 {
+		return errors.New("invalid watermark opacity: must be in (0, 1]")
+	}
+
+	if watermark.Scale <= 0 || watermark.Scale > 1 {
+		return errors.New("invalid watermark scale: must be in (0, 1]")
+	}
+
+	if watermark.Text == "" && len(watermark.Logo) == 0 {
+		return errors.New("invalid watermark: logo or text is required")
+	}
+
+	if len(watermark.Logo) > 0 && utils.DetectImageFormat(watermark.Logo) != utils.FormatPNG {
+		return errors.New("invalid watermark: logo must be a PNG image")
+	}
+
+	return nil
+}
+
+// watermarkOptions преобразует настройки водяного знака в опции обработки изображения
+func watermarkOptions(watermark domain.Watermark) (*utils.WatermarkOptions, error) {
+	options := &utils.WatermarkOptions{
+		Text:     watermark.Text,
+		Position: watermark.Position,
+		Opacity:  watermark.Opacity,
+		Scale:    watermark.Scale,
+	}
+
+	if len(watermark.Logo) > 0 {
+		logo, err := png.Decode(bytes.NewReader(watermark.Logo))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode watermark logo: %w", err)
+		}
+		options.Logo = logo
+	}
+
+	return options, nil
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/oidc"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+// oidcStateTTL время, за которое пользователь должен вернуться от провайдера
+const oidcStateTTL = 10 * time.Minute
+
+// Ошибки входа через OpenID Connect
+var (
+	errOIDCProviderNotFound = errors.New("identity provider not found")
+	errOIDCStateInvalid     = errors.New("invalid or expired login state")
+	errOIDCLoginFailed      = errors.New("external login failed")
+	errOIDCEmailMissing     = errors.New("identity provider did not return an email")
+)
+
+// oidcState параметры начатого входа, которые нужны для завершения на callback
+type oidcState struct {
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+}
+
+// oidcStateStore хранит параметры входа в Redis под ключом oidc_state:<state> до возврата пользователя
+type oidcStateStore struct {
+	client *redis.Client
+}
+
+// save сохраняет параметры входа
+func (s *oidcStateStore) save(ctx context.Context, state string, value oidcState) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode login state: %w", err)
+	}
+
+	if err := s.client.Set(ctx, "oidc_state:"+state, data, oidcStateTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+
+	return nil
+}
+
+// consume возвращает и удаляет параметры входа, state можно использовать только один раз
+func (s *oidcStateStore) consume(ctx context.Context, state string) (oidcState, error) {
+	data, err := s.client.GetDel(ctx, "oidc_state:"+state).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return oidcState{}, errOIDCStateInvalid
+		}
+		return oidcState{}, fmt.Errorf("failed to get login state: %w", err)
+	}
+
+	var value oidcState
+	if err := json.Unmarshal(data, &value); err != nil {
+		return oidcState{}, errOIDCStateInvalid
+	}
+
+	return value, nil
+}
+
+// OIDCProviders возвращает имена настроенных провайдеров OpenID Connect
+func (s *AuthService) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidc))
+	for name := range s.oidc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin начинает вход через провайдера и возвращает адрес страницы входа провайдера
+func (s *AuthService) StartOIDCLogin(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return "", errOIDCProviderNotFound
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to build oidc auth url", "provider", providerName, "error", err)
+		return "", fmt.Errorf("failed to start external login: %w", err)
+	}
+
+	err = s.oidcStates.save(ctx, state, oidcState{Provider: providerName, Verifier: verifier, Nonce: nonce})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to save oidc state", "provider", providerName, "error", err)
+		return "", err
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin завершает вход через провайдера: проверяет ответ, находит или создает
+// пользователя, привязанного к внешней учетной записи, и начинает новую сессию
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, providerName, state, code string, client domain.ClientInfo) (domain.TokenResponse, error) {
+	s.logger.InfoContext(ctx, "processing oidc callback", "provider", providerName)
+
+	provider, ok := s.oidc[providerName]
+	if !ok {
+		return domain.TokenResponse{}, errOIDCProviderNotFound
+	}
+
+	saved, err := s.oidcStates.consume(ctx, state)
+	if err != nil {
+		if errors.Is(err, errOIDCStateInvalid) {
+			s.logger.WarnContext(ctx, "invalid oidc state", "provider", providerName)
+		}
+		return domain.TokenResponse{}, err
+	}
+	if saved.Provider != providerName {
+		s.logger.WarnContext(ctx, "oidc state issued for another provider", "provider", providerName, "expected", saved.Provider)
+		return domain.TokenResponse{}, errOIDCStateInvalid
+	}
+
+	claims, err := provider.Exchange(ctx, code, saved.Verifier, saved.Nonce)
+	if err != nil {
+		s.logger.WarnContext(ctx, "oidc code exchange failed", "provider", providerName, "error", err)
+		return domain.TokenResponse{}, errOIDCLoginFailed
+	}
+
+	user, err := s.identityUser(ctx, providerName, claims)
+	if err != nil {
+		return domain.TokenResponse{}, err
+	}
+
+	return s.authenticate(ctx, user, client)
+}
+
+// identityUser возвращает пользователя, к которому привязана внешняя учетная запись.
+// Если учетная запись еще не привязана, она привязывается к пользователю с тем же email,
+// когда провайдер подтвердил адрес, иначе создается новый пользователь.
+func (s *AuthService) identityUser(ctx context.Context, providerName string, claims oidc.Claims) (domain.User, error) {
+	existing, err := s.userRepo.GetByIdentity(ctx, providerName, claims.Subject)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user by identity", "provider", providerName, "error", err)
+		return domain.User{}, err
+	}
+	if existing != nil {
+		return *existing, nil
+	}
+
+	// email обязателен: он уникален среди пользователей и нужен для сброса пароля
+	if claims.Email == "" {
+		s.logger.WarnContext(ctx, "oidc provider returned no email", "provider", providerName)
+		return domain.User{}, errOIDCEmailMissing
+	}
+
+	identity := domain.UserIdentity{
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	if err == nil {
+		if !claims.EmailVerified {
+			// Иначе любой провайдер мог бы войти в чужую учетную запись, указав ее email
+			s.logger.WarnContext(ctx, "oidc email not verified, refusing to link", "provider", providerName, "user_id", user.ID)
+			return domain.User{}, errors.New("email already exists")
+		}
+
+		identity.UserID = user.ID
+		if err := s.userRepo.AddIdentity(ctx, identity); err != nil {
+			s.logger.ErrorContext(ctx, "failed to link identity", "provider", providerName, "user_id", user.ID, "error", err)
+			return domain.User{}, fmt.Errorf("failed to link identity: %w", err)
+		}
+
+		if !user.EmailVerified {
+			if err := s.userRepo.SetEmailVerified(ctx, user.ID); err != nil {
+				s.logger.WarnContext(ctx, "failed to verify email", "user_id", user.ID, "error", err)
+			}
+			user.EmailVerified = true
+		}
+
+		s.logger.InfoContext(ctx, "identity linked to existing user", "provider", providerName, "user_id", user.ID)
+		return user, nil
+	}
+	if !strings.Contains(err.Error(), "not found") {
+		s.logger.ErrorContext(ctx, "failed to get user by email", "error", err)
+		return domain.User{}, err
+	}
+
+	return s.createIdentityUser(ctx, identity, claims)
+}
+
+// createIdentityUser регистрирует нового пользователя по данным внешней учетной записи
+func (s *AuthService) createIdentityUser(ctx context.Context, identity domain.UserIdentity, claims oidc.Claims) (domain.User, error) {
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	// Вход по паролю для такого пользователя невозможен, пока он не сбросит пароль
+	password, err := randomToken()
+	if err != nil {
+		return domain.User{}, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to hash password", "error", err)
+		return domain.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := domain.User{
+		Username:      username,
+		Email:         claims.Email,
+		PasswordHash:  string(hashedPassword),
+		Role:          "user",
+		EmailVerified: claims.EmailVerified,
+		CreatedAt:     Now(),
+		UpdatedAt:     Now(),
+	}
+
+	id, err := s.userRepo.CreateWithIdentity(ctx, user, identity)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create user", "provider", identity.Provider, "error", err)
+		return domain.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	user.ID = id
+
+	s.logger.InfoContext(ctx, "user registered via oidc", "id", id, "provider", identity.Provider)
+
+	if !user.EmailVerified {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			s.logger.WarnContext(ctx, "failed to send verification email", "user_id", id, "error", err)
+		}
+	}
+
+	return user, nil
+}
+
+// availableUsername подбирает свободное имя пользователя на основе данных провайдера
+func (s *AuthService) availableUsername(ctx context.Context, claims oidc.Claims) (string, error) {
+	base := claims.Username
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		default:
+			return -1
+		}
+	}, base)
+	if len(base) > 24 {
+		base = base[:24]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	username := base
+	for i := 0; i < 5; i++ {
+		if _, err := s.userRepo.GetByUsername(ctx, username); err != nil {
+			return username, nil
+		}
+
+		suffix := make([]byte, 2)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate username: %w", err)
+		}
+		username = fmt.Sprintf("%s_%d", base, int(suffix[0])<<8|int(suffix[1]))
+	}
+
+	return "", errors.New("failed to generate username")
+}
+
+// randomToken возвращает случайную строку для state, nonce и паролей
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,563 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/jwtkeys"
+	"github.com/LirikaOne-Back/manga-reader3/internal/mailer"
+	"github.com/LirikaOne-Back/manga-reader3/internal/oidc"
+	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testOIDCClientID = "manga-reader"
+
+// testIDToken данные ID-токена, который выдаст testIssuer
+type testIDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Nonce         string // Пустой - nonce из запроса авторизации
+}
+
+// testAuthorization код авторизации, выданный testIssuer
+type testAuthorization struct {
+	claims    jwt.MapClaims
+	challenge string // PKCE code challenge из запроса авторизации
+}
+
+// testIssuer провайдер OpenID Connect для тестов: discovery-документ, JWKS и token endpoint
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testAuthorization
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	issuer := &testIssuer{key: key, codes: make(map[string]testAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.server.URL,
+		"authorization_endpoint":                i.server.URL + "/authorize",
+		"token_endpoint":                        i.server.URL + "/token",
+		"jwks_uri":                              i.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *testIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token обменивает код на ID-токен, проверяя PKCE code verifier
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	i.mu.Lock()
+	auth, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	if !ok || pkceChallenge(r.PostForm.Get("code_verifier")) != auth.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(i.key)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authorize имитирует вход пользователя на странице провайдера по адресу authURL
+// и возвращает state и код авторизации для callback
+func (i *testIssuer) authorize(t *testing.T, authURL string, id testIDToken) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth url: %v", err)
+	}
+	query := parsed.Query()
+
+	if !strings.HasPrefix(authURL, i.server.URL+"/authorize?") {
+		t.Fatalf("auth url %s does not point to the provider", authURL)
+	}
+	if query.Get("client_id") != testOIDCClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url parameters: %s", parsed.RawQuery)
+	}
+
+	nonce := id.Nonce
+	if nonce == "" {
+		nonce = query.Get("nonce")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            i.server.URL,
+		"aud":            testOIDCClientID,
+		"sub":            id.Subject,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          id.Email,
+		"email_verified": id.EmailVerified,
+	}
+	if id.Username != "" {
+		claims["preferred_username"] = id.Username
+	}
+
+	code := fmt.Sprintf("code-%d", now.UnixNano())
+
+	i.mu.Lock()
+	i.codes[code] = testAuthorization{claims: claims, challenge: query.Get("code_challenge")}
+	i.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// testUserRepo хранит пользователей и привязанные внешние учетные записи в памяти
+type testUserRepo struct {
+	repository.UserRepository
+
+	mu         sync.Mutex
+	users      map[int]domain.User
+	identities map[string]int // provider/subject -> ID пользователя
+}
+
+func newTestUserRepo(users ...domain.User) *testUserRepo {
+	repo := &testUserRepo{users: make(map[int]domain.User), identities: make(map[string]int)}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+	return repo
+}
+
+func (r *testUserRepo) GetByID(ctx context.Context, id int) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return domain.User{}, fmt.Errorf("user with id %d not found", id)
+	}
+	return user, nil
+}
+
+func (r *testUserRepo) GetByUsername(ctx context.Context, username string) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return domain.User{}, fmt.Errorf("user with username %s not found", username)
+}
+
+func (r *testUserRepo) GetByEmail(ctx context.Context, email string) (domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return domain.User{}, fmt.Errorf("user with email %s not found", email)
+}
+
+func (r *testUserRepo) SetEmailVerified(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.users[id]
+	user.EmailVerified = true
+	r.users[id] = user
+	return nil
+}
+
+func (r *testUserRepo) GetByIdentity(ctx context.Context, provider, subject string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.identities[provider+"/"+subject]
+	if !ok {
+		return nil, nil
+	}
+	user := r.users[id]
+	return &user, nil
+}
+
+func (r *testUserRepo) CreateWithIdentity(ctx context.Context, user domain.User, identity domain.UserIdentity) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user.ID = len(r.users) + 100
+	r.users[user.ID] = user
+	r.identities[identity.Provider+"/"+identity.Subject] = user.ID
+	return user.ID, nil
+}
+
+func (r *testUserRepo) AddIdentity(ctx context.Context, identity domain.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.identities[identity.Provider+"/"+identity.Subject] = identity.UserID
+	return nil
+}
+
+func (r *testUserRepo) GetTOTP(ctx context.Context, userID int) (*domain.TOTP, error) {
+	return nil, nil
+}
+
+// identityUserID возвращает ID пользователя, к которому привязана учетная запись, или 0
+func (r *testUserRepo) identityUserID(provider, subject string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.identities[provider+"/"+subject]
+}
+
+// testMailer запоминает отправленные письма
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// newTestOIDCAuthService создает AuthService с провайдерами test и other, которые обслуживает issuer
+func newTestOIDCAuthService(t *testing.T, issuer *testIssuer, repo *testUserRepo, mail *testMailer) *AuthService {
+	t.Helper()
+
+	keys, err := jwtkeys.Load(jwtkeys.Config{Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	var providers []*oidc.Provider
+	for _, name := range []string{"test", "other"} {
+		providers = append(providers, oidc.NewProvider(oidc.ProviderConfig{
+			Name:         name,
+			IssuerURL:    issuer.server.URL,
+			ClientID:     testOIDCClientID,
+			ClientSecret: "client-secret",
+			RedirectURL:  "http://localhost:8080/api/auth/oidc/" + name + "/callback",
+		}))
+	}
+
+	return NewAuthService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)), AuthConfig{
+		Keys:        keys,
+		AccessTTL:   15 * time.Minute,
+		RefreshTTL:  24 * time.Hour,
+		RedisClient: newTestRedis(t),
+		Mailer:      mail,
+		AppURL:      "http://localhost:3000",
+		VerifyTTL:   24 * time.Hour,
+		OIDC:        providers,
+	})
+}
+
+// startTestOIDCLogin начинает вход через провайдера и проходит авторизацию у issuer
+func startTestOIDCLogin(t *testing.T, s *AuthService, issuer *testIssuer, provider string, id testIDToken) (string, string) {
+	t.Helper()
+
+	authURL, err := s.StartOIDCLogin(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	return issuer.authorize(t, authURL, id)
+}
+
+// checkTestTokens проверяет, что выданный access-токен принадлежит пользователю userID
+func checkTestTokens(t *testing.T, s *AuthService, tokens domain.TokenResponse, userID int) {
+	t.Helper()
+
+	claims, err := s.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != userID || claims.Type != "access" {
+		t.Fatalf("access token for user %d (%s), want user %d", claims.UserID, claims.Type, userID)
+	}
+	if tokens.RefreshToken == "" {
+		t.Fatal("refresh token is empty")
+	}
+}
+
+func TestCompleteOIDCLoginCreatesUser(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+	repo := newTestUserRepo()
+	mail := &testMailer{}
+	s := newTestOIDCAuthService(t, issuer, repo, mail)
+
+	state, code := startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+		Subject: "subject-1", Email: "reader@example.com", EmailVerified: true, Username: "reader",
+	})
+
+	tokens, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+
+	userID := repo.identityUserID("test", "subject-1")
+	user, err := repo.GetByID(ctx, userID)
+	if err != nil {
+		t.Fatalf("identity is not linked to a new user: %v", err)
+	}
+	if user.Username != "reader" || user.Email != "reader@example.com" || !user.EmailVerified {
+		t.Errorf("created user = %+v", user)
+	}
+	checkTestTokens(t, s, tokens, userID)
+
+	if len(mail.messages) != 0 {
+		t.Errorf("verification email sent for verified address: %+v", mail.messages)
+	}
+}
+
+func TestCompleteOIDCLoginStateMismatch(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+	repo := newTestUserRepo()
+	s := newTestOIDCAuthService(t, issuer, repo, &testMailer{})
+
+	id := testIDToken{Subject: "subject-1", Email: "reader@example.com", EmailVerified: true}
+
+	t.Run("unknown state", func(t *testing.T) {
+		_, code := startTestOIDCLogin(t, s, issuer, "test", id)
+
+		_, err := s.CompleteOIDCLogin(ctx, "test", "forged-state", code, domain.ClientInfo{})
+		if !errors.Is(err, errOIDCStateInvalid) {
+			t.Fatalf("CompleteOIDCLogin() error = %v, want %v", err, errOIDCStateInvalid)
+		}
+	})
+
+	t.Run("state issued for another provider", func(t *testing.T) {
+		state, code := startTestOIDCLogin(t, s, issuer, "other", id)
+
+		_, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+		if !errors.Is(err, errOIDCStateInvalid) {
+			t.Fatalf("CompleteOIDCLogin() error = %v, want %v", err, errOIDCStateInvalid)
+		}
+
+		// Неудачная попытка расходует state
+		_, err = s.CompleteOIDCLogin(ctx, "other", state, code, domain.ClientInfo{})
+		if !errors.Is(err, errOIDCStateInvalid) {
+			t.Fatalf("reused state: error = %v, want %v", err, errOIDCStateInvalid)
+		}
+	})
+
+	t.Run("state reused", func(t *testing.T) {
+		state, code := startTestOIDCLogin(t, s, issuer, "test", id)
+
+		if _, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{}); err != nil {
+			t.Fatalf("CompleteOIDCLogin: %v", err)
+		}
+
+		_, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+		if !errors.Is(err, errOIDCStateInvalid) {
+			t.Fatalf("reused state: error = %v, want %v", err, errOIDCStateInvalid)
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		state, code := startTestOIDCLogin(t, s, issuer, "test", id)
+
+		_, err := s.CompleteOIDCLogin(ctx, "missing", state, code, domain.ClientInfo{})
+		if !errors.Is(err, errOIDCProviderNotFound) {
+			t.Fatalf("CompleteOIDCLogin() error = %v, want %v", err, errOIDCProviderNotFound)
+		}
+	})
+}
+
+func TestCompleteOIDCLoginNonceMismatch(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+	repo := newTestUserRepo()
+	s := newTestOIDCAuthService(t, issuer, repo, &testMailer{})
+
+	state, code := startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+		Subject: "subject-1", Email: "reader@example.com", EmailVerified: true, Nonce: "replayed-nonce",
+	})
+
+	_, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+	if !errors.Is(err, errOIDCLoginFailed) {
+		t.Fatalf("CompleteOIDCLogin() error = %v, want %v", err, errOIDCLoginFailed)
+	}
+	if repo.identityUserID("test", "subject-1") != 0 {
+		t.Fatal("identity linked despite nonce mismatch")
+	}
+}
+
+func TestCompleteOIDCLoginUnverifiedEmail(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("existing user is not linked", func(t *testing.T) {
+		issuer := newTestIssuer(t)
+		repo := newTestUserRepo(domain.User{ID: 1, Username: "owner", Email: "owner@example.com", Role: "user", EmailVerified: true})
+		s := newTestOIDCAuthService(t, issuer, repo, &testMailer{})
+
+		state, code := startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+			Subject: "attacker", Email: "owner@example.com", EmailVerified: false,
+		})
+
+		_, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+		if err == nil || !strings.Contains(err.Error(), "email already exists") {
+			t.Fatalf("CompleteOIDCLogin() error = %v, want email already exists", err)
+		}
+		if repo.identityUserID("test", "attacker") != 0 {
+			t.Fatal("identity with unverified email linked to existing user")
+		}
+	})
+
+	t.Run("new user must verify email", func(t *testing.T) {
+		issuer := newTestIssuer(t)
+		repo := newTestUserRepo()
+		mail := &testMailer{}
+		s := newTestOIDCAuthService(t, issuer, repo, mail)
+
+		state, code := startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+			Subject: "subject-2", Email: "new@example.com", EmailVerified: false,
+		})
+
+		tokens, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+		if err != nil {
+			t.Fatalf("CompleteOIDCLogin: %v", err)
+		}
+
+		userID := repo.identityUserID("test", "subject-2")
+		user, err := repo.GetByID(ctx, userID)
+		if err != nil {
+			t.Fatalf("identity is not linked to a new user: %v", err)
+		}
+		if user.EmailVerified || user.Username != "new" {
+			t.Errorf("created user = %+v, want unverified user new", user)
+		}
+		checkTestTokens(t, s, tokens, userID)
+
+		if len(mail.messages) != 1 || mail.messages[0].To != "new@example.com" {
+			t.Errorf("verification emails = %+v, want one to new@example.com", mail.messages)
+		}
+	})
+}
+
+func TestCompleteOIDCLoginLinksAccount(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+	repo := newTestUserRepo(domain.User{ID: 1, Username: "reader", Email: "reader@example.com", Role: "user"})
+	s := newTestOIDCAuthService(t, issuer, repo, &testMailer{})
+
+	state, code := startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+		Subject: "subject-1", Email: "reader@example.com", EmailVerified: true,
+	})
+
+	tokens, err := s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+
+	if id := repo.identityUserID("test", "subject-1"); id != 1 {
+		t.Fatalf("identity linked to user %d, want 1", id)
+	}
+	checkTestTokens(t, s, tokens, 1)
+
+	user, _ := repo.GetByID(ctx, 1)
+	if !user.EmailVerified {
+		t.Error("email confirmed by provider is not marked verified")
+	}
+	if len(repo.users) != 1 {
+		t.Errorf("users = %d, want existing user only", len(repo.users))
+	}
+
+	// Повторный вход находит пользователя по привязанной учетной записи, даже если email у провайдера изменился
+	state, code = startTestOIDCLogin(t, s, issuer, "test", testIDToken{
+		Subject: "subject-1", Email: "changed@example.com", EmailVerified: true,
+	})
+
+	tokens, err = s.CompleteOIDCLogin(ctx, "test", state, code, domain.ClientInfo{})
+	if err != nil {
+		t.Fatalf("second CompleteOIDCLogin: %v", err)
+	}
+	checkTestTokens(t, s, tokens, 1)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis минимальный сервер Redis для тестов сервисов: строки хранятся в памяти,
+// HSET, SADD и EXPIRE только подтверждаются, время жизни ключей не учитывается.
+// Неизвестные команды возвращают ошибку, поэтому тест упадет, если сервис начнет их использовать.
+type testRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+// newTestRedis запускает testRedis и возвращает подключенный к нему клиент
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &testRedis{strings: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:            listener.Addr().String(),
+		Protocol:        2,
+		DisableIdentity: true,
+	})
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+
+	return client
+}
+
+// serve обрабатывает команды одного подключения, включая транзакции MULTI/EXEC
+func (s *testRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	var queued [][]string
+	multi := false
+
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+
+		switch name := strings.ToUpper(args[0]); {
+		case name == "MULTI":
+			multi = true
+			writer.WriteString("+OK\r\n")
+		case name == "EXEC":
+			fmt.Fprintf(writer, "*%d\r\n", len(queued))
+			for _, cmd := range queued {
+				writer.WriteString(s.exec(cmd))
+			}
+			queued, multi = nil, false
+		case multi:
+			queued = append(queued, args)
+			writer.WriteString("+QUEUED\r\n")
+		default:
+			writer.WriteString(s.exec(args))
+		}
+
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec выполняет команду и возвращает ответ в формате RESP2
+func (s *testRedis) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		s.strings[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET", "GETDEL":
+		value, ok := s.strings[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		if strings.EqualFold(args[0], "GETDEL") {
+			delete(s.strings, args[1])
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.strings[key]; ok {
+				delete(s.strings, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "HSET", "SADD", "EXPIRE":
+		return ":1\r\n"
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// readRedisCommand читает команду клиента: массив bulk-строк
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	count, err := readRedisLength(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		size, err := readRedisLength(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+// readRedisLength читает строку вида <prefix><число>\r\n
+func readRedisLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+
+	return strconv.Atoi(line[1:])
+}
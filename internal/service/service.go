@@ -10,6 +10,9 @@ type Services struct {
 	Chapter *ChapterService
 	Auth    *AuthService
 	User    *UserService
+	Comment *CommentService
+	Team    *TeamService
+	Audit   *AuditService
 }
 
 // Now возвращает текущее время (для удобства мокирования в тестах)
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Схема индекса подсказок поиска в Redis:
+//
+//	manga_suggest          -> sorted set с нулевыми score; элемент - "<текст для поиска>\x00<тип>\x00<ID>\x00<текст>"
+//	manga_suggest_members  -> hash: ID манги (или genres) -> JSON-список ее элементов manga_suggest
+//	manga_suggest_built    -> метка полностью построенного индекса, создается только перестроением
+//
+// Элементы с одинаковым score упорядочены лексикографически, поэтому подсказки по префиксу
+// выбираются одним ZRANGEBYLEX. Для каждого поля индексируются окончания, начинающиеся с каждого слова,
+// чтобы "пис" находил "Ван Пис".
+const (
+	suggestKey         = "manga_suggest"
+	suggestMembersKey  = "manga_suggest_members"
+	suggestBuiltKey    = "manga_suggest_built"
+	suggestGenresField = "genres"
+
+	suggestMaxWords    = 6  // Количество слов поля, с которых может начинаться подсказка
+	suggestFetchFactor = 4  // Запас элементов на подсказки, которые отбрасываются как повторы
+	defaultSuggestSize = 10 // Количество подсказок по умолчанию
+	maxSuggestSize     = 20
+
+	suggestRebuildTimeout = time.Minute // Время на фоновое перестроение индекса
+)
+
+// suggestIndex хранит индекс подсказок поиска по названиям, авторам и жанрам в Redis
+type suggestIndex struct {
+	client *redis.Client
+}
+
+// search возвращает подсказки, начинающиеся с prefix: сначала совпадения с начала текста, затем с начала слова
+func (s *suggestIndex) search(ctx context.Context, prefix string, limit int) ([]domain.Suggestion, error) {
+	query := normalizeSuggestText(prefix)
+	if query == "" {
+		return []domain.Suggestion{}, nil
+	}
+
+	members, err := s.client.ZRangeByLex(ctx, suggestKey, &redis.ZRangeBy{
+		Min:   "[" + query,
+		Max:   "[" + query + "\xff",
+		Count: int64(limit * suggestFetchFactor),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search suggestions: %w", err)
+	}
+
+	type match struct {
+		suggestion domain.Suggestion
+		fromStart  bool
+	}
+
+	seen := make(map[string]bool, len(members))
+	matches := make([]match, 0, len(members))
+	for _, member := range members {
+		suggestion, key, ok := parseSuggestMember(member)
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		fromStart := strings.HasPrefix(normalizeSuggestText(suggestion.Text), query)
+		matches = append(matches, match{suggestion: suggestion, fromStart: fromStart})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].fromStart && !matches[j].fromStart
+	})
+
+	suggestions := make([]domain.Suggestion, 0, limit)
+	for _, m := range matches {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, m.suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// put добавляет или заменяет подсказки манги. Пока индекс не построен, ничего не делает:
+// иначе частичный индекс из одной манги выглядел бы построенным.
+func (s *suggestIndex) put(ctx context.Context, manga domain.Manga) error {
+	if built, err := s.built(ctx); err != nil || !built {
+		return err
+	}
+
+	field := strconv.Itoa(manga.ID)
+
+	previous, err := s.members(ctx, field)
+	if err != nil {
+		return err
+	}
+
+	members := mangaSuggestMembers(manga)
+	data, err := json.Marshal(members)
+	if err != nil {
+		return fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(previous) > 0 {
+			pipe.ZRem(ctx, suggestKey, toInterfaces(previous)...)
+		}
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, suggestKey, toZMembers(members)...)
+		}
+		pipe.HSet(ctx, suggestMembersKey, field, data)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save suggestions: %w", err)
+	}
+
+	return nil
+}
+
+// remove удаляет подсказки манги. Пока индекс не построен, ничего не делает.
+func (s *suggestIndex) remove(ctx context.Context, mangaID int) error {
+	if built, err := s.built(ctx); err != nil || !built {
+		return err
+	}
+
+	field := strconv.Itoa(mangaID)
+
+	previous, err := s.members(ctx, field)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(previous) > 0 {
+			pipe.ZRem(ctx, suggestKey, toInterfaces(previous)...)
+		}
+		pipe.HDel(ctx, suggestMembersKey, field)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove suggestions: %w", err)
+	}
+
+	return nil
+}
+
+// rebuild заново строит индекс по всей манге и жанрам. Индекс собирается во временных ключах
+// и заменяет текущий атомарно, поэтому поиск подсказок во время перестроения не прерывается.
+func (s *suggestIndex) rebuild(ctx context.Context, mangas []domain.Manga, genres []domain.Genre) error {
+	suffix := ":" + uuid.New().String()
+	tmpKey, tmpMembersKey := suggestKey+suffix, suggestMembersKey+suffix
+
+	fields := make(map[string]interface{}, len(mangas)+1)
+	var all []string
+	for _, manga := range mangas {
+		members := mangaSuggestMembers(manga)
+		data, err := json.Marshal(members)
+		if err != nil {
+			return fmt.Errorf("failed to encode suggestions: %w", err)
+		}
+		fields[strconv.Itoa(manga.ID)] = data
+		all = append(all, members...)
+	}
+
+	genreMembers := make([]string, 0, len(genres))
+	for _, genre := range genres {
+		genreMembers = append(genreMembers, suggestMembers(domain.SuggestionGenre, genre.ID, genre.Name)...)
+	}
+	data, err := json.Marshal(genreMembers)
+	if err != nil {
+		return fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+	fields[suggestGenresField] = data
+	all = append(all, genreMembers...)
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(all) > 0 {
+			pipe.ZAdd(ctx, tmpKey, toZMembers(all)...)
+		}
+		pipe.HSet(ctx, tmpMembersKey, fields)
+		return nil
+	})
+	if err != nil {
+		s.client.Del(ctx, tmpKey, tmpMembersKey)
+		return fmt.Errorf("failed to build suggestions: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(all) > 0 {
+			pipe.Rename(ctx, tmpKey, suggestKey)
+		} else {
+			pipe.Del(ctx, suggestKey)
+		}
+		pipe.Rename(ctx, tmpMembersKey, suggestMembersKey)
+		pipe.Set(ctx, suggestBuiltKey, 1, 0)
+		return nil
+	})
+	if err != nil {
+		s.client.Del(ctx, tmpKey, tmpMembersKey)
+		return fmt.Errorf("failed to replace suggestions: %w", err)
+	}
+
+	return nil
+}
+
+// built сообщает, построен ли индекс целиком
+func (s *suggestIndex) built(ctx context.Context) (bool, error) {
+	n, err := s.client.Exists(ctx, suggestBuiltKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check suggestions: %w", err)
+	}
+	return n > 0, nil
+}
+
+// members возвращает элементы индекса, сохраненные для поля hash
+func (s *suggestIndex) members(ctx context.Context, field string) ([]string, error) {
+	data, err := s.client.HGet(ctx, suggestMembersKey, field).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get suggestions: %w", err)
+	}
+
+	var members []string
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("failed to decode suggestions: %w", err)
+	}
+	return members, nil
+}
+
+// mangaSuggestMembers возвращает элементы индекса для названий, автора и художника манги
+func mangaSuggestMembers(manga domain.Manga) []string {
+	var members []string
+	members = append(members, suggestMembers(domain.SuggestionTitle, manga.ID, manga.Title)...)
+	members = append(members, suggestMembers(domain.SuggestionAlterTitle, manga.ID, manga.AlterTitle)...)
+	members = append(members, suggestMembers(domain.SuggestionAuthor, manga.ID, manga.Author)...)
+	if manga.Artist != manga.Author {
+		members = append(members, suggestMembers(domain.SuggestionAuthor, manga.ID, manga.Artist)...)
+	}
+	return members
+}
+
+// suggestMembers возвращает элементы индекса для текста: по одному на каждое из первых слов
+func suggestMembers(kind string, id int, text string) []string {
+	text = strings.TrimSpace(text)
+	words := strings.Fields(normalizeSuggestText(text))
+	if len(words) > suggestMaxWords {
+		words = words[:suggestMaxWords]
+	}
+
+	// Текст подсказки не должен содержать разделитель полей элемента
+	text = strings.ReplaceAll(text, "\x00", "")
+
+	members := make([]string, 0, len(words))
+	for i := range words {
+		members = append(members, strings.Join(words[i:], " ")+"\x00"+kind+"\x00"+strconv.Itoa(id)+"\x00"+text)
+	}
+	return members
+}
+
+// parseSuggestMember разбирает элемент индекса. Ключ повтора совпадает у одного названия манги,
+// найденного по разным словам, и у одинаковых авторов и жанров разных манги.
+func parseSuggestMember(member string) (domain.Suggestion, string, bool) {
+	parts := strings.SplitN(member, "\x00", 4)
+	if len(parts) != 4 {
+		return domain.Suggestion{}, "", false
+	}
+
+	suggestion := domain.Suggestion{Type: parts[1], Text: parts[3]}
+	key := suggestion.Type + "\x00" + normalizeSuggestText(suggestion.Text)
+
+	if suggestion.Type == domain.SuggestionTitle || suggestion.Type == domain.SuggestionAlterTitle {
+		id, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return domain.Suggestion{}, "", false
+		}
+		suggestion.MangaID = id
+		key = suggestion.Type + "\x00" + parts[2]
+	}
+
+	return suggestion, key, true
+}
+
+// normalizeSuggestText приводит текст к виду для поиска: нижний регистр, ё как е,
+// слова без знаков препинания через один пробел
+func normalizeSuggestText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// toZMembers преобразует элементы индекса в элементы sorted set с нулевым score
+func toZMembers(members []string) []redis.Z {
+	z := make([]redis.Z, 0, len(members))
+	for _, member := range members {
+		z = append(z, redis.Z{Member: member})
+	}
+	return z
+}
+
+// toInterfaces преобразует строки в аргументы команды Redis
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
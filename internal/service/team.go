@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
+)
+
+// teamRolePermissions права участников команды по ролям
+var teamRolePermissions = map[string][]string{
+	domain.TeamRoleOwner:    {domain.PermissionUpload, domain.PermissionDeleteManga, domain.PermissionManageMembers},
+	domain.TeamRoleUploader: {domain.PermissionUpload},
+	domain.TeamRoleMember:   {},
+}
+
+// teamRoleHasPermission сообщает, дает ли роль в команде указанное право
+func teamRoleHasPermission(role, permission string) bool {
+	for _, p := range teamRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTeamPermission проверяет, что пользователь имеет право permission в команде teamID.
+// Администратор имеет все права; мангу без команды может изменять только администратор.
+func checkTeamPermission(ctx context.Context, teams repository.TeamRepository, userID int, admin bool, teamID *int, permission string) error {
+	if admin {
+		return nil
+	}
+
+	if teamID == nil {
+		return errors.New("permission denied: manga is not assigned to a team")
+	}
+
+	role, err := teams.GetMemberRole(ctx, *teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !teamRoleHasPermission(role, permission) {
+		return fmt.Errorf("permission denied: %s permission in team %d is required", permission, *teamID)
+	}
+
+	return nil
+}
+
+// TeamService предоставляет методы для работы с командами и их участниками
+type TeamService struct {
+	repo     repository.TeamRepository
+	userRepo repository.UserRepository
+	audit    *AuditService
+	logger   *slog.Logger
+}
+
+// NewTeamService создает новый экземпляр TeamService
+func NewTeamService(repo repository.TeamRepository, userRepo repository.UserRepository, audit *AuditService, logger *slog.Logger) *TeamService {
+	return &TeamService{
+		repo:     repo,
+		userRepo: userRepo,
+		audit:    audit,
+		logger:   logger,
+	}
+}
+
+// List возвращает список всех команд
+func (s *TeamService) List(ctx context.Context) ([]domain.Team, error) {
+	s.logger.DebugContext(ctx, "getting teams list")
+
+	teams, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get teams list", "error", err)
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// GetByID возвращает команду по ID
+func (s *TeamService) GetByID(ctx context.Context, id int) (domain.Team, error) {
+	s.logger.DebugContext(ctx, "getting team by id", "id", id)
+
+	team, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get team", "id", id, "error", err)
+		return domain.Team{}, err
+	}
+
+	return team, nil
+}
+
+// Create создает команду, input.OwnerID становится ее владельцем
+func (s *TeamService) Create(ctx context.Context, input domain.TeamInput) (int, error) {
+	s.logger.InfoContext(ctx, "creating team", "name", input.Name, "owner_id", input.OwnerID)
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return 0, errors.New("invalid team: name is required")
+	}
+	if input.OwnerID == 0 {
+		return 0, errors.New("invalid team: owner id is required")
+	}
+
+	if err := s.checkNameAvailable(ctx, name, 0); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, input.OwnerID); err != nil {
+		s.logger.ErrorContext(ctx, "team owner not found", "owner_id", input.OwnerID, "error", err)
+		return 0, err
+	}
+
+	team := domain.Team{Name: name, Description: strings.TrimSpace(input.Description)}
+	id, err := s.repo.Create(ctx, team, input.OwnerID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to create team", "name", name, "error", err)
+		return 0, err
+	}
+
+	team.ID = id
+	s.audit.Record(ctx, AuditActionCreate, domain.AuditEntityTeam, id, nil, team)
+
+	s.logger.InfoContext(ctx, "team created successfully", "id", id, "name", name)
+	return id, nil
+}
+
+// Update изменяет название и описание команды
+func (s *TeamService) Update(ctx context.Context, id int, input domain.TeamInput) error {
+	s.logger.InfoContext(ctx, "updating team", "id", id)
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return errors.New("invalid team: name is required")
+	}
+
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "team not found for update", "id", id, "error", err)
+		return err
+	}
+
+	if err := s.checkNameAvailable(ctx, name, id); err != nil {
+		return err
+	}
+
+	team := domain.Team{ID: id, Name: name, Description: strings.TrimSpace(input.Description)}
+	if err := s.repo.Update(ctx, team); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update team", "id", id, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionUpdate, domain.AuditEntityTeam, id, existing, team)
+
+	s.logger.InfoContext(ctx, "team updated successfully", "id", id)
+	return nil
+}
+
+// Delete удаляет команду. Манга команды остается без команды, и изменять ее может только администратор.
+func (s *TeamService) Delete(ctx context.Context, id int) error {
+	s.logger.InfoContext(ctx, "deleting team", "id", id)
+
+	team, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "team not found for deletion", "id", id, "error", err)
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete team", "id", id, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityTeam, id, team, nil)
+
+	s.logger.InfoContext(ctx, "team deleted successfully", "id", id)
+	return nil
+}
+
+// GetMembers возвращает участников команды
+func (s *TeamService) GetMembers(ctx context.Context, teamID int) ([]domain.TeamMember, error) {
+	s.logger.DebugContext(ctx, "getting team members", "team_id", teamID)
+
+	if _, err := s.repo.GetByID(ctx, teamID); err != nil {
+		s.logger.ErrorContext(ctx, "team not found", "team_id", teamID, "error", err)
+		return nil, err
+	}
+
+	members, err := s.repo.GetMembers(ctx, teamID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get team members", "team_id", teamID, "error", err)
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// GetUserTeams возвращает команды пользователя вместе с его ролью в них
+func (s *TeamService) GetUserTeams(ctx context.Context, userID int) ([]domain.UserTeam, error) {
+	s.logger.DebugContext(ctx, "getting user teams", "user_id", userID)
+
+	teams, err := s.repo.GetUserTeams(ctx, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get user teams", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// SetMember добавляет пользователя в команду или меняет его роль.
+// Доступно участникам команды с правом управления составом и администраторам.
+func (s *TeamService) SetMember(ctx context.Context, teamID, actorID int, admin bool, userID int, role string) error {
+	s.logger.InfoContext(ctx, "setting team member", "team_id", teamID, "user_id", userID, "role", role, "actor_id", actorID)
+
+	if _, ok := teamRolePermissions[role]; !ok {
+		return fmt.Errorf("invalid team member: unknown role %s", role)
+	}
+
+	if _, err := s.repo.GetByID(ctx, teamID); err != nil {
+		s.logger.ErrorContext(ctx, "team not found", "team_id", teamID, "error", err)
+		return err
+	}
+
+	if err := checkTeamPermission(ctx, s.repo, actorID, admin, &teamID, domain.PermissionManageMembers); err != nil {
+		return err
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		s.logger.ErrorContext(ctx, "user not found", "user_id", userID, "error", err)
+		return err
+	}
+
+	previous, err := s.repo.GetMemberRole(ctx, teamID, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get team member role", "team_id", teamID, "user_id", userID, "error", err)
+		return err
+	}
+
+	if previous == domain.TeamRoleOwner && role != domain.TeamRoleOwner {
+		if err := s.checkNotLastOwner(ctx, teamID, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.SetMember(ctx, teamID, userID, role); err != nil {
+		s.logger.ErrorContext(ctx, "failed to set team member", "team_id", teamID, "user_id", userID, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionSetMember, domain.AuditEntityTeam, teamID,
+		teamMemberSnapshot(userID, previous), teamMemberSnapshot(userID, role))
+
+	s.logger.InfoContext(ctx, "team member set successfully", "team_id", teamID, "user_id", userID, "role", role)
+	return nil
+}
+
+// RemoveMember исключает пользователя из команды.
+// Доступно участникам команды с правом управления составом и администраторам; участник может покинуть команду сам.
+func (s *TeamService) RemoveMember(ctx context.Context, teamID, actorID int, admin bool, userID int) error {
+	s.logger.InfoContext(ctx, "removing team member", "team_id", teamID, "user_id", userID, "actor_id", actorID)
+
+	if _, err := s.repo.GetByID(ctx, teamID); err != nil {
+		s.logger.ErrorContext(ctx, "team not found", "team_id", teamID, "error", err)
+		return err
+	}
+
+	if actorID != userID {
+		if err := checkTeamPermission(ctx, s.repo, actorID, admin, &teamID, domain.PermissionManageMembers); err != nil {
+			return err
+		}
+	}
+
+	previous, err := s.repo.GetMemberRole(ctx, teamID, userID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get team member role", "team_id", teamID, "user_id", userID, "error", err)
+		return err
+	}
+
+	if previous == domain.TeamRoleOwner {
+		if err := s.checkNotLastOwner(ctx, teamID, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.RemoveMember(ctx, teamID, userID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to remove team member", "team_id", teamID, "user_id", userID, "error", err)
+		return err
+	}
+
+	s.audit.Record(ctx, AuditActionRemoveMember, domain.AuditEntityTeam, teamID, teamMemberSnapshot(userID, previous), nil)
+
+	s.logger.InfoContext(ctx, "team member removed successfully", "team_id", teamID, "user_id", userID)
+	return nil
+}
+
+// checkNotLastOwner проверяет, что кроме владельца userID в команде есть другой владелец.
+// Репозиторий повторяет проверку в транзакции изменения состава, что защищает от параллельных запросов.
+func (s *TeamService) checkNotLastOwner(ctx context.Context, teamID, userID int) error {
+	members, err := s.repo.GetMembers(ctx, teamID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get team members", "team_id", teamID, "error", err)
+		return err
+	}
+
+	for _, member := range members {
+		if member.UserID != userID && member.Role == domain.TeamRoleOwner {
+			return nil
+		}
+	}
+
+	s.logger.WarnContext(ctx, "refusing to remove last team owner", "team_id", teamID, "user_id", userID)
+	return errors.New("invalid team member: team must have at least one owner")
+}
+
+// teamMemberSnapshot возвращает состояние участника команды для журнала аудита; пустая роль - не участник
+func teamMemberSnapshot(userID int, role string) any {
+	if role == "" {
+		return nil
+	}
+	return map[string]any{"user_id": userID, "role": role}
+}
+
+// checkNameAvailable проверяет, что название не занято другой командой
+func (s *TeamService) checkNameAvailable(ctx context.Context, name string, teamID int) error {
+	existing, err := s.repo.GetByName(ctx, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		s.logger.ErrorContext(ctx, "failed to check team name", "name", name, "error", err)
+		return err
+	}
+
+	if existing.ID != teamID {
+		return errors.New("team name already exists")
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Ошибки проверки refresh-токена
+var (
+	errRefreshTokenInvalid = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+	errSessionNotFound     = errors.New("session not found")
+)
+
+// Схема хранения refresh-токенов в Redis:
+//
+//	refresh_token:<user>:<jti>        -> ID семейства; ключ существует только для текущего токена семейства
+//	refresh_used:<user>:<jti>         -> ID семейства; уже использованные (ротированные) токены
+//	refresh_family:<user>:<family>    -> hash с текущим jti и данными сессии (устройство, IP, время входа)
+//	refresh_families:<user>           -> set семейств пользователя (для выхода со всех устройств)
+//
+// Семейство - цепочка токенов, полученных ротацией от одного входа, то есть сессия пользователя.
+// Повторное использование ротированного токена означает его кражу, поэтому отзывается все семейство.
+const (
+	refreshTokenKeyFmt    = "refresh_token:%d:%s"
+	refreshUsedKeyFmt     = "refresh_used:%d:%s"
+	refreshFamilyKeyFmt   = "refresh_family:%d:%s"
+	refreshFamiliesKeyFmt = "refresh_families:%d"
+)
+
+// rotateRefreshScript атомарно заменяет текущий токен семейства новым.
+// KEYS: токен, отметка использования, set семейств.
+// ARGV: новый jti, TTL в мс, ID пользователя, время использования (unix), IP клиента.
+// Возвращает {"ok", family}, {"reused", family} или {"invalid", ""}.
+var rotateRefreshScript = redis.NewScript(`
+local family = redis.call('GET', KEYS[1])
+if family then
+	local familyKey = 'refresh_family:' .. ARGV[3] .. ':' .. family
+	redis.call('DEL', KEYS[1])
+	redis.call('SET', KEYS[2], family, 'PX', ARGV[2])
+	redis.call('SET', 'refresh_token:' .. ARGV[3] .. ':' .. ARGV[1], family, 'PX', ARGV[2])
+	redis.call('HSET', familyKey, 'jti', ARGV[1], 'last_used_at', ARGV[4], 'ip', ARGV[5])
+	redis.call('PEXPIRE', familyKey, ARGV[2])
+	redis.call('PEXPIRE', KEYS[3], ARGV[2])
+	return {'ok', family}
+end
+
+family = redis.call('GET', KEYS[2])
+if family then
+	local familyKey = 'refresh_family:' .. ARGV[3] .. ':' .. family
+	local current = redis.call('HGET', familyKey, 'jti')
+	if current then
+		redis.call('DEL', 'refresh_token:' .. ARGV[3] .. ':' .. current)
+	end
+	redis.call('DEL', familyKey)
+	redis.call('SREM', KEYS[3], family)
+	return {'reused', family}
+end
+
+return {'invalid', ''}
+`)
+
+// refreshTokenStore хранит refresh-токены и сессии в Redis
+type refreshTokenStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// create сохраняет первый токен нового семейства вместе с данными сессии
+func (s *refreshTokenStore) create(ctx context.Context, userID int, family, jti string, session domain.Session) error {
+	familyKey := fmt.Sprintf(refreshFamilyKeyFmt, userID, family)
+	familiesKey := fmt.Sprintf(refreshFamiliesKeyFmt, userID)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, fmt.Sprintf(refreshTokenKeyFmt, userID, jti), family, s.ttl)
+		pipe.HSet(ctx, familyKey,
+			"jti", jti,
+			"device", session.Device,
+			"user_agent", session.UserAgent,
+			"ip", session.IP,
+			"created_at", session.CreatedAt.Unix(),
+			"last_used_at", session.LastUsedAt.Unix(),
+		)
+		pipe.Expire(ctx, familyKey, s.ttl)
+		pipe.SAdd(ctx, familiesKey, family)
+		pipe.Expire(ctx, familiesKey, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// rotate заменяет токен jti на newJTI и возвращает ID семейства.
+// Если jti уже был ротирован, отзывает все семейство и возвращает errRefreshTokenReused.
+func (s *refreshTokenStore) rotate(ctx context.Context, userID int, jti, newJTI, ip string) (string, error) {
+	keys := []string{
+		fmt.Sprintf(refreshTokenKeyFmt, userID, jti),
+		fmt.Sprintf(refreshUsedKeyFmt, userID, jti),
+		fmt.Sprintf(refreshFamiliesKeyFmt, userID),
+	}
+
+	result, err := rotateRefreshScript.Run(ctx, s.client, keys,
+		newJTI, s.ttl.Milliseconds(), userID, Now().Unix(), ip).StringSlice()
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	switch result[0] {
+	case "ok":
+		return result[1], nil
+	case "reused":
+		return result[1], errRefreshTokenReused
+	default:
+		return "", errRefreshTokenInvalid
+	}
+}
+
+// list возвращает активные сессии пользователя
+func (s *refreshTokenStore) list(ctx context.Context, userID int) ([]domain.Session, error) {
+	familiesKey := fmt.Sprintf(refreshFamiliesKeyFmt, userID)
+
+	families, err := s.client.SMembers(ctx, familiesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token families: %w", err)
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(families))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, family := range families {
+			cmds[i] = pipe.HGetAll(ctx, fmt.Sprintf(refreshFamilyKeyFmt, userID, family))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	sessions := make([]domain.Session, 0, len(families))
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		// Семейство истекло по TTL, но осталось в set
+		if len(fields) == 0 {
+			expired = append(expired, families[i])
+			continue
+		}
+
+		sessions = append(sessions, domain.Session{
+			ID:         families[i],
+			Device:     fields["device"],
+			UserAgent:  fields["user_agent"],
+			IP:         fields["ip"],
+			CreatedAt:  unixField(fields["created_at"]),
+			LastUsedAt: unixField(fields["last_used_at"]),
+		})
+	}
+
+	if len(expired) > 0 {
+		if err := s.client.SRem(ctx, familiesKey, expired...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to remove expired sessions: %w", err)
+		}
+	}
+
+	return sessions, nil
+}
+
+// revoke отзывает одну сессию пользователя
+func (s *refreshTokenStore) revoke(ctx context.Context, userID int, family string) error {
+	exists, err := s.client.SIsMember(ctx, fmt.Sprintf(refreshFamiliesKeyFmt, userID), family).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get token families: %w", err)
+	}
+	if !exists {
+		return errSessionNotFound
+	}
+
+	return s.revokeFamily(ctx, userID, family)
+}
+
+// revokeAll отзывает все семейства токенов пользователя
+func (s *refreshTokenStore) revokeAll(ctx context.Context, userID int) error {
+	familiesKey := fmt.Sprintf(refreshFamiliesKeyFmt, userID)
+
+	families, err := s.client.SMembers(ctx, familiesKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get token families: %w", err)
+	}
+
+	for _, family := range families {
+		if err := s.revokeFamily(ctx, userID, family); err != nil {
+			return err
+		}
+	}
+
+	if err := s.client.Del(ctx, familiesKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete token families: %w", err)
+	}
+
+	return nil
+}
+
+// revokeFamily отзывает текущий токен семейства и само семейство
+func (s *refreshTokenStore) revokeFamily(ctx context.Context, userID int, family string) error {
+	familyKey := fmt.Sprintf(refreshFamilyKeyFmt, userID, family)
+
+	jti, err := s.client.HGet(ctx, familyKey, "jti").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to get token family: %w", err)
+	}
+
+	keys := []string{familyKey}
+	if jti != "" {
+		keys = append(keys, fmt.Sprintf(refreshTokenKeyFmt, userID, jti))
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.SRem(ctx, fmt.Sprintf(refreshFamiliesKeyFmt, userID), family)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+// unixField разбирает время в формате unix из поля hash
+func unixField(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
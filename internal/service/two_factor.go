@@ -0,0 +1,456 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
+	"github.com/LirikaOne-Back/manga-reader3/pkg/utils"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Параметры двухфакторной аутентификации
+const (
+	twoFactorChallengeTTL      = 5 * time.Minute
+	twoFactorMaxAttempts       = 5 // Неверных кодов на один challenge, после чего вход нужно начать заново
+	twoFactorSkew              = 1 // Допустимое расхождение часов в интервалах TOTP
+	twoFactorRecoveryCodeCount = 10
+)
+
+// Ошибки двухфакторной аутентификации
+var (
+	errTwoFactorInvalidCode      = errors.New("invalid two-factor code")
+	errTwoFactorChallengeInvalid = errors.New("invalid or expired two-factor challenge")
+	errTwoFactorNotEnabled       = errors.New("two-factor authentication not enabled")
+	errTwoFactorAlreadyEnabled   = errors.New("two-factor authentication already enabled")
+	errTwoFactorSetupMissing     = errors.New("two-factor setup not started")
+	errTwoFactorMandatory        = errors.New("two-factor authentication is mandatory for this role")
+)
+
+// twoFactorFailScript учитывает неверный код, только если challenge еще существует:
+// HINCRBY по истекшему ключу создал бы его заново без TTL.
+// KEYS: challenge. ARGV: попытки до удаления challenge.
+// Возвращает число попыток, 0 если challenge не найден.
+var twoFactorFailScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+end
+return attempts
+`)
+
+// twoFactorChallengeStore хранит незавершенные входы в Redis:
+//
+//	2fa_challenge:<sha256(token)> -> hash с ID пользователя, данными клиента и числом неверных попыток
+//	totp_used:<user>:<step>       -> отметка использованного кода TOTP, чтобы его нельзя было ввести повторно
+type twoFactorChallengeStore struct {
+	client *redis.Client
+}
+
+// issue создает challenge для пользователя, прошедшего проверку пароля
+func (s *twoFactorChallengeStore) issue(ctx context.Context, userID int, client domain.ClientInfo) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	key := "2fa_challenge:" + hashMailToken(token)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"user_id", userID,
+			"device", client.Device,
+			"user_agent", client.UserAgent,
+			"ip", client.IP,
+			"attempts", 0,
+		)
+		pipe.Expire(ctx, key, twoFactorChallengeTTL)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save two-factor challenge: %w", err)
+	}
+
+	return token, nil
+}
+
+// get возвращает пользователя и клиента challenge
+func (s *twoFactorChallengeStore) get(ctx context.Context, token string) (int, domain.ClientInfo, error) {
+	values, err := s.client.HGetAll(ctx, "2fa_challenge:"+hashMailToken(token)).Result()
+	if err != nil {
+		return 0, domain.ClientInfo{}, fmt.Errorf("failed to get two-factor challenge: %w", err)
+	}
+
+	userID, err := strconv.Atoi(values["user_id"])
+	if err != nil {
+		return 0, domain.ClientInfo{}, errTwoFactorChallengeInvalid
+	}
+
+	return userID, domain.ClientInfo{
+		Device:    values["device"],
+		UserAgent: values["user_agent"],
+		IP:        values["ip"],
+	}, nil
+}
+
+// fail учитывает неверный код и удаляет challenge после twoFactorMaxAttempts попыток
+func (s *twoFactorChallengeStore) fail(ctx context.Context, token string) error {
+	err := twoFactorFailScript.Run(ctx, s.client, []string{"2fa_challenge:" + hashMailToken(token)}, twoFactorMaxAttempts).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update two-factor challenge: %w", err)
+	}
+	return nil
+}
+
+// consume удаляет challenge; false, если его уже использовал параллельный запрос
+func (s *twoFactorChallengeStore) consume(ctx context.Context, token string) (bool, error) {
+	deleted, err := s.client.Del(ctx, "2fa_challenge:"+hashMailToken(token)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete two-factor challenge: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// markTOTPUsed запоминает использованный интервал TOTP; false, если код уже вводился
+func (s *twoFactorChallengeStore) markTOTPUsed(ctx context.Context, userID int, step int64) (bool, error) {
+	key := fmt.Sprintf("totp_used:%d:%d", userID, step)
+	ttl := utils.TOTPPeriod * time.Duration(2*twoFactorSkew+2)
+
+	ok, err := s.client.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to save used totp code: %w", err)
+	}
+	return ok, nil
+}
+
+// TwoFactorStatus возвращает состояние двухфакторной аутентификации пользователя
+func (s *AuthService) TwoFactorStatus(ctx context.Context, userID int) (domain.TwoFactorStatus, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return domain.TwoFactorStatus{}, errors.New("user not found")
+	}
+
+	status := domain.TwoFactorStatus{Required: s.twoFactorRequiredFor(user.Role)}
+
+	totp, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return domain.TwoFactorStatus{}, err
+	}
+	if totp == nil || !totp.Enabled {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.RecoveryCodesLeft, err = s.userRepo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return domain.TwoFactorStatus{}, err
+	}
+
+	return status, nil
+}
+
+// SetupTwoFactor создает новый секрет TOTP. 2FA включается после подтверждения кодом в EnableTwoFactor.
+func (s *AuthService) SetupTwoFactor(ctx context.Context, userID int) (domain.TwoFactorSetup, error) {
+	s.logger.InfoContext(ctx, "starting two-factor setup", "user_id", userID)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return domain.TwoFactorSetup{}, errors.New("user not found")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return domain.TwoFactorSetup{}, err
+	}
+
+	if err := s.userRepo.SaveTOTPSecret(ctx, userID, secret); err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			return domain.TwoFactorSetup{}, errTwoFactorAlreadyEnabled
+		}
+		s.logger.ErrorContext(ctx, "failed to save totp secret", "user_id", userID, "error", err)
+		return domain.TwoFactorSetup{}, err
+	}
+
+	return domain.TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.twoFactorIssuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor подтверждает секрет кодом из приложения, включает 2FA и возвращает коды восстановления.
+// Остальные сессии пользователя завершаются: они были открыты без второго фактора.
+func (s *AuthService) EnableTwoFactor(ctx context.Context, userID int, sessionID, code string) ([]string, error) {
+	s.logger.InfoContext(ctx, "enabling two-factor authentication", "user_id", userID)
+
+	totp, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil {
+		return nil, errTwoFactorSetupMissing
+	}
+	if totp.Enabled {
+		return nil, errTwoFactorAlreadyEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, userID, totp.Secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.EnableTOTP(ctx, userID, hashes); err != nil {
+		if strings.Contains(err.Error(), "already enabled") {
+			return nil, errTwoFactorAlreadyEnabled
+		}
+		s.logger.ErrorContext(ctx, "failed to enable totp", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	sessions, err := s.tokens.list(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to list sessions", "user_id", userID, "error", err)
+	}
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			continue
+		}
+		if err := s.tokens.revoke(ctx, userID, session.ID); err != nil && !errors.Is(err, errSessionNotFound) {
+			s.logger.WarnContext(ctx, "failed to revoke session", "user_id", userID, "session", session.ID, "error", err)
+		}
+	}
+
+	s.logger.InfoContext(ctx, "two-factor authentication enabled", "user_id", userID)
+	return codes, nil
+}
+
+// DisableTwoFactor отключает 2FA после проверки пароля и кода
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID int, password, code string) error {
+	s.logger.InfoContext(ctx, "disabling two-factor authentication", "user_id", userID)
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return errors.New("user not found")
+	}
+
+	if s.twoFactorRequiredFor(user.Role) {
+		return errTwoFactorMandatory
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		s.logger.WarnContext(ctx, "invalid password", "user_id", userID)
+		return errors.New("invalid password")
+	}
+
+	totp, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if totp == nil || !totp.Enabled {
+		return errTwoFactorNotEnabled
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, totp.Secret, code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DeleteTOTP(ctx, userID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to delete totp", "user_id", userID, "error", err)
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "two-factor authentication disabled", "user_id", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми после проверки кода из приложения
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int, code string) ([]string, error) {
+	s.logger.InfoContext(ctx, "regenerating recovery codes", "user_id", userID)
+
+	totp, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if totp == nil || !totp.Enabled {
+		return nil, errTwoFactorNotEnabled
+	}
+
+	if err := s.verifyTOTP(ctx, userID, totp.Secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		s.logger.ErrorContext(ctx, "failed to save recovery codes", "user_id", userID, "error", err)
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CompleteTwoFactorLogin завершает вход: проверяет код из приложения или код восстановления и выдает токены
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context, input domain.TwoFactorLogin) (domain.TokenResponse, error) {
+	userID, client, err := s.challenges.get(ctx, input.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errTwoFactorChallengeInvalid) {
+			s.logger.WarnContext(ctx, "invalid two-factor challenge")
+		}
+		return domain.TokenResponse{}, err
+	}
+
+	s.logger.InfoContext(ctx, "processing two-factor login", "user_id", userID)
+
+	totp, err := s.userRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		return domain.TokenResponse{}, err
+	}
+	if totp == nil || !totp.Enabled {
+		return domain.TokenResponse{}, errTwoFactorChallengeInvalid
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, totp.Secret, input.Code); err != nil {
+		if errors.Is(err, errTwoFactorInvalidCode) {
+			if err := s.challenges.fail(ctx, input.ChallengeToken); err != nil {
+				s.logger.ErrorContext(ctx, "failed to update two-factor challenge", "error", err)
+			}
+		}
+		return domain.TokenResponse{}, err
+	}
+
+	ok, err := s.challenges.consume(ctx, input.ChallengeToken)
+	if err != nil {
+		return domain.TokenResponse{}, err
+	}
+	if !ok {
+		return domain.TokenResponse{}, errTwoFactorChallengeInvalid
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "user not found", "id", userID)
+		return domain.TokenResponse{}, errors.New("user not found")
+	}
+
+	return s.startSession(ctx, user, client, true)
+}
+
+// authenticate завершает вход пользователя, подтвердившего личность паролем или у внешнего провайдера.
+// При включенной 2FA вместо токенов возвращается challenge для второго шага.
+func (s *AuthService) authenticate(ctx context.Context, user domain.User, client domain.ClientInfo) (domain.TokenResponse, error) {
+	totp, err := s.userRepo.GetTOTP(ctx, user.ID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get totp", "user_id", user.ID, "error", err)
+		return domain.TokenResponse{}, err
+	}
+
+	if totp == nil || !totp.Enabled {
+		return s.startSession(ctx, user, client, false)
+	}
+
+	token, err := s.challenges.issue(ctx, user.ID, client)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to issue two-factor challenge", "user_id", user.ID, "error", err)
+		return domain.TokenResponse{}, err
+	}
+
+	s.logger.InfoContext(ctx, "two-factor challenge issued", "user_id", user.ID)
+
+	return domain.TokenResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int64(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// twoFactorRequiredFor сообщает, обязательна ли 2FA для роли
+func (s *AuthService) twoFactorRequiredFor(role string) bool {
+	return s.twoFactorRequired && (role == "moderator" || role == "admin")
+}
+
+// verifySecondFactor проверяет код из приложения или, если передан не 6-значный код, код восстановления
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID int, secret, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits {
+		return s.verifyTOTP(ctx, userID, secret, code)
+	}
+
+	ok, err := s.userRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to use recovery code", "user_id", userID, "error", err)
+		return err
+	}
+	if !ok {
+		s.logger.WarnContext(ctx, "invalid recovery code", "user_id", userID)
+		return errTwoFactorInvalidCode
+	}
+
+	s.logger.InfoContext(ctx, "recovery code used", "user_id", userID)
+	return nil
+}
+
+// verifyTOTP проверяет код из приложения и запрещает его повторное использование
+func (s *AuthService) verifyTOTP(ctx context.Context, userID int, secret, code string) error {
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(code), Now(), twoFactorSkew)
+	if !ok {
+		s.logger.WarnContext(ctx, "invalid totp code", "user_id", userID)
+		return errTwoFactorInvalidCode
+	}
+
+	fresh, err := s.challenges.markTOTPUsed(ctx, userID, step)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to check totp reuse", "user_id", userID, "error", err)
+		return err
+	}
+	if !fresh {
+		s.logger.WarnContext(ctx, "totp code reused", "user_id", userID)
+		return errTwoFactorInvalidCode
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes создает коды восстановления вида xxxxx-xxxxx и их хеши для хранения
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, twoFactorRecoveryCodeCount)
+	hashes := make([]string, twoFactorRecoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode нормализует код восстановления (регистр, дефисы, пробелы) и возвращает его sha256
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -5,35 +5,45 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 
 	"github.com/LirikaOne-Back/manga-reader3/internal/domain"
 	"github.com/LirikaOne-Back/manga-reader3/internal/repository"
 )
 
+// Ограничения для списков закладок и истории чтения
+const (
+	defaultUserListPageSize = 50
+	maxUserListPageSize     = 200
+)
+
 // UserService предоставляет методы для работы с пользователями
 type UserService struct {
 	repo   repository.UserRepository
+	audit  *AuditService
 	logger *slog.Logger
 }
 
 // NewUserService создает новый экземпляр UserService
 func NewUserService(
 	repo repository.UserRepository,
+	audit *AuditService,
 	logger *slog.Logger,
 ) *UserService {
 	return &UserService{
 		repo:   repo,
+		audit:  audit,
 		logger: logger,
 	}
 }
 
 // GetByID возвращает пользователя по ID
 func (s *UserService) GetByID(ctx context.Context, id int) (domain.User, error) {
-	s.logger.Debug("getting user by id", "id", id)
+	s.logger.DebugContext(ctx, "getting user by id", "id", id)
 
 	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to get user", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to get user", "id", id, "error", err)
 		return domain.User{}, err
 	}
 
@@ -42,117 +52,250 @@ func (s *UserService) GetByID(ctx context.Context, id int) (domain.User, error)
 
 // Update обновляет информацию о пользователе
 func (s *UserService) Update(ctx context.Context, user domain.User) error {
-	s.logger.Info("updating user", "id", user.ID)
+	s.logger.InfoContext(ctx, "updating user", "id", user.ID)
 
 	if user.ID == 0 {
 		return errors.New("user id is required")
 	}
 
 	// Проверяем, существует ли пользователь
-	_, err := s.repo.GetByID(ctx, user.ID)
+	existing, err := s.repo.GetByID(ctx, user.ID)
 	if err != nil {
-		s.logger.Error("user not found", "id", user.ID, "error", err)
+		s.logger.ErrorContext(ctx, "user not found", "id", user.ID, "error", err)
 		return err
 	}
 
 	err = s.repo.Update(ctx, user)
 	if err != nil {
-		s.logger.Error("failed to update user", "id", user.ID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to update user", "id", user.ID, "error", err)
 		return err
 	}
 
-	s.logger.Info("user updated successfully", "id", user.ID)
+	s.audit.Record(ctx, AuditActionUpdate, domain.AuditEntityUser, user.ID, existing, user)
+
+	s.logger.InfoContext(ctx, "user updated successfully", "id", user.ID)
 	return nil
 }
 
 // Delete удаляет пользователя по ID
 func (s *UserService) Delete(ctx context.Context, id int) error {
-	s.logger.Info("deleting user", "id", id)
+	s.logger.InfoContext(ctx, "deleting user", "id", id)
 
 	// Проверяем, существует ли пользователь
-	_, err := s.repo.GetByID(ctx, id)
+	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.Error("user not found", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "user not found", "id", id, "error", err)
 		return err
 	}
 
 	err = s.repo.Delete(ctx, id)
 	if err != nil {
-		s.logger.Error("failed to delete user", "id", id, "error", err)
+		s.logger.ErrorContext(ctx, "failed to delete user", "id", id, "error", err)
 		return err
 	}
 
-	s.logger.Info("user deleted successfully", "id", id)
+	s.audit.Record(ctx, AuditActionDelete, domain.AuditEntityUser, id, user, nil)
+
+	s.logger.InfoContext(ctx, "user deleted successfully", "id", id)
 	return nil
 }
 
 // AddBookmark добавляет закладку для пользователя
 func (s *UserService) AddBookmark(ctx context.Context, userID, mangaID int) error {
-	s.logger.Info("adding bookmark", "user_id", userID, "manga_id", mangaID)
+	s.logger.InfoContext(ctx, "adding bookmark", "user_id", userID, "manga_id", mangaID)
 
 	err := s.repo.AddBookmark(ctx, userID, mangaID)
 	if err != nil {
-		s.logger.Error("failed to add bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to add bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
 		return fmt.Errorf("failed to add bookmark: %w", err)
 	}
 
-	s.logger.Info("bookmark added successfully", "user_id", userID, "manga_id", mangaID)
+	s.logger.InfoContext(ctx, "bookmark added successfully", "user_id", userID, "manga_id", mangaID)
 	return nil
 }
 
 // RemoveBookmark удаляет закладку пользователя
 func (s *UserService) RemoveBookmark(ctx context.Context, userID, mangaID int) error {
-	s.logger.Info("removing bookmark", "user_id", userID, "manga_id", mangaID)
+	s.logger.InfoContext(ctx, "removing bookmark", "user_id", userID, "manga_id", mangaID)
 
 	err := s.repo.RemoveBookmark(ctx, userID, mangaID)
 	if err != nil {
-		s.logger.Error("failed to remove bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
+		s.logger.ErrorContext(ctx, "failed to remove bookmark", "user_id", userID, "manga_id", mangaID, "error", err)
 		return fmt.Errorf("failed to remove bookmark: %w", err)
 	}
 
-	s.logger.Info("bookmark removed successfully", "user_id", userID, "manga_id", mangaID)
+	s.logger.InfoContext(ctx, "bookmark removed successfully", "user_id", userID, "manga_id", mangaID)
 	return nil
 }
 
-// GetBookmarks возвращает список закладок пользователя
-func (s *UserService) GetBookmarks(ctx context.Context, userID int) ([]domain.Manga, error) {
-	s.logger.Debug("getting bookmarks", "user_id", userID)
+// GetBookmarks возвращает страницу закладок пользователя, начиная с новых
+func (s *UserService) GetBookmarks(ctx context.Context, userID int, cursor string, limit int) (domain.BookmarkPage, error) {
+	s.logger.DebugContext(ctx, "getting bookmarks", "user_id", userID, "cursor", cursor)
+
+	after, err := decodePageCursor(cursor, cursorBookmarks, true)
+	if err != nil {
+		return domain.BookmarkPage{}, err
+	}
+
+	limit = userListPageSize(limit)
 
-	mangas, err := s.repo.GetBookmarks(ctx, userID)
+	// Запрашиваем на одну закладку больше, чтобы узнать, есть ли следующая страница
+	bookmarks, err := s.repo.GetBookmarks(ctx, userID, after, limit+1)
 	if err != nil {
-		s.logger.Error("failed to get bookmarks", "user_id", userID, "error", err)
-		return nil, fmt.Errorf("failed to get bookmarks: %w", err)
+		s.logger.ErrorContext(ctx, "failed to get bookmarks", "user_id", userID, "error", err)
+		return domain.BookmarkPage{}, fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	page := domain.BookmarkPage{Bookmarks: bookmarks}
+	if len(bookmarks) > limit {
+		last := bookmarks[limit-1]
+		page.Bookmarks = bookmarks[:limit]
+		page.NextCursor = encodePageCursor(domain.PageCursor{Sort: cursorBookmarks, Desc: true, ID: last.ID, Time: last.BookmarkedAt})
+	}
+	if page.Bookmarks == nil {
+		page.Bookmarks = []domain.BookmarkedManga{}
 	}
 
-	return mangas, nil
+	return page, nil
 }
 
 // SaveReadHistory сохраняет историю чтения
 func (s *UserService) SaveReadHistory(ctx context.Context, history domain.ReadHistory) error {
-	s.logger.Info("saving read history",
+	s.logger.InfoContext(ctx, "saving read history",
 		"user_id", history.UserID,
 		"manga_id", history.MangaID,
 		"chapter_id", history.ChapterID)
 
 	err := s.repo.SaveReadHistory(ctx, history)
 	if err != nil {
-		s.logger.Error("failed to save read history", "error", err)
+		s.logger.ErrorContext(ctx, "failed to save read history", "error", err)
 		return fmt.Errorf("failed to save read history: %w", err)
 	}
 
-	s.logger.Info("read history saved successfully")
+	s.logger.InfoContext(ctx, "read history saved successfully")
 	return nil
 }
 
-// GetReadHistory возвращает историю чтения пользователя
-func (s *UserService) GetReadHistory(ctx context.Context, userID int) ([]domain.ReadHistory, error) {
-	s.logger.Debug("getting read history", "user_id", userID)
+// GetReadHistory возвращает страницу истории чтения пользователя, начиная с последних прочитанных глав
+func (s *UserService) GetReadHistory(ctx context.Context, userID int, cursor string, limit int) (domain.ReadHistoryPage, error) {
+	s.logger.DebugContext(ctx, "getting read history", "user_id", userID, "cursor", cursor)
+
+	after, err := decodePageCursor(cursor, cursorReadHistory, true)
+	if err != nil {
+		return domain.ReadHistoryPage{}, err
+	}
+
+	limit = userListPageSize(limit)
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	history, err := s.repo.GetReadHistory(ctx, userID, after, limit+1)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to get read history", "user_id", userID, "error", err)
+		return domain.ReadHistoryPage{}, fmt.Errorf("failed to get read history: %w", err)
+	}
+
+	page := domain.ReadHistoryPage{History: history}
+	if len(history) > limit {
+		last := history[limit-1]
+		page.History = history[:limit]
+		page.NextCursor = encodePageCursor(domain.PageCursor{Sort: cursorReadHistory, Desc: true, ID: last.ID, Time: last.ReadAt})
+	}
+	if page.History == nil {
+		page.History = []domain.ReadHistory{}
+	}
+
+	return page, nil
+}
+
+// GetContinueReading возвращает для каждой начатой манги последнюю прочитанную главу и страницу,
+// следующую непрочитанную главу и количество непрочитанных глав.
+// Список упорядочен по времени последнего чтения, начиная с самого свежего.
+func (s *UserService) GetContinueReading(ctx context.Context, userID int) ([]domain.ContinueReading, error) {
+	s.logger.DebugContext(ctx, "getting continue reading", "user_id", userID)
 
-	history, err := s.repo.GetReadHistory(ctx, userID)
+	states, err := s.repo.GetChapterReadStates(ctx, userID)
 	if err != nil {
-		s.logger.Error("failed to get read history", "user_id", userID, "error", err)
-		return nil, fmt.Errorf("failed to get read history: %w", err)
+		s.logger.ErrorContext(ctx, "failed to get chapter read states", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to get chapter read states: %w", err)
+	}
+
+	// Группируем главы по манге, сохраняя порядок номеров глав
+	var mangaIDs []int
+	chaptersByManga := make(map[int][]domain.ChapterReadState)
+	for _, state := range states {
+		if _, ok := chaptersByManga[state.MangaID]; !ok {
+			mangaIDs = append(mangaIDs, state.MangaID)
+		}
+		chaptersByManga[state.MangaID] = append(chaptersByManga[state.MangaID], state)
+	}
+
+	shelf := make([]domain.ContinueReading, 0, len(mangaIDs))
+	for _, mangaID := range mangaIDs {
+		if item, ok := buildContinueReading(chaptersByManga[mangaID]); ok {
+			shelf = append(shelf, item)
+		}
+	}
+
+	sort.SliceStable(shelf, func(i, j int) bool {
+		return shelf[i].ReadAt.After(shelf[j].ReadAt)
+	})
+
+	return shelf, nil
+}
+
+// buildContinueReading вычисляет позицию чтения по главам одной манги, упорядоченным по номеру.
+// Непрочитанными считаются неоткрытые главы после последней прочитанной.
+func buildContinueReading(chapters []domain.ChapterReadState) (domain.ContinueReading, bool) {
+	last := -1
+	for i, ch := range chapters {
+		if ch.ReadAt != nil && (last < 0 || ch.ReadAt.After(*chapters[last].ReadAt)) {
+			last = i
+		}
+	}
+	if last < 0 {
+		return domain.ContinueReading{}, false
 	}
 
-	return history, nil
+	lastChapter := chapters[last]
+	item := domain.ContinueReading{
+		MangaID:     lastChapter.MangaID,
+		MangaTitle:  lastChapter.MangaTitle,
+		CoverURL:    lastChapter.CoverURL,
+		LastChapter: chapterRef(lastChapter),
+		ReadAt:      *lastChapter.ReadAt,
+	}
+	if lastChapter.Page != nil {
+		item.LastPage = *lastChapter.Page
+	}
+
+	for _, ch := range chapters[last+1:] {
+		if ch.ReadAt != nil {
+			continue
+		}
+		if item.NextChapter == nil {
+			next := chapterRef(ch)
+			item.NextChapter = &next
+		}
+		item.UnreadCount++
+	}
+
+	return item, true
+}
+
+// chapterRef возвращает краткую информацию о главе
+func chapterRef(state domain.ChapterReadState) domain.ChapterRef {
+	return domain.ChapterRef{
+		ID:        state.ChapterID,
+		Number:    state.ChapterNumber,
+		Title:     state.ChapterTitle,
+		PageCount: state.PageCount,
+	}
+}
+
+// userListPageSize возвращает размер страницы закладок или истории чтения
+func userListPageSize(limit int) int {
+	if limit <= 0 || limit > maxUserListPageSize {
+		return defaultUserListPageSize
+	}
+	return limit
 }
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage реализует Storage поверх локальной файловой системы
+type LocalStorage struct {
+	root      string
+	publicURL string
+}
+
+// NewLocalStorage создает хранилище в каталоге root, создавая его при необходимости
+func NewLocalStorage(root, publicURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalStorage{
+		root:      root,
+		publicURL: publicURL,
+	}, nil
+}
+
+// Put сохраняет объект в файл
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	absPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели частично записанный файл
+	tmp, err := os.CreateTemp(filepath.Dir(absPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), absPath); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// Get читает объект из файла
+func (s *LocalStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	absPath, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return data, nil
+}
+
+// Delete удаляет файл объекта
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	absPath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(absPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	s.removeEmptyDirs(filepath.Dir(absPath))
+	return nil
+}
+
+// Move переименовывает файл объекта
+func (s *LocalStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	srcPath, err := s.path(srcKey)
+	if err != nil {
+		return err
+	}
+	dstPath, err := s.path(dstKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	s.removeEmptyDirs(filepath.Dir(srcPath))
+	return nil
+}
+
+// List возвращает ключи всех файлов с указанным префиксом
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+
+	// Обходим только каталог, в котором может находиться префикс
+	dir := filepath.Join(s.root, filepath.FromSlash(prefix))
+	if !strings.HasSuffix(prefix, "/") {
+		dir = filepath.Dir(dir)
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return keys, nil
+}
+
+// URL возвращает публичный адрес объекта
+func (s *LocalStorage) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
+
+// path возвращает абсолютный путь к файлу объекта
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// removeEmptyDirs удаляет пустые каталоги вверх по дереву, не затрагивая корень хранилища
+func (s *LocalStorage) removeEmptyDirs(dir string) {
+	root := filepath.Clean(s.root)
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStorage реализует Storage в памяти процесса.
+// Используется в тестах и локальной разработке без MinIO/S3.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	objects   map[string][]byte
+	publicURL string
+}
+
+// NewMemoryStorage создает пустое хранилище в памяти
+func NewMemoryStorage(publicURL string) *MemoryStorage {
+	return &MemoryStorage{
+		objects:   make(map[string][]byte),
+		publicURL: publicURL,
+	}
+}
+
+// Put сохраняет копию данных объекта
+func (s *MemoryStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[cleaned] = append([]byte(nil), data...)
+	return nil
+}
+
+// Get возвращает копию данных объекта
+func (s *MemoryStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.objects[cleaned]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Delete удаляет объект
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, cleaned)
+	return nil
+}
+
+// Move переносит объект на новый ключ
+func (s *MemoryStorage) Move(ctx context.Context, srcKey, dstKey string) error {
+	src, err := CleanKey(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := CleanKey(dstKey)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.objects[src]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.objects, src)
+	s.objects[dst] = data
+	return nil
+}
+
+// List возвращает отсортированные ключи объектов с указанным префиксом
+func (s *MemoryStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// URL возвращает публичный адрес объекта
+func (s *MemoryStorage) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
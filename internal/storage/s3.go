@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config настройки подключения к S3-совместимому хранилищу
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	PublicURL string // Если не указан, используется адрес бакета
+}
+
+// S3Storage реализует Storage поверх S3-совместимого хранилища (AWS S3, MinIO и т.д.)
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage создает клиент S3 и проверяет существование бакета
+func NewS3Storage(ctx context.Context, cfg S3Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", cfg.Bucket)
+	}
+
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = client.EndpointURL().String() + "/" + cfg.Bucket
+	}
+
+	return &S3Storage{
+		client:    client,
+		bucket:    cfg.Bucket,
+		publicURL: publicURL,
+	}, nil
+}
+
+// Put загружает объект в бакет
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	if contentType == "" {
+		contentType = ContentType(cleaned, data)
+	}
+
+	_, err = s.client.PutObject(ctx, s.bucket, cleaned, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+
+	return nil
+}
+
+// Get скачивает объект из бакета
+func (s *S3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.client.GetObject(ctx, s.bucket, cleaned, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.wrapError("failed to get object", err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, s.wrapError("failed to read object", err)
+	}
+
+	return data, nil
+}
+
+// Delete удаляет объект из бакета
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, cleaned, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+// Move копирует объект на новый ключ и удаляет исходный
+func (s *S3Storage) Move(ctx context.Context, srcKey, dstKey string) error {
+	src, err := CleanKey(srcKey)
+	if err != nil {
+		return err
+	}
+	dst, err := CleanKey(dstKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: src},
+	)
+	if err != nil {
+		return s.wrapError("failed to copy object", err)
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, src, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete source object: %w", err)
+	}
+
+	return nil
+}
+
+// List возвращает ключи объектов с указанным префиксом
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	return keys, nil
+}
+
+// URL возвращает публичный адрес объекта
+func (s *S3Storage) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
+
+// wrapError преобразует ошибку "объект не найден" в ErrNotFound
+func (s *S3Storage) wrapError(msg string, err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrNotFound возвращается, если объект с указанным ключом не существует
+var ErrNotFound = errors.New("object not found")
+
+// Storage определяет методы для работы с хранилищем объектов.
+// Ключи - это относительные пути с разделителем "/", например "manga_1/chapter_1.00/page_001.jpg".
+type Storage interface {
+	// Put сохраняет объект, перезаписывая существующий
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get возвращает содержимое объекта или ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete удаляет объект; отсутствие объекта не считается ошибкой
+	Delete(ctx context.Context, key string) error
+	// Move переносит объект на новый ключ
+	Move(ctx context.Context, srcKey, dstKey string) error
+	// List возвращает ключи всех объектов с указанным префиксом
+	List(ctx context.Context, prefix string) ([]string, error)
+	// URL возвращает публичный адрес объекта
+	URL(key string) string
+}
+
+// DeletePrefix удаляет все объекты с указанным префиксом
+func DeletePrefix(ctx context.Context, s Storage, prefix string) error {
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete object %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// MovePrefix переносит все объекты с префиксом srcPrefix под префикс dstPrefix
+func MovePrefix(ctx context.Context, s Storage, srcPrefix, dstPrefix string) error {
+	keys, err := s.List(ctx, srcPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	for _, key := range keys {
+		dstKey := dstPrefix + strings.TrimPrefix(key, srcPrefix)
+		if err := s.Move(ctx, key, dstKey); err != nil {
+			return fmt.Errorf("failed to move object %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// ContentType определяет MIME-тип объекта по расширению ключа или по содержимому
+func ContentType(key string, data []byte) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	}
+	return http.DetectContentType(data)
+}
+
+// CleanKey нормализует ключ объекта и запрещает выход за пределы хранилища
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return cleaned, nil
+}
+
+// joinURL объединяет базовый адрес и ключ объекта
+func joinURL(baseURL, key string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(key, "/")
+}
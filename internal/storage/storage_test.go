@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testBackends возвращает реализации Storage, которые проверяются общими тестами
+func testBackends(t *testing.T) map[string]Storage {
+	t.Helper()
+
+	local, err := NewLocalStorage(t.TempDir(), "/images")
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+
+	return map[string]Storage{
+		"memory": NewMemoryStorage("/images"),
+		"local":  local,
+	}
+}
+
+func TestStoragePutGet(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Put(ctx, "manga_1/chapter_1.00/page_001.jpg", []byte("first"), "image/jpeg"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if err := s.Put(ctx, "manga_1/chapter_1.00/page_001.jpg", []byte("second"), "image/jpeg"); err != nil {
+				t.Fatalf("Put overwrite: %v", err)
+			}
+
+			data, err := s.Get(ctx, "manga_1/chapter_1.00/page_001.jpg")
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if string(data) != "second" {
+				t.Fatalf("Get returned %q, want %q", data, "second")
+			}
+
+			if _, err := s.Get(ctx, "manga_1/missing.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get missing: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStorageDelete(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Put(ctx, "a/b.jpg", []byte("x"), "image/jpeg"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if err := s.Delete(ctx, "a/b.jpg"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := s.Get(ctx, "a/b.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Delete: got %v, want ErrNotFound", err)
+			}
+
+			// Удаление отсутствующего объекта не является ошибкой
+			if err := s.Delete(ctx, "a/b.jpg"); err != nil {
+				t.Fatalf("Delete missing: %v", err)
+			}
+		})
+	}
+}
+
+func TestStorageMove(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := s.Put(ctx, "src/page.jpg", []byte("x"), "image/jpeg"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if err := s.Move(ctx, "src/page.jpg", "dst/page.jpg"); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+
+			if _, err := s.Get(ctx, "src/page.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get source after Move: got %v, want ErrNotFound", err)
+			}
+			data, err := s.Get(ctx, "dst/page.jpg")
+			if err != nil || string(data) != "x" {
+				t.Fatalf("Get destination after Move: %q, %v", data, err)
+			}
+
+			if err := s.Move(ctx, "src/page.jpg", "dst/other.jpg"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Move missing: got %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStorageListAndPrefixHelpers(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"manga_1/chapter_1.00/page_001.jpg", "manga_1/chapter_1.00/page_002.jpg", "manga_1/chapter_2.00/page_001.jpg", "manga_10/chapter_1.00/page_001.jpg"} {
+				if err := s.Put(ctx, key, []byte(key), "image/jpeg"); err != nil {
+					t.Fatalf("Put %s: %v", key, err)
+				}
+			}
+
+			keys, err := s.List(ctx, "manga_1/chapter_1.00/")
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			want := []string{"manga_1/chapter_1.00/page_001.jpg", "manga_1/chapter_1.00/page_002.jpg"}
+			if !reflect.DeepEqual(keys, want) {
+				t.Fatalf("List returned %v, want %v", keys, want)
+			}
+
+			keys, err = s.List(ctx, "manga_404/")
+			if err != nil || len(keys) != 0 {
+				t.Fatalf("List of missing prefix: %v, %v", keys, err)
+			}
+
+			if err := MovePrefix(ctx, s, "manga_1/chapter_1.00/", "manga_1/chapter_3.00/"); err != nil {
+				t.Fatalf("MovePrefix: %v", err)
+			}
+			data, err := s.Get(ctx, "manga_1/chapter_3.00/page_002.jpg")
+			if err != nil || string(data) != "manga_1/chapter_1.00/page_002.jpg" {
+				t.Fatalf("Get after MovePrefix: %q, %v", data, err)
+			}
+
+			if err := DeletePrefix(ctx, s, "manga_1/"); err != nil {
+				t.Fatalf("DeletePrefix: %v", err)
+			}
+			keys, err = s.List(ctx, "manga_")
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if !reflect.DeepEqual(keys, []string{"manga_10/chapter_1.00/page_001.jpg"}) {
+				t.Fatalf("List after DeletePrefix returned %v", keys)
+			}
+		})
+	}
+}
+
+func TestStorageKeys(t *testing.T) {
+	ctx := context.Background()
+
+	for name, s := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Ключ не может выйти за пределы хранилища
+			if err := s.Put(ctx, "../../escape.jpg", []byte("x"), "image/jpeg"); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			data, err := s.Get(ctx, "escape.jpg")
+			if err != nil || string(data) != "x" {
+				t.Fatalf("Get of cleaned key: %q, %v", data, err)
+			}
+
+			if err := s.Put(ctx, "/", []byte("x"), "image/jpeg"); err == nil {
+				t.Fatal("Put with empty key: expected error")
+			}
+
+			if got := s.URL("manga_1/page_001.jpg"); got != "/images/manga_1/page_001.jpg" {
+				t.Fatalf("URL returned %q", got)
+			}
+		})
+	}
+}
@@ -76,6 +76,9 @@ func New(cfg Config) *slog.Logger {
 		handler = slog.NewTextHandler(cfg.Output, handlerOpts)
 	}
 
+	// ID запроса из контекста добавляется к записям, созданным через *Context методы логгера
+	handler = &contextHandler{Handler: handler}
+
 	if cfg.WithSource {
 		handler = &sourceHandler{
 			Handler: handler,
@@ -99,6 +102,40 @@ func (h *sourceHandler) Handle(ctx context.Context, r slog.Record) error {
 	return h.Handler.Handle(ctx, r)
 }
 
+// requestIDKey ключ ID запроса в context.Context
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст с ID запроса
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler добавляет в запись request_id из контекста
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
 // itoa - быстрая конвертация int в string без выделения памяти
 func itoa(i int) string {
 	// Для большинства строк номеров строк 4 символов будет достаточно
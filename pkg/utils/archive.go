@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ArchiveEntry представляет файл, извлеченный из архива
+type ArchiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// ArchiveLimits ограничения на содержимое архива; нулевое значение снимает ограничение
+type ArchiveLimits struct {
+	MaxEntries   int   // Максимальное количество файлов
+	MaxEntrySize int64 // Максимальный размер одного файла после распаковки
+	MaxTotalSize int64 // Максимальный суммарный размер файлов после распаковки
+}
+
+// ReadImageArchive читает CBZ/ZIP архив и возвращает файлы изображений,
+// отсортированные в естественном порядке (page2 < page10).
+// Количество и размеры файлов проверяются по заголовкам до распаковки и еще раз при чтении.
+func ReadImageArchive(data []byte, limits ArchiveLimits) ([]ArchiveEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	files := make([]*zip.File, 0, len(reader.File))
+	var declaredSize uint64
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || isIgnoredArchiveEntry(file.Name) {
+			continue
+		}
+
+		if limits.MaxEntries > 0 && len(files) == limits.MaxEntries {
+			return nil, fmt.Errorf("invalid archive: too many files (max %d)", limits.MaxEntries)
+		}
+		if limits.MaxEntrySize > 0 && file.UncompressedSize64 > uint64(limits.MaxEntrySize) {
+			return nil, fmt.Errorf("invalid archive: entry %s is too large", file.Name)
+		}
+
+		declaredSize += file.UncompressedSize64
+		if limits.MaxTotalSize > 0 && declaredSize > uint64(limits.MaxTotalSize) {
+			return nil, fmt.Errorf("invalid archive: uncompressed size exceeds %d bytes", limits.MaxTotalSize)
+		}
+
+		files = append(files, file)
+	}
+
+	entries := make([]ArchiveEntry, 0, len(files))
+	var totalSize int64
+	for _, file := range files {
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: failed to open entry %s: %w", file.Name, err)
+		}
+
+		// Ограничиваем чтение, так как заголовок архива может содержать неверный размер
+		limit := limits.MaxEntrySize
+		if limit <= 0 {
+			limit = int64(file.UncompressedSize64)
+		}
+		if limits.MaxTotalSize > 0 && limit > limits.MaxTotalSize-totalSize {
+			limit = limits.MaxTotalSize - totalSize
+		}
+		entryData, err := io.ReadAll(io.LimitReader(rc, limit+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive: failed to read entry %s: %w", file.Name, err)
+		}
+		if int64(len(entryData)) > limit {
+			return nil, fmt.Errorf("invalid archive: entry %s is too large", file.Name)
+		}
+		totalSize += int64(len(entryData))
+
+		entries = append(entries, ArchiveEntry{
+			Name: file.Name,
+			Data: entryData,
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("invalid archive: no files found")
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return NaturalLess(entries[i].Name, entries[j].Name)
+	})
+
+	return entries, nil
+}
+
+// isIgnoredArchiveEntry проверяет, является ли файл служебным (метаданные, скрытые файлы)
+func isIgnoredArchiveEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+
+	base := path.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return true
+	}
+
+	switch strings.ToLower(base) {
+	case "comicinfo.xml", "thumbs.db":
+		return true
+	}
+
+	return false
+}
+
+// NaturalLess сравнивает строки с учетом чисел внутри них,
+// так что "page2.jpg" оказывается раньше "page10.jpg"
+func NaturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+
+	for a != "" && b != "" {
+		aDigit, bDigit := isDigit(a[0]), isDigit(b[0])
+
+		switch {
+		case aDigit && bDigit:
+			aNum, aRest := splitNumber(a)
+			bNum, bRest := splitNumber(b)
+
+			// Сравниваем числа без ведущих нулей: сначала по длине, затем лексикографически
+			aTrim, bTrim := strings.TrimLeft(aNum, "0"), strings.TrimLeft(bNum, "0")
+			if len(aTrim) != len(bTrim) {
+				return len(aTrim) < len(bTrim)
+			}
+			if aTrim != bTrim {
+				return aTrim < bTrim
+			}
+			if len(aNum) != len(bNum) {
+				return len(aNum) < len(bNum)
+			}
+			a, b = aRest, bRest
+		case a[0] != b[0]:
+			return a[0] < b[0]
+		default:
+			a, b = a[1:], b[1:]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+// splitNumber отделяет ведущую последовательность цифр от остатка строки
+func splitNumber(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// isDigit проверяет, является ли байт цифрой
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildArchive создает ZIP архив с файлами заданного размера
+func buildArchive(t *testing.T, count, size int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for i := 1; i <= count; i++ {
+		w, err := writer.Create(fmt.Sprintf("page%d.png", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(bytes.Repeat([]byte{0}, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadImageArchiveLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		size    int
+		limits  ArchiveLimits
+		wantErr string
+	}{
+		{name: "within limits", count: 3, size: 100, limits: ArchiveLimits{MaxEntries: 3, MaxEntrySize: 100, MaxTotalSize: 300}},
+		{name: "too many files", count: 4, size: 10, limits: ArchiveLimits{MaxEntries: 3}, wantErr: "too many files"},
+		{name: "entry too large", count: 1, size: 101, limits: ArchiveLimits{MaxEntrySize: 100}, wantErr: "entry page1.png is too large"},
+		{name: "total too large", count: 4, size: 100, limits: ArchiveLimits{MaxEntrySize: 100, MaxTotalSize: 300}, wantErr: "uncompressed size exceeds"},
+		{name: "no limits", count: 5, size: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadImageArchive(buildArchive(t, tt.count, tt.size), tt.limits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tt.count {
+				t.Fatalf("expected %d entries, got %d", tt.count, len(entries))
+			}
+		})
+	}
+}
+
+func TestReadImageArchiveOrder(t *testing.T) {
+	entries, err := ReadImageArchive(buildArchive(t, 12, 1), ArchiveLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if names[1] != "page2.png" || names[11] != "page12.png" {
+		t.Fatalf("unexpected order: %v", names)
+	}
+}
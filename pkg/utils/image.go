@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/chai2010/webp"
 	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
 )
 
 // ImageInfo содержит информацию об изображении
@@ -23,23 +30,48 @@ type ImageInfo struct {
 	Size        int
 }
 
+// Поддерживаемые форматы изображений
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
 // ProcessImageOptions опции для обработки изображения
 type ProcessImageOptions struct {
-	MaxWidth     int  // Максимальная ширина
-	MaxHeight    int  // Максимальная высота
-	Quality      int  // Качество (0-100)
-	ConvertToJPG bool // Конвертировать в JPEG
-	Watermark    bool // Добавить водяной знак
+	MaxWidth  int               // Максимальная ширина (0 - без ограничения)
+	MaxHeight int               // Максимальная высота (0 - без ограничения)
+	Quality   int               // Качество (0-100)
+	Format    string            // Целевой формат: jpeg, png, webp (пустая строка - исходный формат)
+	Watermark *WatermarkOptions // Водяной знак (nil - без водяного знака)
+}
+
+// Позиции водяного знака
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+)
+
+// WatermarkOptions опции водяного знака
+type WatermarkOptions struct {
+	Logo     image.Image // Логотип; если не задан, рисуется Text
+	Text     string      // Текст водяного знака
+	Position string      // Позиция: top-left, top-right, bottom-left, bottom-right, center
+	Opacity  float64     // Непрозрачность (0-1)
+	Scale    float64     // Ширина водяного знака относительно ширины страницы (0-1)
 }
 
 // DefaultProcessImageOptions возвращает опции по умолчанию
 func DefaultProcessImageOptions() ProcessImageOptions {
 	return ProcessImageOptions{
-		MaxWidth:     1920,
-		MaxHeight:    2400,
-		Quality:      85,
-		ConvertToJPG: true,
-		Watermark:    false,
+		MaxWidth:  1920,
+		MaxHeight: 2400,
+		Quality:   85,
+		Format:    FormatJPEG,
+		Watermark: nil,
 	}
 }
 
@@ -48,90 +80,143 @@ func GetImageInfo(data []byte) (*ImageInfo, error) {
 	// Определяем тип изображения
 	contentType := http.DetectContentType(data)
 
-	// Декодируем изображение для получения размеров
-	img, _, err := image.Decode(bytes.NewReader(data))
+	// Читаем только заголовок: размеры известны до декодирования пикселей
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	bounds := img.Bounds()
-
 	// Определяем формат
-	format := ""
-	switch {
-	case strings.Contains(contentType, "jpeg"):
-		format = "jpeg"
-	case strings.Contains(contentType, "png"):
-		format = "png"
-	case strings.Contains(contentType, "webp"):
-		format = "webp"
-	default:
+	format := DetectImageFormat(data)
+	if format == "" {
 		format = "unknown"
 	}
 
 	return &ImageInfo{
-		Width:       bounds.Dx(),
-		Height:      bounds.Dy(),
+		Width:       config.Width,
+		Height:      config.Height,
 		ContentType: contentType,
 		Format:      format,
 		Size:        len(data),
 	}, nil
 }
 
-// ProcessImage обрабатывает изображение согласно опциям
-func ProcessImage(data []byte, options ProcessImageOptions) ([]byte, error) {
-	// Определяем тип изображения
+// DetectImageFormat определяет формат изображения по содержимому.
+// Возвращает пустую строку, если формат не поддерживается.
+func DetectImageFormat(data []byte) string {
 	contentType := http.DetectContentType(data)
 
+	switch {
+	case strings.Contains(contentType, "jpeg"):
+		return FormatJPEG
+	case strings.Contains(contentType, "png"):
+		return FormatPNG
+	case strings.Contains(contentType, "webp"):
+		return FormatWebP
+	default:
+		return ""
+	}
+}
+
+// FormatExtension возвращает расширение файла для формата изображения
+func FormatExtension(format string) string {
+	switch format {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatPNG:
+		return ".png"
+	case FormatWebP:
+		return ".webp"
+	default:
+		return ""
+	}
+}
+
+// FormatContentType возвращает MIME-тип для формата изображения
+func FormatContentType(format string) string {
+	switch format {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatPNG:
+		return "image/png"
+	case FormatWebP:
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ProcessImage обрабатывает изображение согласно опциям и возвращает
+// закодированные данные вместе с итоговым форматом
+func ProcessImage(data []byte, options ProcessImageOptions) ([]byte, string, error) {
+	// Определяем формат изображения
+	sourceFormat := DetectImageFormat(data)
+
 	// Декодируем изображение
 	var img image.Image
 	var err error
 
 	reader := bytes.NewReader(data)
 
-	if strings.Contains(contentType, "jpeg") {
+	switch sourceFormat {
+	case FormatJPEG:
 		img, err = jpeg.Decode(reader)
-	} else if strings.Contains(contentType, "png") {
+	case FormatPNG:
 		img, err = png.Decode(reader)
-	} else if strings.Contains(contentType, "webp") {
+	case FormatWebP:
 		img, err = webp.Decode(reader)
-	} else {
-		return nil, fmt.Errorf("unsupported image format: %s", contentType)
+	default:
+		return nil, "", fmt.Errorf("unsupported image format: %s", http.DetectContentType(data))
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
 	}
 
 	// Изменяем размер, если нужно
-	img = resizeImage(img, options.MaxWidth, options.MaxHeight)
+	if options.MaxWidth > 0 || options.MaxHeight > 0 {
+		img = resizeImage(img, options.MaxWidth, options.MaxHeight)
+	}
 
 	// Добавляем водяной знак, если нужно
-	if options.Watermark {
-		img, err = addWatermark(img)
+	if options.Watermark != nil {
+		img, err = addWatermark(img, *options.Watermark)
 		if err != nil {
-			return nil, fmt.Errorf("failed to add watermark: %w", err)
+			return nil, "", fmt.Errorf("failed to add watermark: %w", err)
 		}
 	}
 
+	// Определяем целевой формат
+	targetFormat := options.Format
+	if targetFormat == "" {
+		targetFormat = sourceFormat
+	}
+
+	quality := options.Quality
+	if quality <= 0 || quality > 100 {
+		quality = DefaultProcessImageOptions().Quality
+	}
+
 	// Кодируем изображение
 	var buf bytes.Buffer
 
-	if options.ConvertToJPG {
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: options.Quality})
-	} else if strings.Contains(contentType, "jpeg") {
-		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: options.Quality})
-	} else if strings.Contains(contentType, "png") {
+	switch targetFormat {
+	case FormatJPEG:
+		// JPEG не поддерживает прозрачность, поэтому подкладываем белый фон
+		err = jpeg.Encode(&buf, flattenImage(img), &jpeg.Options{Quality: quality})
+	case FormatPNG:
 		err = png.Encode(&buf, img)
-	} else if strings.Contains(contentType, "webp") {
-		err = webp.Encode(&buf, img, &webp.Options{Quality: float32(options.Quality)})
+	case FormatWebP:
+		err = webp.Encode(&buf, img, &webp.Options{Quality: float32(quality)})
+	default:
+		return nil, "", fmt.Errorf("unsupported target format: %s", targetFormat)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to encode image: %w", err)
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return buf.Bytes(), targetFormat, nil
 }
 
 // resizeImage изменяет размер изображения, сохраняя пропорции
@@ -140,6 +225,14 @@ func resizeImage(img image.Image, maxWidth, maxHeight int) image.Image {
 	width := bounds.Dx()
 	height := bounds.Dy()
 
+	// Нулевое ограничение означает отсутствие ограничения по этой стороне
+	if maxWidth <= 0 {
+		maxWidth = width
+	}
+	if maxHeight <= 0 {
+		maxHeight = height
+	}
+
 	// Если изображение уже меньше максимальных размеров, ничего не делаем
 	if width <= maxWidth && height <= maxHeight {
 		return img
@@ -174,10 +267,151 @@ func resizeImage(img image.Image, maxWidth, maxHeight int) image.Image {
 	return imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
 }
 
+// flattenImage накладывает изображение на белый фон
+func flattenImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
+
 // addWatermark добавляет водяной знак на изображение
-func addWatermark(img image.Image) (image.Image, error) {
-	// TODO: Реализовать добавление водяного знака
-	// Для простоты просто возвращаем исходное изображение
+func addWatermark(img image.Image, options WatermarkOptions) (image.Image, error) {
+	bounds := img.Bounds()
+	pageWidth, pageHeight := bounds.Dx(), bounds.Dy()
+
+	// Масштабируем водяной знак относительно ширины страницы
+	scale := options.Scale
+	if scale <= 0 || scale > 1 {
+		scale = 0.2
+	}
+	targetWidth := int(float64(pageWidth) * scale)
+	if targetWidth < 1 {
+		return img, nil
+	}
+
+	mark := options.Logo
+	if mark == nil {
+		if options.Text == "" {
+			return nil, fmt.Errorf("watermark requires a logo or text")
+		}
+
+		// Текст рисуется сразу в нужном размере, чтобы не размывать его при масштабировании
+		var err error
+		mark, err = renderText(options.Text, targetWidth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	markBounds := mark.Bounds()
+	width, height := markBounds.Dx(), markBounds.Dy()
+	if options.Logo != nil {
+		width = targetWidth
+		height = markBounds.Dy() * width / markBounds.Dx()
+	}
+	if height > pageHeight {
+		height = pageHeight
+		width = markBounds.Dx() * height / markBounds.Dy()
+	}
+	if width < 1 || height < 1 {
+		return img, nil
+	}
+	if width != markBounds.Dx() || height != markBounds.Dy() {
+		mark = imaging.Resize(mark, width, height, imaging.Lanczos)
+	}
+
+	// Вычисляем позицию с отступом от края
+	margin := min(pageWidth, pageHeight) / 50
+	var x, y int
+	switch options.Position {
+	case PositionTopLeft:
+		x, y = margin, margin
+	case PositionTopRight:
+		x, y = pageWidth-width-margin, margin
+	case PositionBottomLeft:
+		x, y = margin, pageHeight-height-margin
+	case PositionCenter:
+		x, y = (pageWidth-width)/2, (pageHeight-height)/2
+	default:
+		x, y = pageWidth-width-margin, pageHeight-height-margin
+	}
+
+	opacity := options.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 0.5
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	target := image.Rect(x, y, x+width, y+height)
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity * 255)})
+	draw.DrawMask(dst, target, mark, mark.Bounds().Min, mask, image.Point{}, draw.Over)
+
+	return dst, nil
+}
+
+// watermarkFont возвращает векторный шрифт текста водяного знака (Go Bold, включает кириллицу)
+var watermarkFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// renderText рисует текст водяного знака шириной около width пикселей белым цветом с темной обводкой
+func renderText(text string, width int) (image.Image, error) {
+	f, err := watermarkFont()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse watermark font: %w", err)
+	}
+
+	// Подбираем размер шрифта по ширине текста, измеренной в базовом размере
+	const baseSize = 64
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: baseSize, DPI: 72})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watermark font face: %w", err)
+	}
+	measured := font.MeasureString(face, text).Ceil()
+	face.Close()
+	if measured == 0 {
+		return nil, fmt.Errorf("watermark text has no visible characters")
+	}
+
+	size := baseSize * float64(width) / float64(measured)
+	face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watermark font face: %w", err)
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	textWidth := font.MeasureString(face, text).Ceil()
+	textHeight := (metrics.Ascent + metrics.Descent).Ceil()
+
+	// Толщина обводки растет вместе с размером текста
+	outline := max(1, int(size/32))
+	img := image.NewNRGBA(image.Rect(0, 0, textWidth+2*outline, textHeight+2*outline))
+	baseline := metrics.Ascent.Ceil() + outline
+
+	drawer := &font.Drawer{Dst: img, Face: face}
+
+	// Обводка
+	drawer.Src = image.NewUniform(color.NRGBA{A: 200})
+	for dy := -outline; dy <= outline; dy += outline {
+		for dx := -outline; dx <= outline; dx += outline {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			drawer.Dot = fixed.P(outline+dx, baseline+dy)
+			drawer.DrawString(text)
+		}
+	}
+
+	// Текст
+	drawer.Src = image.White
+	drawer.Dot = fixed.P(outline, baseline)
+	drawer.DrawString(text)
+
 	return img, nil
 }
 
@@ -196,3 +430,42 @@ func IsImageValid(reader io.Reader) (bool, error) {
 	// Проверяем, является ли файл изображением
 	return strings.HasPrefix(contentType, "image/"), nil
 }
+
+// Rendition описывает именованный вариант изображения страницы
+type Rendition struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+	Quality   int
+}
+
+// Названия стандартных вариантов изображения
+const (
+	RenditionThumb  = "thumb"
+	RenditionMobile = "mobile"
+	RenditionFull   = "full"
+)
+
+// DefaultRenditions возвращает стандартный набор вариантов изображения
+func DefaultRenditions() map[string]Rendition {
+	return map[string]Rendition{
+		RenditionThumb:  {Name: RenditionThumb, MaxWidth: 320, MaxHeight: 480, Quality: 70},
+		RenditionMobile: {Name: RenditionMobile, MaxWidth: 800, MaxHeight: 0, Quality: 80},
+		RenditionFull:   {Name: RenditionFull, MaxWidth: 1920, MaxHeight: 2400, Quality: 85},
+	}
+}
+
+// Options возвращает опции обработки для получения варианта в указанном формате
+func (r Rendition) Options(format string) ProcessImageOptions {
+	return ProcessImageOptions{
+		MaxWidth:  r.MaxWidth,
+		MaxHeight: r.MaxHeight,
+		Quality:   r.Quality,
+		Format:    format,
+	}
+}
+
+// IsSupportedFormat проверяет, поддерживается ли формат для кодирования
+func IsSupportedFormat(format string) bool {
+	return format == FormatJPEG || format == FormatPNG || format == FormatWebP
+}